@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/araquach/phorest-datahub/internal/config"
+	"github.com/araquach/phorest-datahub/internal/db"
+	"github.com/araquach/phorest-datahub/internal/phorest"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// app bundles the config + DB handle every DB-backed command needs.
+type app struct {
+	cfg    *config.Config
+	logger *log.Logger
+	dsn    string
+	db     *gorm.DB
+}
+
+// loadConfig resolves config and the active (sandbox-aware) DSN without opening the DB.
+func loadConfig() (*config.Config, string, error) {
+	cfg := config.Load()
+	logger := cfg.Logger
+
+	dsn, err := cfg.ActiveDatabaseURL()
+	if err != nil {
+		return nil, "", fmt.Errorf("database URL resolution failed: %w", err)
+	}
+
+	if cfg.SandboxMode {
+		logger.Println("🧪 SANDBOX MODE ENABLED — using SANDBOX_DATABASE_URL")
+	} else {
+		logger.Println("⚠️  NORMAL MODE — using DATABASE_URL")
+	}
+
+	return cfg, dsn, nil
+}
+
+// openApp loads config, opens + health-checks the DB and (optionally) runs
+// migrations when AUTO_MIGRATE=1. Callers must defer a.close().
+func openApp() (*app, error) {
+	cfg, dsn, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	logger := cfg.Logger
+
+	if err := os.MkdirAll(cfg.ExportDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create export dir %q: %w", cfg.ExportDir, err)
+	}
+	logger.Printf("📂 Using export dir: %s", cfg.ExportDir)
+
+	gdb, err := db.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("DB connection failed: %w", err)
+	}
+
+	if err := db.HealthCheck(gdb, 3*time.Second); err != nil {
+		_ = db.Close(gdb)
+		return nil, fmt.Errorf("DB health check failed: %w", err)
+	}
+	logger.Println("✅ Database connection healthy.")
+
+	if cfg.AutoMigrate {
+		logger.Println("Running SQL migrations...")
+		if err := db.RunMigrations(dsn, "migrations", logger); err != nil {
+			_ = db.Close(gdb)
+			return nil, fmt.Errorf("database migration failed: %w", err)
+		}
+		logger.Println("✅ Database migrated successfully.")
+	}
+
+	return &app{cfg: cfg, logger: logger, dsn: dsn, db: gdb}, nil
+}
+
+func (a *app) close() {
+	_ = db.Close(a.db)
+}
+
+func (a *app) runner() *phorest.Runner {
+	return phorest.NewRunner(a.db, a.cfg, a.logger)
+}
+
+// resolveBranch maps a branch name or ID to its configured BranchConfig,
+// searching every business.
+func (a *app) resolveBranch(key string) (config.BranchConfig, error) {
+	if _, b, ok := a.cfg.FindBranch(key); ok {
+		return b, nil
+	}
+	return config.BranchConfig{}, fmt.Errorf("unknown branch %q", key)
+}
+
+// resolveBranchKey maps a sync_watermarks / sync_run_branches branch_id as
+// typed on the command line: ALL (or ALL:<businessID>) for business-wide
+// syncs, otherwise a branch name or ID.
+func (a *app) resolveBranchKey(key string) (string, error) {
+	all := repos.WatermarkAllBranches
+	switch {
+	case strings.EqualFold(key, all):
+		return all, nil
+	case len(key) > len(all)+1 && strings.EqualFold(key[:len(all)+1], all+":"):
+		// business-wide key of a non-primary business: ALL:<businessID>
+		return repos.BusinessWideKey(key[len(all)+1:], false), nil
+	}
+	b, err := a.resolveBranch(key)
+	if err != nil {
+		return "", err
+	}
+	return b.BranchID, nil
+}
+
+// resolveBusiness maps a business name or ID to its BusinessConfig.
+func (a *app) resolveBusiness(key string) (config.BusinessConfig, error) {
+	if b, ok := a.cfg.FindBusiness(key); ok {
+		return b, nil
+	}
+	return config.BusinessConfig{}, fmt.Errorf("unknown business %q", key)
+}
+
+// ---------- shared flag types ----------
+
+// listFlag accepts repeated and/or comma-separated values: --branch PK --branch Base,Jakata
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// dateFlag parses YYYY-MM-DD into a UTC date; nil when not set.
+type dateFlag struct {
+	t *time.Time
+}
+
+func (d *dateFlag) String() string {
+	if d.t == nil {
+		return ""
+	}
+	return d.t.Format("2006-01-02")
+}
+
+func (d *dateFlag) Set(v string) error {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("want YYYY-MM-DD: %w", err)
+	}
+	t = t.UTC()
+	d.t = &t
+	return nil
+}
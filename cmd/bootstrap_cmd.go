@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+)
+
+// runBootstrapCmd runs the one-off seeding steps that used to run on every start.
+func runBootstrapCmd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub bootstrap <csv|reviews|watermarks|all>")
+	}
+	step := args[0]
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	runner := a.runner()
+
+	switch step {
+	case "csv":
+		// Clients + transactions from local CSVs (only on fresh DB)
+		return runner.BootstrapFromCSVsIfNeeded()
+	case "reviews":
+		// Reviews from local CSV backups (only on fresh DB)
+		return runner.BootstrapReviewsFromCSVsIfNeeded()
+	case "watermarks":
+		return runner.BootstrapWatermarks()
+	case "all":
+		if err := runner.BootstrapFromCSVsIfNeeded(); err != nil {
+			return fmt.Errorf("CSV bootstrap failed: %w", err)
+		}
+		if err := runner.BootstrapReviewsFromCSVsIfNeeded(); err != nil {
+			return fmt.Errorf("reviews CSV bootstrap failed: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown bootstrap step %q", step)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/fakephorest"
+	"github.com/araquach/phorest-datahub/internal/util"
+)
+
+// runFakePhorestCmd serves the fake Phorest API until SIGINT/SIGTERM. It needs
+// no DB or Phorest credentials; point another datahub at it with
+// PHOREST_BASE_URL=http://<addr>.
+func runFakePhorestCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fake-phorest", flag.ExitOnError)
+
+	addr := fs.String("addr", "127.0.0.1:8089", "listen address")
+	fixtures := fs.String("fixtures", "", "fixture directory (default: built-in fixtures)")
+	quiet := fs.Bool("quiet", false, "don't log every request")
+
+	var f fakephorest.Faults
+	fs.IntVar(&f.FailFirst, "fail-first", 0, "answer the first N API requests with 503")
+	fs.IntVar(&f.RateLimitEvery, "rate-limit-every", 0, "answer every Nth API request with 429")
+	fs.DurationVar(&f.RetryAfter, "retry-after", 0, "Retry-After sent with injected 429s (0 = no header)")
+	fs.IntVar(&f.ErrorEvery, "error-every", 0, "answer every Nth API request with 500")
+	fs.DurationVar(&f.Latency, "latency", 0, "delay added to every API response")
+	fs.IntVar(&f.JobPolls, "job-polls", 1, "polls before a CSV export job reports DONE")
+	fs.BoolVar(&f.FailJobs, "fail-jobs", false, "CSV export jobs end FAILED")
+
+	var p fakephorest.Paging
+	fs.IntVar(&p.MaxPageSize, "max-page-size", 0, "cap page size regardless of the requested size")
+	fs.BoolVar(&p.ExtraEmptyPage, "extra-empty-page", false, "overstate totalPages by one")
+	fs.BoolVar(&p.OverlapPages, "overlap-pages", false, "repeat the previous page's last item at the top of each page")
+	_ = fs.Parse(args)
+
+	logger := util.NewLogger()
+
+	opts := fakephorest.Options{Faults: f, Paging: p}
+	if !*quiet {
+		opts.Logger = logger
+	}
+
+	fixtureFS := fakephorest.DefaultFixtures()
+	if *fixtures != "" {
+		if st, err := os.Stat(*fixtures); err != nil || !st.IsDir() {
+			return fmt.Errorf("fixtures %q is not a directory", *fixtures)
+		}
+		fixtureFS = os.DirFS(*fixtures)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", *addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           fakephorest.New(fixtureFS, opts).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("🧪 fake Phorest listening on http://%s", ln.Addr())
+	logger.Printf("   export PHOREST_BASE_URL=http://%s", ln.Addr())
+	logger.Printf("   control: GET|PUT /_fake/faults, GET /_fake/adjustments, GET /_fake/stats")
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Printf("👋 fake Phorest stopped")
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/fakes3"
+	"github.com/araquach/phorest-datahub/internal/util"
+)
+
+// runFakeS3Cmd serves an in-memory S3 stand-in until SIGINT/SIGTERM, so the
+// S3 archive backend can be tried without a bucket. Objects are lost on exit.
+func runFakeS3Cmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fake-s3", flag.ExitOnError)
+
+	addr := fs.String("addr", "127.0.0.1:9009", "listen address")
+	accessKey := fs.String("access-key", "fake", "access key requests must be signed with")
+	secretKey := fs.String("secret-key", "fakesecret", "secret key requests must be signed with")
+	maxKeys := fs.Int("max-keys", 1000, "cap on keys per ListObjectsV2 page")
+	quiet := fs.Bool("quiet", false, "don't log every request")
+	_ = fs.Parse(args)
+
+	logger := util.NewLogger()
+
+	opts := fakes3.Options{AccessKey: *accessKey, SecretKey: *secretKey, MaxKeys: *maxKeys}
+	if !*quiet {
+		opts.Logger = logger
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", *addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           fakes3.New(opts).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("🧪 fake S3 listening on http://%s", ln.Addr())
+	logger.Printf("   export ARCHIVE_S3_ENDPOINT=http://%s ARCHIVE_S3_BUCKET=datahub", ln.Addr())
+	logger.Printf("   export ARCHIVE_S3_ACCESS_KEY=%s ARCHIVE_S3_SECRET_KEY=%s", *accessKey, *secretKey)
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Printf("👋 fake S3 stopped")
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func runImportsCmd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub imports <list>")
+	}
+
+	switch args[0] {
+	case "list":
+		return runImportsList(args[1:])
+	default:
+		return fmt.Errorf("unknown imports subcommand %q", args[0])
+	}
+}
+
+// runImportsList shows which CSV files the DB was built from (core.import_ledger):
+//
+//	datahub imports list --entity transactions_csv --limit 100
+func runImportsList(args []string) error {
+	fs := flag.NewFlagSet("imports list", flag.ExitOnError)
+	entity := fs.String("entity", "", "transactions_csv | clients_csv | reviews_csv")
+	changed := fs.Bool("changed", false, "only files imported again with different contents")
+	limit := fs.Int("limit", 50, "max files to show")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	rows, err := repos.NewImportLedgerRepo(a.db, a.logger).List(repos.ImportLedgerFilter{
+		Entity:      *entity,
+		ChangedOnly: *changed,
+		Limit:       *limit,
+	})
+	if err != nil {
+		return fmt.Errorf("list imports: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tENTITY\tFILE\tSHA256\tSIZE\tROWS\tREJECTED\tIMPORTED_AT\tRUN\tFLAG")
+	for _, e := range rows {
+		run := "-"
+		if e.RunID != nil {
+			run = strconv.FormatInt(*e.RunID, 10)
+		}
+		note := ""
+		if e.Changed {
+			note = "changed"
+			if e.PreviousSHA256 != nil {
+				note += fmt.Sprintf(" (was %.12s)", *e.PreviousSHA256)
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%.12s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			e.ID, e.Entity, e.FileName, e.SHA256, fmtBytes(e.SizeBytes),
+			e.RowCount, e.RejectedCount, fmtTime(&e.ImportedAt), run, note)
+	}
+	return tw.Flush()
+}
+
+// fmtBytes renders a size as B / KiB / MiB / GiB.
+func fmtBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -2,285 +2,87 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
-	"time"
-
-	"github.com/araquach/phorest-datahub/internal/repos"
-	"github.com/araquach/phorest-datahub/internal/services"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
-
-	"github.com/araquach/phorest-datahub/internal/config"
-	"github.com/araquach/phorest-datahub/internal/db"
-	"github.com/araquach/phorest-datahub/internal/phorest"
 )
 
+const usage = `datahub — Phorest data hub
+
+Usage:
+  datahub <command> [subcommand] [flags]
+
+Commands:
+  sync <entity|all>            run one incremental sync (see "datahub sync -h")
+  serve                        daemon: run every sync on its own schedule (SCHEDULE_<ENTITY>)
+  stock reconcile              reconcile hub product sales into virtual stock transfers
+  stock outbox <list|retry>    queued stock adjustments / re-post failed ones
+  stock exceptions <list|retry|ignore>
+                               sales reconcile couldn't process / re-check or ignore them
+  stock rules <list|add|disable|enable>
+                               which branch's stock each hub's sales come from
+  bootstrap <csv|reviews|watermarks|all>
+                               one-off seeding from archived CSVs (local or S3) / existing data
+  migrate <up|down|version>    manage SQL migrations
+  watermarks <list|history|set|rewind|reset>
+                               show sync_watermarks and their moves / move one (audited, --dry-run)
+  runs <list|show>             sync run history (core.sync_runs)
+  imports list                 CSV files imported into the DB (core.import_ledger)
+  fake-phorest                 serve a local fake Phorest API (point PHOREST_BASE_URL at it)
+  fake-s3                      serve a local in-memory S3 stand-in (point ARCHIVE_S3_ENDPOINT at it)
+
+Flags override the equivalent env vars for that invocation only.
+`
+
 func main() {
 	_ = godotenv.Load()
 
-	cfg := config.Load()
-	logger := cfg.Logger
-
-	if err := os.MkdirAll(cfg.ExportDir, 0o755); err != nil {
-		logger.Fatalf("create export dir %q: %v", cfg.ExportDir, err)
-	}
-	logger.Printf("📂 Using export dir: %s", cfg.ExportDir)
-
-	// -----------------------------
-	// Sandbox-aware DB selection
-	// -----------------------------
-	dsn, err := cfg.ActiveDatabaseURL()
-	if err != nil {
-		logger.Fatalf("database URL resolution failed: %v", err)
-	}
-
-	if cfg.SandboxMode {
-		logger.Println("🧪 SANDBOX MODE ENABLED — using SANDBOX_DATABASE_URL")
-	} else {
-		logger.Println("⚠️  NORMAL MODE — using DATABASE_URL")
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Root context: cancelled on Ctrl-C / SIGTERM so running syncs can stop cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd, args := os.Args[1], os.Args[2:]
+
+	var err error
+	switch cmd {
+	case "sync":
+		err = runSyncCmd(ctx, args)
+	case "serve":
+		err = runServeCmd(ctx, args)
+	case "stock":
+		err = runStockCmd(ctx, args)
+	case "bootstrap":
+		err = runBootstrapCmd(args)
+	case "migrate":
+		err = runMigrateCmd(args)
+	case "watermarks":
+		err = runWatermarksCmd(args)
+	case "runs":
+		err = runRunsCmd(args)
+	case "imports":
+		err = runImportsCmd(args)
+	case "fake-phorest":
+		err = runFakePhorestCmd(ctx, args)
+	case "fake-s3":
+		err = runFakeS3Cmd(ctx, args)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
 	}
 
-	// Optional safety guard (recommended on this feature branch)
-	// if !cfg.SandboxMode && os.Getenv("ALLOW_NON_SANDBOX") != "1" {
-	// 	logger.Fatalf("refusing to run without sandbox: set SANDBOX_MODE=1 or ALLOW_NON_SANDBOX=1")
-	// }
-
-	gdb, err := db.Open(dsn)
 	if err != nil {
-		logger.Fatalf("DB connection failed: %v", err)
-	}
-	defer db.Close(gdb)
-
-	if err := db.HealthCheck(gdb, 3*time.Second); err != nil {
-		logger.Fatalf("DB health check failed: %v", err)
-	}
-	logger.Println("✅ Database connection healthy.")
-
-	if cfg.AutoMigrate {
-		logger.Println("Running SQL migrations...")
-		if err := db.RunMigrations(dsn, "migrations", logger); err != nil {
-			logger.Fatalf("Database migration failed: %v", err)
-		}
-		logger.Println("✅ Database migrated successfully.")
-	}
-
-	for _, b := range cfg.Branches {
-		logger.Printf("Branch: %s (ID: %s)\n", b.Name, b.BranchID)
-	}
-
-	logger.Println("✅ Startup complete. Ready to sync Phorest data.")
-
-	runner := phorest.NewRunner(gdb, cfg, logger)
-
-	// ---------- BOOTSTRAP PHASE ----------
-
-	// Clients + transactions from local CSVs (only on fresh DB)
-	if err := runner.BootstrapFromCSVsIfNeeded(); err != nil {
-		logger.Fatalf("CSV bootstrap failed: %v", err)
-	}
-
-	// Reviews from local CSV backups (only on fresh DB)
-	if err := runner.BootstrapReviewsFromCSVsIfNeeded(); err != nil {
-		logger.Fatalf("Reviews CSV bootstrap failed: %v", err)
-	}
-
-	// ---------- ONGOING “EVERY RUN” API SYNC ----------
-
-	if err := runner.SyncStaffFromAPI(); err != nil {
-		logger.Fatalf("staff sync failed: %v", err)
-	}
-
-	if err := runner.SyncBranchesFromAPI(); err != nil {
-		logger.Printf("branch sync ended with errors: %v", err)
-	}
-
-	// ---------- INCREMENTAL CSV + REVIEWS (ENV-GUARDED) ----------
-
-	// Clients incremental (CLIENT_CSV)
-	if os.Getenv("RUN_CLIENTS_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental CLIENT_CSV sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalClientsSync(ctx); err != nil {
-			logger.Fatalf("CLIENT_CSV incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental CLIENT_CSV sync complete.")
-	}
-
-	// Clients via API (live state)
-	if os.Getenv("RUN_CLIENTS_API_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental CLIENTS_API sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalClientsAPISync(ctx); err != nil {
-			logger.Fatalf("CLIENTS_API incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental CLIENTS_API sync complete.")
-	}
-
-	// Transactions incremental (TRANSACTIONS_CSV)
-	if os.Getenv("RUN_TRANSACTIONS_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental TRANSACTIONS_CSV sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalTransactionsSync(ctx); err != nil {
-			logger.Fatalf("TRANSACTIONS_CSV incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental TRANSACTIONS_CSV sync complete.")
-	}
-
-	// Appointments incremental
-	if os.Getenv("RUN_APPOINTMENTS_API_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental APPOINTMENTS_API sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalAppointmentsAPISync(ctx); err != nil {
-			logger.Fatalf("APPOINTMENTS_API incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental APPOINTMENTS_API sync complete.")
-	}
-
-	// Reviews incremental
-	if os.Getenv("RUN_REVIEWS_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental REVIEWS sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalReviewsSync(ctx); err != nil {
-			logger.Fatalf("REVIEWS incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental REVIEWS sync complete.")
-	}
-
-	// Staff work timetables incremental
-	if os.Getenv("RUN_WORKTIMETABLE_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental WORKTIMETABLE sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalStaffWorkTimetableSync(ctx); err != nil {
-			logger.Fatalf("WORKTIMETABLE incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental WORKTIMETABLE sync complete.")
-	}
-
-	if os.Getenv("RUN_PRODUCTS_SYNC") == "1" {
-		logger.Println("🚀 Running PRODUCTS sync…")
-		if err := runner.SyncProductsFromAPI(); err != nil {
-			logger.Fatalf("products sync failed: %v", err)
-		}
-		logger.Println("✅ PRODUCTS sync complete.")
-	}
-
-	if os.Getenv("RUN_STOCK_RECONCILE_DRY_RUN") == "1" {
-		logger.Println("🧪 Running STOCK reconcile (dry-run)…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
-
-		sqlDB, err := gdb.DB()
-		if err != nil {
-			logger.Fatalf("failed to get raw sql DB: %v", err)
-		}
-
-		repo := repos.StockReconcileRepo{DB: sqlDB}
-
-		svc := services.StockReconcileService{
-			Repo:       repo,
-			PKBranchID: os.Getenv("SITE_2_BRANCH_ID"),
-			DryRun:     true,
-
-			// FIXED start date — no historical processing before this
-			FromTS: time.Date(2026, time.January, 01, 0, 0, 0, 0, time.UTC),
-			ToTS:   time.Now().UTC(),
-
-			Limit: 500,
-
-			TestBarcode: os.Getenv("STOCK_RECONCILE_TEST_BARCODE"),
-
-			MaxPreview: 25,
-			PrintJSON:  os.Getenv("STOCK_RECONCILE_PRINT_JSON") == "1",
-		}
-
-		if err := svc.Run(ctx); err != nil {
-			logger.Fatalf("stock reconcile dry-run failed: %v", err)
-		}
-
-		logger.Println("✅ STOCK reconcile (dry-run) complete.")
-	}
-
-	if os.Getenv("RUN_STOCK_RECONCILE_LIVE") == "1" {
-		logger.Println("🚨 Running STOCK reconcile (LIVE)…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
-
-		sqlDB, err := gdb.DB()
-		if err != nil {
-			logger.Fatalf("failed to get raw sql DB: %v", err)
-		}
-
-		repo := repos.StockReconcileRepo{DB: sqlDB}
-
-		adjuster := phorest.NewStockAdjuster(
-			"https://api-gateway-eu.phorest.com/third-party-api-server",
-			cfg.PhorestBusiness,
-			cfg.PhorestUsername,
-			cfg.PhorestPassword,
-		)
-
-		svc := services.StockReconcileService{
-			Repo:       repo,
-			Adjuster:   adjuster,
-			PKBranchID: os.Getenv("SITE_2_BRANCH_ID"),
-			DryRun:     false,
-
-			FromTS: time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC),
-			ToTS:   time.Now().UTC(),
-
-			Limit: 500,
-
-			// Leave unset for full live:
-			TestBarcode: os.Getenv("STOCK_RECONCILE_TEST_BARCODE"),
-
-			MaxPreview: 25,
-			PrintJSON:  os.Getenv("STOCK_RECONCILE_PRINT_JSON") == "1",
-		}
-
-		if err := svc.Run(ctx); err != nil {
-			logger.Fatalf("stock reconcile LIVE failed: %v", err)
-		}
-
-		logger.Println("✅ STOCK reconcile (LIVE) complete.")
-	}
-
-	// Breaks incremental
-	if os.Getenv("RUN_BREAKS_API_INCREMENTAL") == "1" {
-		logger.Println("🚀 Running incremental BREAKS_API sync…")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-		defer cancel()
-
-		if err := runner.RunIncrementalBreaksAPISync(ctx); err != nil {
-			logger.Fatalf("BREAKS_API incremental sync failed: %v", err)
-		}
-
-		logger.Println("✅ Incremental BREAKS_API sync complete.")
+		fmt.Fprintf(os.Stderr, "❌ %s: %v\n", cmd, err)
+		os.Exit(1)
 	}
 }
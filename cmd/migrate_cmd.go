@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/araquach/phorest-datahub/internal/db"
+)
+
+func runMigrateCmd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub migrate <up|down|version> [flags]")
+	}
+	sub := args[0]
+
+	fs := flag.NewFlagSet("migrate "+sub, flag.ExitOnError)
+	dir := fs.String("dir", "migrations", "migrations directory")
+	steps := fs.Int("steps", 1, "number of migrations to roll back (down only)")
+	_ = fs.Parse(args[1:])
+
+	cfg, dsn, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	logger := cfg.Logger
+
+	switch sub {
+	case "up":
+		return db.RunMigrations(dsn, *dir, logger)
+	case "down":
+		return db.RollbackMigrations(dsn, *dir, *steps, logger)
+	case "version":
+		v, dirty, err := db.MigrationVersion(dsn, *dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version=%d dirty=%v\n", v, dirty)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", sub)
+	}
+}
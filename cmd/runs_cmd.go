@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func runRunsCmd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub runs <list|show>")
+	}
+
+	switch args[0] {
+	case "list":
+		return runRunsList(args[1:])
+	case "show":
+		return runRunsShow(args[1:])
+	default:
+		return fmt.Errorf("unknown runs subcommand %q", args[0])
+	}
+}
+
+// runRunsList answers questions like "when did PK transactions last succeed?":
+//
+//	datahub runs list --entity transactions_csv --branch PK --status success --limit 1
+func runRunsList(args []string) error {
+	fs := flag.NewFlagSet("runs list", flag.ExitOnError)
+	entity := fs.String("entity", "", "watermark entity name (e.g. transactions_csv, appointments_api)")
+	branch := fs.String("branch", "", "branch ID or name; ALL for business-wide syncs (ALL:<businessID> for any business but the first)")
+	status := fs.String("status", "", "running | success | failed | skipped")
+	limit := fs.Int("limit", 20, "max runs to show")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	filter := repos.SyncRunFilter{
+		Entity: *entity,
+		Status: *status,
+		Limit:  *limit,
+	}
+	if *branch != "" {
+		if filter.BranchID, err = a.resolveBranchKey(*branch); err != nil {
+			return err
+		}
+	}
+
+	rows, err := repos.NewSyncRunsRepo(a.db, a.logger).List(filter)
+	if err != nil {
+		return fmt.Errorf("list runs: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tENTITY\tSTATUS\tSTARTED_AT\tDURATION\tFETCHED\tUPSERTED\tERROR")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			r.ID, r.Entity, r.Status, fmtTime(&r.StartedAt), fmtDuration(r.StartedAt, r.FinishedAt),
+			r.RowsFetched, r.RowsUpserted, fmtErr(r.Error, 60))
+	}
+	return tw.Flush()
+}
+
+func runRunsShow(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: datahub runs show <id>")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid run id %q", args[0])
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	run, err := repos.NewSyncRunsRepo(a.db, a.logger).Get(id)
+	if err != nil {
+		return fmt.Errorf("get run %d: %w", id, err)
+	}
+	if run == nil {
+		return fmt.Errorf("run %d not found", id)
+	}
+
+	fmt.Printf("Run %d  %s  %s\n", run.ID, run.Entity, run.Status)
+	fmt.Printf("  started:  %s\n", fmtTime(&run.StartedAt))
+	fmt.Printf("  finished: %s (%s)\n", fmtTime(run.FinishedAt), fmtDuration(run.StartedAt, run.FinishedAt))
+	fmt.Printf("  rows:     %d fetched, %d upserted\n", run.RowsFetched, run.RowsUpserted)
+	if run.Error != nil {
+		fmt.Printf("  error:    %s\n", *run.Error)
+	}
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BRANCH\tSTATUS\tDURATION\tWINDOW_FROM\tWINDOW_TO\tFETCHED\tUPSERTED\tWM_BEFORE\tWM_AFTER\tERROR")
+	for _, b := range run.Branches {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			b.BranchID, b.Status, fmtDuration(b.StartedAt, b.FinishedAt),
+			fmtTime(b.WindowFrom), fmtTime(b.WindowTo),
+			b.RowsFetched, b.RowsUpserted,
+			fmtTime(b.WatermarkBefore), fmtTime(b.WatermarkAfter),
+			fmtErr(b.Error, 0))
+	}
+	return tw.Flush()
+}
+
+func fmtTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func fmtDuration(start time.Time, end *time.Time) string {
+	if end == nil {
+		return "-"
+	}
+	return end.Sub(start).Round(time.Second).String()
+}
+
+// fmtErr flattens an error for table output, truncating to max runes (0 = no limit).
+func fmtErr(s *string, max int) string {
+	if s == nil {
+		return ""
+	}
+	msg := strings.Join(strings.Fields(*s), " ")
+	if r := []rune(msg); max > 0 && len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return msg
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/phorest"
+	"github.com/araquach/phorest-datahub/internal/scheduler"
+)
+
+// runServeCmd keeps the DB pool open and runs every sync on its own schedule
+// until SIGINT/SIGTERM. Schedules come from SCHEDULE_<ENTITY> env vars
+// (e.g. SCHEDULE_APPOINTMENTS="*/15 * * * *", SCHEDULE_CLIENTS_CSV=off),
+// falling back to each job's DefaultSchedule.
+func runServeCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	var only listFlag
+	fs.Var(&only, "only", "only schedule these entities (repeatable / comma-separated)")
+	jitter := fs.Duration("jitter", envDuration("SCHEDULE_JITTER", 30*time.Second), "max random delay added to each tick")
+	lockWait := fs.Duration("lock-wait", envDuration("LOCK_WAIT", 0), "wait this long for another process syncing the same entity/branch before skipping it (env LOCK_WAIT)")
+	_ = fs.Parse(args)
+
+	for _, name := range only {
+		if _, ok := phorest.FindSyncJob(name); !ok {
+			return fmt.Errorf("unknown entity %q", name)
+		}
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	runner := a.runner()
+	runner.Opts.LockWait = *lockWait
+	sched := scheduler.New(a.logger)
+
+	scheduled := 0
+	for _, j := range phorest.SyncJobs() {
+		if len(only) > 0 && !contains(only, j.Name) {
+			continue
+		}
+
+		envKey := "SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(j.Name, "-", "_"))
+		spec := strings.TrimSpace(os.Getenv(envKey))
+		if spec == "" {
+			spec = j.DefaultSchedule
+		}
+		if spec == "" || strings.EqualFold(spec, "off") {
+			a.logger.Printf("⏭  serve: %s disabled (%s=off)", j.Name, envKey)
+			continue
+		}
+
+		s, err := scheduler.ParseSchedule(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %w", envKey, err)
+		}
+
+		job := j
+		sched.Add(scheduler.Job{
+			Name:     job.Name,
+			Schedule: s,
+			Jitter:   *jitter,
+			Timeout:  job.Timeout,
+			Run: func(ctx context.Context) error {
+				return job.Run(runner, ctx)
+			},
+		})
+		a.logger.Printf("🗓  serve: %s scheduled %q (timeout %s)", job.Name, spec, job.Timeout)
+		scheduled++
+	}
+
+	if scheduled == 0 {
+		return fmt.Errorf("no jobs scheduled")
+	}
+
+	a.logger.Printf("✅ serve: %d jobs scheduled (jitter ≤ %s). Ctrl-C / SIGTERM to stop.", scheduled, *jitter)
+	sched.Run(ctx)
+
+	// Jobs overlap in serve, so per-job deltas would be misleading; log the total.
+	a.logger.Printf("📊 serve: Phorest HTTP totals %s", phorest.SharedHTTPStats())
+	return nil
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/config"
+	"github.com/araquach/phorest-datahub/internal/db"
+	"github.com/araquach/phorest-datahub/internal/phorest"
+	"github.com/araquach/phorest-datahub/internal/repos"
+	"github.com/araquach/phorest-datahub/internal/services"
+)
+
+// defaultStockReconcileFrom is the fixed cut-over date — no historical
+// processing before this unless --from says otherwise.
+var defaultStockReconcileFrom = time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC)
+
+func runStockCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub stock <reconcile|outbox|exceptions|rules> [flags]")
+	}
+
+	switch args[0] {
+	case "reconcile":
+		return runStockReconcile(ctx, args[1:])
+	case "outbox":
+		return runStockOutbox(ctx, args[1:])
+	case "exceptions":
+		return runStockExceptions(ctx, args[1:])
+	case "rules":
+		return runStockRules(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown stock subcommand %q", args[0])
+	}
+}
+
+func runStockReconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock reconcile", flag.ExitOnError)
+
+	var from, to dateFlag
+	dryRun := fs.Bool("dry-run", false, "log the payloads only (no Phorest calls, no DB marks)")
+	var businesses listFlag
+	fs.Var(&businesses, "business", "only these businesses, name or ID (repeatable / comma-separated); default all")
+	hub := fs.String("hub", "", "hub branch name or ID (default: each business's stock_hub branch and branches with stock source rules)")
+	fs.Var(&from, "from", "only items updated on/after YYYY-MM-DD (default 2026-01-16 or STOCK_RECONCILE_FROM_DATE; hubs made by stock source rules: when the first rule was added)")
+	fs.Var(&to, "to", "only items updated before YYYY-MM-DD (default now)")
+	limit := fs.Int("limit", 500, "rows per batch")
+	barcode := fs.String("barcode", os.Getenv("STOCK_RECONCILE_TEST_BARCODE"), "only process this barcode")
+	maxPreview := fs.Int("max-preview", 25, "stock lines to preview per payload")
+	printJSON := fs.Bool("print-json", os.Getenv("STOCK_RECONCILE_PRINT_JSON") == "1", "print full JSON payloads")
+	timeout := fs.Duration("timeout", 10*time.Minute, "overall timeout")
+	lockWait := fs.Duration("lock-wait", envDuration("LOCK_WAIT", 0), "wait this long for a concurrent live run to finish before skipping (env LOCK_WAIT)")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	hubs, err := stockHubs(ctx, a, repos.StockSourceRuleRepo{DB: sqlDB}, *hub, businesses, *dryRun)
+	if err != nil {
+		return err
+	}
+	if from.t != nil {
+		// An explicit --from backfills rules-made hubs too.
+		for i := range hubs {
+			hubs[i].From = time.Time{}
+		}
+	}
+
+	fromTS := defaultStockReconcileFrom
+	if env := os.Getenv("STOCK_RECONCILE_FROM_DATE"); env != "" {
+		t, err := time.Parse("2006-01-02", env)
+		if err != nil {
+			return fmt.Errorf("invalid STOCK_RECONCILE_FROM_DATE (want YYYY-MM-DD): %w", err)
+		}
+		fromTS = t.UTC()
+	}
+	if from.t != nil {
+		fromTS = *from.t
+	}
+	toTS := time.Now().UTC()
+	if to.t != nil {
+		toTS = *to.t
+	}
+
+	svc := services.StockReconcileService{
+		Repo:        repos.StockReconcileRepo{DB: sqlDB},
+		Logger:      a.logger,
+		Hubs:        hubs,
+		DryRun:      *dryRun,
+		FromTS:      fromTS,
+		ToTS:        toTS,
+		Limit:       *limit,
+		TestBarcode: *barcode,
+		MaxPreview:  *maxPreview,
+		PrintJSON:   *printJSON,
+		LockWait:    *lockWait,
+	}
+
+	if *dryRun {
+		a.logger.Println("🧪 Running STOCK reconcile (dry-run)…")
+	} else {
+		a.logger.Println("🚨 Running STOCK reconcile (LIVE)…")
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if err := svc.Run(runCtx); errors.Is(err, db.ErrLockHeld) {
+		a.logger.Printf("⏭  STOCK reconcile skipped: %v", err)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("stock reconcile failed (dry-run=%v): %w", *dryRun, err)
+	}
+
+	a.logger.Printf("✅ STOCK reconcile complete (dry-run=%v).", *dryRun)
+	return nil
+}
+
+// stockHubs picks the hub(s) to reconcile: the --hub branch if given,
+// otherwise the stock_hub branch of every (selected) business plus any of
+// its branches with active stock source rules. A branch that is a hub only
+// through rules starts from when its first active rule was created, so
+// adding one doesn't replay the branch's sales since the cut-over date.
+// Live runs get an adjuster bound to the hub's own business.
+func stockHubs(ctx context.Context, a *app, rules repos.StockSourceRuleRepo, hubKey string, businesses []string, dryRun bool) ([]services.StockHub, error) {
+	var picked []config.BusinessConfig
+	var hubBranches []config.BranchConfig
+
+	ruleBranches, err := rules.SellingBranches(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("stock source rules: %w", err)
+	}
+
+	if hubKey != "" {
+		biz, br, ok := a.cfg.FindBranch(hubKey)
+		if !ok {
+			return nil, fmt.Errorf("hub branch: unknown branch %q", hubKey)
+		}
+		picked, hubBranches = append(picked, biz), append(hubBranches, br)
+	} else {
+		for _, b := range a.cfg.Businesses {
+			if len(businesses) > 0 && !slices.ContainsFunc(businesses, func(k string) bool {
+				return strings.EqualFold(k, b.BusinessID) || strings.EqualFold(k, b.Name)
+			}) {
+				continue
+			}
+			found := false
+			for _, br := range b.Branches {
+				if _, hasRules := ruleBranches[br.BranchID]; br.Role == config.RoleStockHub || hasRules {
+					picked, hubBranches = append(picked, b), append(hubBranches, br)
+					found = true
+				}
+			}
+			if !found {
+				a.logger.Printf("ℹ️  %s has no %s branch or stock source rules, skipping", b.Name, config.RoleStockHub)
+			}
+		}
+		var unknown []string
+		for id := range ruleBranches {
+			if _, _, ok := a.cfg.FindBranch(id); !ok {
+				unknown = append(unknown, id)
+			}
+		}
+		slices.Sort(unknown)
+		for _, id := range unknown {
+			a.logger.Printf("⚠️  stock source rules for unknown branch %s, skipping", id)
+		}
+		if len(picked) == 0 {
+			return nil, fmt.Errorf("no stock hub to reconcile (give a branch role %q, add stock source rules, or pass --hub)", config.RoleStockHub)
+		}
+	}
+
+	hubs := make([]services.StockHub, 0, len(picked))
+	for i, b := range picked {
+		br := hubBranches[i]
+		h := services.StockHub{BusinessID: b.BusinessID, BranchID: br.BranchID}
+		if since, ok := ruleBranches[br.BranchID]; ok && br.Role != config.RoleStockHub {
+			h.From = since.UTC()
+		}
+		if !dryRun {
+			h.Adjuster = phorest.NewStockAdjuster(b.BaseURL, b.BusinessID, b.Username, b.Password)
+		}
+		hubs = append(hubs, h)
+	}
+	return hubs, nil
+}
+
+// stockBranchFilter resolves a --hub flag to a branch ID; "" stays "" (all).
+func stockBranchFilter(a *app, key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	b, err := a.resolveBranch(key)
+	if err != nil {
+		return "", fmt.Errorf("hub branch: %w", err)
+	}
+	return b.BranchID, nil
+}
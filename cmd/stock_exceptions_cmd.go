@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func runStockExceptions(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub stock exceptions <list|retry|ignore> [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		return runStockExceptionsList(ctx, args[1:])
+	case "retry":
+		return runStockExceptionsRetry(ctx, args[1:])
+	case "ignore":
+		return runStockExceptionsIgnore(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown stock exceptions subcommand %q", args[0])
+	}
+}
+
+// runStockExceptionsList shows items stock reconcile couldn't process
+// (core.stock_virtual_transfer_exceptions):
+//
+//	datahub stock exceptions list --status open --reason UNMAPPED_STAFF
+func runStockExceptionsList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock exceptions list", flag.ExitOnError)
+	status := fs.String("status", "", "open | resolved | ignored (default all)")
+	reason := fs.String("reason", "", "MISSING_BARCODE | UNMAPPED_STAFF")
+	hub := fs.String("hub", "", "only sales at this branch, name or ID")
+	limit := fs.Int("limit", 50, "max rows to show")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	hubID, err := stockBranchFilter(a, *hub)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockExceptionRepo{DB: sqlDB}
+	rows, err := repo.List(ctx, repos.StockExceptionFilter{
+		HubBranchID: hubID,
+		Status:      *status,
+		Reason:      *reason,
+		Limit:       *limit,
+	})
+	if err != nil {
+		return fmt.Errorf("list stock exceptions: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ITEM_ID\tREASON\tSTATUS\tPURCHASED_AT\tBARCODE\tPRODUCT\tSTAFF\tCREATED_AT\tCHECKED_AT\tNOTE")
+	for _, e := range rows {
+		purchased := "-"
+		if e.PurchasedAt.Valid {
+			purchased = fmtTime(&e.PurchasedAt.Time)
+		}
+		staff := strings.TrimSpace(e.StaffFirstName.String + " " + e.StaffLastName.String)
+		if staff == "" {
+			staff = e.StaffID
+		}
+		var note *string
+		if e.Note.Valid {
+			note = &e.Note.String
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.TransactionItemID, e.Reason, e.Status, purchased, e.ProductBarcode,
+			fmtErr(&e.ProductName.String, 40), staff, fmtTime(&e.CreatedAt), fmtTime(&e.CheckedAt), fmtErr(note, 40))
+	}
+	return tw.Flush()
+}
+
+// runStockExceptionsRetry re-checks exceptions against the current staff
+// overrides and products now, changed or not. Fixed ones are resolved and
+// go through the next stock reconcile as normal transfers:
+//
+//	datahub stock exceptions retry                       # every open exception
+//	datahub stock exceptions retry --reason UNMAPPED_STAFF
+//	datahub stock exceptions retry --id ITEM1,ITEM2      # also un-ignores these
+func runStockExceptionsRetry(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock exceptions retry", flag.ExitOnError)
+	var ids listFlag
+	fs.Var(&ids, "id", "transaction item IDs (repeatable / comma-separated); default every open exception")
+	reason := fs.String("reason", "", "only MISSING_BARCODE | UNMAPPED_STAFF")
+	hub := fs.String("hub", "", "only sales at this branch, name or ID")
+	dryRun := fs.Bool("dry-run", false, "only report what would be resolved")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	hubID, err := stockBranchFilter(a, *hub)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockExceptionRepo{DB: sqlDB}
+	res, err := repo.Reevaluate(ctx, repos.StockExceptionFilter{
+		HubBranchID: hubID,
+		Reason:      *reason,
+		ItemIDs:     ids,
+	}, true, !*dryRun)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		a.logger.Printf("🧪 Would resolve %d exception(s); %d still open.", res.Resolved, res.StillOpen)
+		return nil
+	}
+	a.logger.Printf("✅ Resolved %d exception(s); %d still open.", res.Resolved, res.StillOpen)
+	if res.Resolved > 0 {
+		a.logger.Println("ℹ️  Resolved items are processed by the next `datahub stock reconcile`.")
+	}
+	return nil
+}
+
+// runStockExceptionsIgnore stops reconcile from ever processing these items:
+//
+//	datahub stock exceptions ignore --id ITEM1 --note "staff left, stock written off"
+func runStockExceptionsIgnore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock exceptions ignore", flag.ExitOnError)
+	var ids listFlag
+	fs.Var(&ids, "id", "transaction item IDs (repeatable / comma-separated)")
+	note := fs.String("note", "", "why they're ignored")
+	_ = fs.Parse(args)
+
+	if len(ids) == 0 {
+		return fmt.Errorf("stock exceptions ignore: --id is required")
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockExceptionRepo{DB: sqlDB}
+	n, err := repo.Ignore(ctx, ids, *note)
+	if err != nil {
+		return err
+	}
+	if skipped := int64(len(ids)) - n; skipped > 0 {
+		a.logger.Printf("ℹ️  %d item(s) were not open exceptions and were left alone.", skipped)
+	}
+	a.logger.Printf("✅ Ignored %d exception(s).", n)
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/araquach/phorest-datahub/internal/phorest"
+	"github.com/araquach/phorest-datahub/internal/repos"
+	"github.com/araquach/phorest-datahub/internal/services"
+)
+
+func runStockOutbox(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub stock outbox <list|retry> [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		return runStockOutboxList(ctx, args[1:])
+	case "retry":
+		return runStockOutboxRetry(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown stock outbox subcommand %q", args[0])
+	}
+}
+
+// runStockOutboxList shows queued stock adjustments (core.stock_adjustment_outbox):
+//
+//	datahub stock outbox list --status failed
+func runStockOutboxList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock outbox list", flag.ExitOnError)
+	status := fs.String("status", "", "pending | sending | sent | failed (default all)")
+	hub := fs.String("hub", "", "only this hub branch, name or ID")
+	limit := fs.Int("limit", 50, "max rows to show")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	hubID, err := stockBranchFilter(a, *hub)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockAdjustmentOutboxRepo{DB: sqlDB}
+	rows, err := repo.List(ctx, repos.StockAdjustmentOutboxFilter{
+		HubBranchID: hubID,
+		Status:      *status,
+		Limit:       *limit,
+	})
+	if err != nil {
+		return fmt.Errorf("list stock outbox: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tHUB\tBRANCH\tOP\tBARCODE\tQTY\tITEMS\tSTATUS\tATTEMPTS\tCREATED_AT\tSENT_AT\tERROR")
+	for _, o := range rows {
+		var lastErr *string
+		if o.LastError.Valid {
+			lastErr = &o.LastError.String
+		}
+		sent := "-"
+		if o.SentAt.Valid {
+			sent = fmtTime(&o.SentAt.Time)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%d\t%s\t%s\t%s\n",
+			o.ID, o.HubBranchID, o.BranchID, o.Operation, o.Barcode, o.Quantity,
+			len(o.SourceItemIDs), o.Status, o.Attempts, fmtTime(&o.CreatedAt), sent, fmtErr(lastErr, 60))
+	}
+	return tw.Flush()
+}
+
+// runStockOutboxRetry puts failed adjustments back to pending and posts them:
+//
+//	datahub stock outbox retry                # every failed row
+//	datahub stock outbox retry --hub PK       # failed rows of one hub
+//	datahub stock outbox retry --id 41,42     # these rows, even if stuck in 'sending'
+//
+// Rows stuck in 'sending' may or may not have reached Phorest (we died
+// mid-post, or the post timed out or lost its connection), so they're only
+// retried by ID, once someone has checked.
+func runStockOutboxRetry(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock outbox retry", flag.ExitOnError)
+	var ids listFlag
+	fs.Var(&ids, "id", "outbox row IDs (repeatable / comma-separated); default every failed row")
+	hub := fs.String("hub", "", "only this hub branch, name or ID")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	hubID, err := stockBranchFilter(a, *hub)
+	if err != nil {
+		return err
+	}
+	var rowIDs []int64
+	for _, s := range ids {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --id %q", s)
+		}
+		rowIDs = append(rowIDs, id)
+	}
+	if len(rowIDs) > 0 && hubID != "" {
+		return fmt.Errorf("--id and --hub are mutually exclusive")
+	}
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+	repo := repos.StockAdjustmentOutboxRepo{DB: sqlDB}
+
+	byHub, err := repo.Requeue(ctx, rowIDs, hubID)
+	if err != nil {
+		return err
+	}
+	if len(byHub) == 0 {
+		a.logger.Println("ℹ️  Nothing to retry.")
+		return nil
+	}
+
+	hubIDs := make([]string, 0, len(byHub))
+	for h := range byHub {
+		hubIDs = append(hubIDs, h)
+	}
+	sort.Strings(hubIDs)
+
+	var failed []string
+	for _, h := range hubIDs {
+		a.logger.Printf("🔁 Retrying %d outbox row(s) for hub %s…", byHub[h], h)
+		biz, _, ok := a.cfg.FindBranch(h)
+		if !ok {
+			failed = append(failed, h+": not a configured branch (rows left pending)")
+			continue
+		}
+		d := services.StockAdjustmentDispatcher{
+			Repo:     repo,
+			Adjuster: phorest.NewStockAdjuster(biz.BaseURL, biz.BusinessID, biz.Username, biz.Password),
+			Logger:   a.logger,
+		}
+		res, err := d.Dispatch(ctx, h)
+		a.logger.Printf("   hub %s: sent=%d failed=%d unknown=%d skipped=%d", h, res.Sent, res.Failed, res.Unknown, res.Skipped)
+		if err != nil {
+			failed = append(failed, h+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("stock outbox retry: %s", strings.Join(failed, "; "))
+	}
+	a.logger.Println("✅ Stock outbox retry complete.")
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/araquach/phorest-datahub/internal/config"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func runStockRules(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub stock rules <list|add|disable|enable> [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		return runStockRulesList(ctx, args[1:])
+	case "add":
+		return runStockRulesAdd(ctx, args[1:])
+	case "disable":
+		return runStockRulesSetActive(ctx, args[1:], false)
+	case "enable":
+		return runStockRulesSetActive(ctx, args[1:], true)
+	default:
+		return fmt.Errorf("unknown stock rules subcommand %q", args[0])
+	}
+}
+
+// runStockRulesList shows the stock source rules (core.stock_source_rules)
+// in the order reconcile tries them. To see what they'd do to real sales,
+// run `datahub stock reconcile --dry-run`.
+func runStockRulesList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock rules list", flag.ExitOnError)
+	branch := fs.String("branch", "", "only rules of this selling branch, name or ID")
+	all := fs.Bool("all", false, "include disabled rules")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	branchID, err := stockBranchFilter(a, *branch)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockSourceRuleRepo{DB: sqlDB}
+	rules, err := repo.List(ctx, branchID, !*all)
+	if err != nil {
+		return fmt.Errorf("list stock source rules: %w", err)
+	}
+
+	orAny := func(s string) string {
+		if s == "" {
+			return "*"
+		}
+		return s
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSELLING_BRANCH\tPRIORITY\tCATEGORY\tBRAND\tSTAFF\tSOURCE\tSOURCE_BRANCH\tACTIVE\tUPDATED_AT\tNOTE")
+	for _, r := range rules {
+		source := "-"
+		if r.SourceBranchID != "" {
+			source = branchLabel(a, r.SourceBranchID)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%v\t%s\t%s\n",
+			r.ID, branchLabel(a, r.SellingBranchID), r.Priority, orAny(r.ProductCategory), orAny(r.ProductBrand),
+			orAny(r.StaffID), r.Source, source, r.Active, fmtTime(&r.UpdatedAt), fmtErr(&r.Note, 40))
+	}
+	return tw.Flush()
+}
+
+// runStockRulesAdd adds a rule:
+//
+//	datahub stock rules add --selling-branch PK --category Colour --source BRANCH --source-branch Base
+//	datahub stock rules add --selling-branch Jakata --source STAFF_OVERRIDE     # make Jakata a hub from now on
+//	datahub stock rules add --selling-branch PK --staff <id> --source SELLING_BRANCH --priority 10
+func runStockRulesAdd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stock rules add", flag.ExitOnError)
+	selling := fs.String("selling-branch", "", "branch whose sales the rule applies to, name or ID (required)")
+	priority := fs.Int("priority", 100, "lower is tried first")
+	category := fs.String("category", "", "only products of this category, ID or name")
+	brand := fs.String("brand", "", "only products of this brand, ID or name")
+	staff := fs.String("staff", "", "only sales by this staff ID")
+	source := fs.String("source", "", "STAFF_OVERRIDE | BRANCH | SELLING_BRANCH (required)")
+	sourceBranch := fs.String("source-branch", "", "branch to deduct from, name or ID (for --source BRANCH)")
+	note := fs.String("note", "", "why the rule exists")
+	_ = fs.Parse(args)
+
+	rule := repos.StockSourceRule{
+		Priority:        *priority,
+		ProductCategory: strings.TrimSpace(*category),
+		ProductBrand:    strings.TrimSpace(*brand),
+		StaffID:         strings.TrimSpace(*staff),
+		Source:          strings.ToUpper(strings.TrimSpace(*source)),
+		Note:            *note,
+	}
+	switch rule.Source {
+	case repos.SourceBranch:
+		if *sourceBranch == "" {
+			return fmt.Errorf("--source BRANCH needs --source-branch")
+		}
+	case repos.SourceStaffOverride, repos.SourceSellingBranch:
+		if *sourceBranch != "" {
+			return fmt.Errorf("--source-branch only goes with --source BRANCH")
+		}
+	default:
+		return fmt.Errorf("--source must be STAFF_OVERRIDE, BRANCH or SELLING_BRANCH")
+	}
+	if *selling == "" {
+		return fmt.Errorf("--selling-branch is required")
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	sellBiz, sellBr, ok := a.cfg.FindBranch(*selling)
+	if !ok {
+		return fmt.Errorf("selling branch: unknown branch %q", *selling)
+	}
+	rule.SellingBranchID = sellBr.BranchID
+	if rule.Source == repos.SourceBranch {
+		srcBiz, srcBr, ok := a.cfg.FindBranch(*sourceBranch)
+		if !ok {
+			return fmt.Errorf("source branch: unknown branch %q", *sourceBranch)
+		}
+		// Adjustments are posted with the selling branch's business credentials.
+		if srcBiz.BusinessID != sellBiz.BusinessID {
+			return fmt.Errorf("source branch %s is in a different business from %s", srcBr.Name, sellBr.Name)
+		}
+		rule.SourceBranchID = srcBr.BranchID
+	}
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockSourceRuleRepo{DB: sqlDB}
+	ruleBranches, err := repo.SellingBranches(ctx)
+	if err != nil {
+		return fmt.Errorf("stock source rules: %w", err)
+	}
+	_, hadRules := ruleBranches[rule.SellingBranchID]
+
+	id, err := repo.Add(ctx, rule)
+	if err != nil {
+		return err
+	}
+	a.logger.Printf("✅ Added stock source rule #%d for %s. Preview it with `datahub stock reconcile --hub %s --dry-run`.",
+		id, sellBr.Name, sellBr.Name)
+	if !hadRules && sellBr.Role != config.RoleStockHub {
+		a.logger.Printf("⚠️  %s is now a stock hub: reconcile will move stock for its sales from now on "+
+			"(earlier sales only with an explicit --from).", sellBr.Name)
+	}
+	return nil
+}
+
+// runStockRulesSetActive disables or re-enables rules by ID.
+func runStockRulesSetActive(ctx context.Context, args []string, active bool) error {
+	verb := "disable"
+	if active {
+		verb = "enable"
+	}
+	fs := flag.NewFlagSet("stock rules "+verb, flag.ExitOnError)
+	var ids listFlag
+	fs.Var(&ids, "id", "rule IDs (repeatable / comma-separated)")
+	_ = fs.Parse(args)
+
+	if len(ids) == 0 {
+		return fmt.Errorf("stock rules %s: --id is required", verb)
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	sqlDB, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get raw sql DB: %w", err)
+	}
+
+	repo := repos.StockSourceRuleRepo{DB: sqlDB}
+	for _, s := range ids {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --id %q", s)
+		}
+		if err := repo.SetActive(ctx, id, active); err != nil {
+			return err
+		}
+		a.logger.Printf("✅ Stock source rule #%d %sd.", id, verb)
+	}
+	return nil
+}
+
+// branchLabel shows a branch ID with its configured name, if any.
+func branchLabel(a *app, branchID string) string {
+	if _, b, ok := a.cfg.FindBranch(branchID); ok && b.Name != "" {
+		return b.Name + " (" + branchID + ")"
+	}
+	return branchID
+}
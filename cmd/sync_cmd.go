@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/phorest"
+)
+
+func runSyncCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+
+	var (
+		businesses listFlag
+		branches   listFlag
+		from       dateFlag
+		to         dateFlag
+	)
+	fs.Var(&businesses, "business", "business name or ID (repeatable / comma-separated); default all")
+	fs.Var(&branches, "branch", "branch name or ID (repeatable / comma-separated); default all")
+	fs.Var(&from, "from", "start date YYYY-MM-DD (overrides *_FROM_DATE)")
+	fs.Var(&to, "to", "end date YYYY-MM-DD (overrides *_TO_DATE)")
+	ignoreWM := fs.Bool("ignore-watermark", false, "backfill mode where supported (no updated_from, no watermark update)")
+	timeout := fs.Duration("timeout", 0, "overall timeout per sync (default: per-entity)")
+	lockWait := fs.Duration("lock-wait", envDuration("LOCK_WAIT", 0), "wait this long for another process syncing the same entity/branch before skipping it (env LOCK_WAIT)")
+
+	fs.Usage = func() {
+		names := make([]string, 0, len(phorest.SyncJobs()))
+		for _, j := range phorest.SyncJobs() {
+			names = append(names, j.Name)
+		}
+		fmt.Fprintf(os.Stderr, "Usage: datahub sync <entity|all> [flags]\n\nEntities: %s\n\nFlags:\n", strings.Join(names, ", "))
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		return fmt.Errorf("missing entity")
+	}
+	entity := args[0]
+	_ = fs.Parse(args[1:])
+
+	var jobs []phorest.SyncJob
+	if entity == "all" {
+		jobs = phorest.SyncJobs()
+	} else {
+		j, ok := phorest.FindSyncJob(entity)
+		if !ok {
+			fs.Usage()
+			return fmt.Errorf("unknown entity %q", entity)
+		}
+		jobs = []phorest.SyncJob{j}
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	for _, b := range businesses {
+		if _, err := a.resolveBusiness(b); err != nil {
+			return err
+		}
+	}
+	for _, b := range branches {
+		if _, err := a.resolveBranch(b); err != nil {
+			return err
+		}
+	}
+
+	runner := a.runner()
+	runner.Opts = phorest.SyncOptions{
+		Businesses:      businesses,
+		Branches:        branches,
+		FromDate:        from.t,
+		ToDate:          to.t,
+		IgnoreWatermark: *ignoreWM,
+		LockWait:        *lockWait,
+	}
+
+	for _, j := range jobs {
+		if err := runSyncJob(ctx, a, runner, j, *timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSyncJob(ctx context.Context, a *app, runner *phorest.Runner, j phorest.SyncJob, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = j.Timeout
+	}
+
+	a.logger.Printf("🚀 Running %s sync…", j.Label)
+
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	before := phorest.SharedHTTPStats()
+	defer func() {
+		a.logger.Printf("📊 %s: Phorest HTTP %s", j.Label, phorest.SharedHTTPStats().Sub(before))
+	}()
+
+	if err := j.Run(runner, jobCtx); err != nil {
+		return fmt.Errorf("%s sync failed: %w", j.Label, err)
+	}
+
+	a.logger.Printf("✅ %s sync complete.", j.Label)
+	return nil
+}
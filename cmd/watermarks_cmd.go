@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/phorest"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func runWatermarksCmd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: datahub watermarks <list|history|set|rewind|reset>")
+	}
+
+	switch args[0] {
+	case "list":
+		return runWatermarksList(args[1:])
+	case "history":
+		return runWatermarksHistory(args[1:])
+	case "set":
+		return runWatermarksSet(args[1:])
+	case "rewind":
+		return runWatermarksRewind(args[1:])
+	case "reset":
+		return runWatermarksReset(args[1:])
+	default:
+		return fmt.Errorf("unknown watermarks subcommand %q", args[0])
+	}
+}
+
+func runWatermarksList(args []string) error {
+	fs := flag.NewFlagSet("watermarks list", flag.ExitOnError)
+	entity := fs.String("entity", "", "only this entity (e.g. transactions_csv)")
+	branch := fs.String("branch", "", "only this branch ID or name; ALL (or ALL:<businessID>) for business-wide syncs")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	branchID := ""
+	if *branch != "" {
+		if branchID, err = a.resolveBranchKey(*branch); err != nil {
+			return err
+		}
+	}
+
+	rows, err := repos.NewWatermarksRepo(a.db, a.logger).List()
+	if err != nil {
+		return fmt.Errorf("list watermarks: %w", err)
+	}
+
+	now := time.Now().UTC()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTITY\tBRANCH\tNAME\tLAST_UPDATED_PHOREST\tLAG\tUPDATED_AT")
+	for _, wm := range rows {
+		branch := wm.Branch()
+		if (*entity != "" && wm.Entity != *entity) || (branchID != "" && branch != branchID) {
+			continue
+		}
+		name := ""
+		if _, b, ok := a.cfg.FindBranch(branch); ok {
+			name = b.Name
+		}
+		lag := "-"
+		if wm.LastUpdatedPhorest != nil {
+			lag = fmtLag(now.Sub(*wm.LastUpdatedPhorest))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			wm.Entity, branch, name, fmtTime(wm.LastUpdatedPhorest), lag, fmtTime(&wm.UpdatedAt))
+	}
+	return tw.Flush()
+}
+
+// runWatermarksHistory shows how watermarks moved, e.g. every suspicious move:
+//
+//	datahub watermarks history --flagged
+func runWatermarksHistory(args []string) error {
+	fs := flag.NewFlagSet("watermarks history", flag.ExitOnError)
+	entity := fs.String("entity", "", "only this entity (e.g. transactions_csv)")
+	branch := fs.String("branch", "", "only this branch ID or name; ALL (or ALL:<businessID>) for business-wide syncs")
+	flagged := fs.Bool("flagged", false, "only moves into the future or over WATERMARK_MAX_JUMP")
+	limit := fs.Int("limit", 50, "max moves to show")
+	_ = fs.Parse(args)
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	filter := repos.WatermarkHistoryFilter{Entity: repos.WatermarkEntity(*entity), FlaggedOnly: *flagged, Limit: *limit}
+	if *branch != "" {
+		if filter.BranchID, err = a.resolveBranchKey(*branch); err != nil {
+			return err
+		}
+	}
+
+	rows, err := repos.NewWatermarksRepo(a.db, a.logger).History(filter)
+	if err != nil {
+		return fmt.Errorf("watermark history: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AT\tENTITY\tBRANCH\tOLD\tNEW\tJUMP\tSOURCE\tRUN\tFLAGS")
+	for _, h := range rows {
+		jump := "-"
+		if h.JumpSeconds != nil {
+			jump = fmtLag(time.Duration(*h.JumpSeconds) * time.Second)
+		}
+		run := "-"
+		if h.RunID != nil {
+			run = strconv.FormatInt(*h.RunID, 10)
+		}
+		var flags []string
+		if h.FlagFuture {
+			flags = append(flags, "🚩future")
+		}
+		if h.FlagJump {
+			flags = append(flags, "🚩jump")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			fmtTime(&h.CreatedAt), h.Entity, h.BranchID, fmtTime(h.OldValue), fmtTime(h.NewValue),
+			jump, h.Source, run, strings.Join(flags, ","))
+	}
+	return tw.Flush()
+}
+
+// runWatermarksSet moves a watermark to an explicit value, forwards or back:
+//
+//	datahub watermarks set --entity transactions_csv --branch PK --to 2025-01-01 --reason "bad import"
+func runWatermarksSet(args []string) error {
+	fs := flag.NewFlagSet("watermarks set", flag.ExitOnError)
+	wf := addWatermarkChangeFlags(fs)
+	to := fs.String("to", "", "new value: RFC3339 timestamp or YYYY-MM-DD (midnight UTC)")
+	_ = fs.Parse(args)
+
+	if *to == "" {
+		return fmt.Errorf("--to is required")
+	}
+	value, err := parseWatermarkValue(*to)
+	if err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+
+	return wf.apply(repos.WatermarkActionSet, func(old *time.Time) (*time.Time, error) {
+		return &value, nil
+	})
+}
+
+// runWatermarksRewind moves an existing watermark back by a duration:
+//
+//	datahub watermarks rewind --entity appointments_api --branch Base --by 3d
+func runWatermarksRewind(args []string) error {
+	fs := flag.NewFlagSet("watermarks rewind", flag.ExitOnError)
+	wf := addWatermarkChangeFlags(fs)
+	by := fs.String("by", "", "how far back, e.g. 36h or 7d")
+	_ = fs.Parse(args)
+
+	if *by == "" {
+		return fmt.Errorf("--by is required")
+	}
+	d, err := parseLag(*by)
+	if err != nil {
+		return fmt.Errorf("--by: %w", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("--by must be positive")
+	}
+
+	return wf.apply(repos.WatermarkActionRewind, func(old *time.Time) (*time.Time, error) {
+		if old == nil {
+			return nil, fmt.Errorf("no watermark to rewind; use \"watermarks set\"")
+		}
+		v := old.UTC().Add(-d)
+		return &v, nil
+	})
+}
+
+// runWatermarksReset deletes a watermark so the next run starts from scratch:
+//
+//	datahub watermarks reset --entity clients_api --branch ALL
+func runWatermarksReset(args []string) error {
+	fs := flag.NewFlagSet("watermarks reset", flag.ExitOnError)
+	wf := addWatermarkChangeFlags(fs)
+	_ = fs.Parse(args)
+
+	return wf.apply(repos.WatermarkActionReset, func(old *time.Time) (*time.Time, error) {
+		if old == nil {
+			return nil, fmt.Errorf("no watermark to reset")
+		}
+		return nil, nil
+	})
+}
+
+// watermarkChangeFlags are shared by set / rewind / reset.
+type watermarkChangeFlags struct {
+	entity, branch, reason, actor *string
+	yes, dryRun                   *bool
+}
+
+func addWatermarkChangeFlags(fs *flag.FlagSet) *watermarkChangeFlags {
+	return &watermarkChangeFlags{
+		entity: fs.String("entity", "", "watermark entity: "+repos.JoinWatermarkEntities(", ")),
+		branch: fs.String("branch", "", "branch ID or name; ALL (or ALL:<businessID>) for business-wide syncs"),
+		reason: fs.String("reason", "", "why, for the audit log"),
+		actor:  fs.String("actor", defaultActor(), "who, for the audit log"),
+		yes:    fs.Bool("yes", false, "don't ask for confirmation"),
+		dryRun: fs.Bool("dry-run", false, "show the change and the next run's window, change nothing"),
+	}
+}
+
+// apply resolves the target row, works out its new value with next (nil =
+// delete the row), shows the effect on the next incremental run and, unless
+// --dry-run, asks for confirmation and writes the change plus its audit row.
+func (wf *watermarkChangeFlags) apply(action string, next func(old *time.Time) (*time.Time, error)) error {
+	if *wf.entity == "" || *wf.branch == "" {
+		return fmt.Errorf("--entity and --branch are required")
+	}
+	entity, err := repos.ParseWatermarkEntity(*wf.entity)
+	if err != nil {
+		return err
+	}
+
+	a, err := openApp()
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	branchID, err := a.resolveBranchKey(*wf.branch)
+	if err != nil {
+		return err
+	}
+
+	wr := repos.NewWatermarksRepo(a.db, a.logger)
+	cur, err := wr.Get(entity, branchID)
+	if err != nil {
+		return fmt.Errorf("get watermark: %w", err)
+	}
+	var old *time.Time
+	if cur != nil {
+		old = cur.LastUpdatedPhorest
+	}
+
+	value, err := next(old)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	backwards := old != nil && value != nil && value.Before(*old)
+
+	fmt.Printf("%s %s/%s\n", action, entity, branchID)
+	fmt.Printf("  current:        %s\n", fmtTime(old))
+	fmt.Printf("  new:            %s\n", fmtTime(value))
+	fmt.Printf("  next run now:   %s\n", phorest.NextWindowFor(entity, old, now))
+	fmt.Printf("  next run after: %s\n", phorest.NextWindowFor(entity, value, now))
+	if value != nil && value.After(now) {
+		fmt.Println("  ⚠️  new value is in the future: the next runs will skip everything until then")
+	}
+
+	if *wf.dryRun {
+		fmt.Println("🧪 dry run: nothing changed")
+		return nil
+	}
+	if old != nil && value != nil && old.Equal(*value) {
+		fmt.Println("ℹ️ unchanged")
+		return nil
+	}
+
+	if !*wf.yes {
+		prompt := "Apply this change?"
+		switch {
+		case value == nil:
+			prompt = "This deletes the watermark; the next run starts from scratch. Apply?"
+		case backwards:
+			prompt = "This moves the watermark BACK; the next run will re-fetch that range. Apply?"
+		}
+		if !confirm(prompt) {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	audit := repos.WatermarkAudit{Action: action, Actor: *wf.actor, Reason: *wf.reason}
+	if value == nil {
+		_, err = wr.Reset(entity, branchID, audit)
+	} else {
+		_, err = wr.SetLastUpdated(entity, branchID, *value, audit)
+	}
+	if err != nil {
+		return fmt.Errorf("%s watermark: %w", action, err)
+	}
+
+	fmt.Printf("✅ %s/%s: %s → %s\n", entity, branchID, fmtTime(old), fmtTime(value))
+	return nil
+}
+
+// confirm asks a yes/no question on stdin; anything but y/yes (including
+// EOF, e.g. under cron) is a no.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// defaultActor is user@host of whoever runs the command.
+func defaultActor() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
+
+// parseWatermarkValue accepts RFC3339 or YYYY-MM-DD (midnight UTC).
+func parseWatermarkValue(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want RFC3339 or YYYY-MM-DD, got %q", s)
+	}
+	return t.UTC(), nil
+}
+
+// parseLag is time.ParseDuration plus a whole-day "Nd" form.
+func parseLag(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("want e.g. 36h or 7d, got %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// fmtLag renders how far behind now a watermark is, e.g. "3d4h" or "12m".
+func fmtLag(d time.Duration) string {
+	if d < 0 {
+		return "-" + fmtLag(-d) + " (future)"
+	}
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	mins := (d - hours*time.Hour) / time.Minute
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, mins)
+	default:
+		return fmt.Sprintf("%dm", mins)
+	}
+}
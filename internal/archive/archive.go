@@ -0,0 +1,369 @@
+// Package archive keeps the CSV exports a rebuild is bootstrapped from,
+// compressed and content-addressed, in a Storage (a local directory or an
+// S3-compatible bucket):
+//
+//	<kind>/<YYYY>/<MM>/<DD>/<sha256[:16]>_<name>.csv.gz (or .zst)
+//
+// The date is the day the file was archived and the hash covers the
+// uncompressed contents, so the same export is only ever stored once.
+// Readers also accept the flat, uncompressed <kind>/*.csv files older
+// versions wrote.
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is how archived files are compressed.
+type Codec string
+
+const (
+	Gzip Codec = "gzip"
+	Zstd Codec = "zstd"
+)
+
+// ParseCodec validates a codec name ("" = gzip).
+func ParseCodec(s string) (Codec, error) {
+	switch Codec(strings.ToLower(strings.TrimSpace(s))) {
+	case "", Gzip, "gz":
+		return Gzip, nil
+	case Zstd, "zst":
+		return Zstd, nil
+	}
+	return "", fmt.Errorf("unknown archive codec %q (want gzip or zstd)", s)
+}
+
+func (c Codec) ext() string {
+	if c == Zstd {
+		return ".zst"
+	}
+	return ".gz"
+}
+
+// hashPrefix is how many hex digits of the SHA-256 go in a file name.
+const hashPrefix = 16
+
+// Archive stores files in Store. Retention > 0 prunes dated entries older
+// than that after every Put; 0 keeps everything.
+type Archive struct {
+	Store     Storage
+	Codec     Codec
+	Retention time.Duration
+
+	lg *log.Logger
+}
+
+func New(store Storage, codec Codec, retention time.Duration, lg *log.Logger) *Archive {
+	return &Archive{Store: store, Codec: codec, Retention: retention, lg: lg}
+}
+
+// Put compresses the local file srcPath into today's directory for kind and
+// returns its key. If a file with the same contents is already archived for
+// kind, that key is returned and nothing is written.
+func (a *Archive) Put(ctx context.Context, kind, srcPath string) (string, error) {
+	sum, err := SHA256(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("archive %s: %w", srcPath, err)
+	}
+
+	keys, err := a.Store.List(ctx, kind+"/")
+	if err != nil {
+		return "", fmt.Errorf("archive %s: list %s: %w", srcPath, kind, err)
+	}
+	for _, key := range keys {
+		if _, ok := datedDay(kind, key); ok && isCSV(key) && strings.HasPrefix(path.Base(key), sum[:hashPrefix]+"_") {
+			a.lg.Printf("📦 %s already archived as %s", filepath.Base(srcPath), a.Store.Location(key))
+			return key, nil
+		}
+	}
+
+	now := time.Now().UTC()
+	key := path.Join(kind, now.Format("2006/01/02"), sum[:hashPrefix]+"_"+filepath.Base(srcPath)+a.Codec.ext())
+
+	tmp, size, err := a.compress(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("archive %s: %w", srcPath, err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if err := a.Store.Put(ctx, key, tmp, size); err != nil {
+		return "", fmt.Errorf("archive %s: %w", srcPath, err)
+	}
+	a.lg.Printf("📦 Archived %s → %s", srcPath, a.Store.Location(key))
+
+	if a.Retention > 0 {
+		if _, err := a.prune(ctx, kind, keys, now); err != nil {
+			a.lg.Printf("⚠️ archive: prune %s: %v", kind, err)
+		}
+	}
+	return key, nil
+}
+
+// compress writes src through the archive's codec into a temp file and
+// returns it rewound, with its size.
+func (a *Archive) compress(src string) (*os.File, int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp("", "datahub-archive-*"+a.Codec.ext())
+	if err != nil {
+		return nil, 0, err
+	}
+	fail := func(err error) (*os.File, int64, error) {
+		_ = out.Close()
+		_ = os.Remove(out.Name())
+		return nil, 0, err
+	}
+
+	var w io.WriteCloser
+	switch a.Codec {
+	case Zstd:
+		if w, err = zstd.NewWriter(out); err != nil {
+			return fail(err)
+		}
+	default:
+		w = gzip.NewWriter(out)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return fail(err)
+	}
+	if err := w.Close(); err != nil {
+		return fail(err)
+	}
+	size, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return out, size, nil
+}
+
+// List returns the key of every CSV of kind, compressed or not, oldest
+// layout first: legacy flat files, then dated entries in date order.
+func (a *Archive) List(ctx context.Context, kind string) ([]string, error) {
+	keys, err := a.Store.List(ctx, kind+"/")
+	if err != nil {
+		return nil, err
+	}
+	out := keys[:0]
+	for _, key := range keys {
+		if isCSV(key) {
+			out = append(out, key)
+		}
+	}
+	slices.SortStableFunc(out, func(x, y string) int {
+		if dx, dy := strings.Count(x, "/"), strings.Count(y, "/"); dx != dy {
+			return dx - dy
+		}
+		return strings.Compare(x, y)
+	})
+	return out, nil
+}
+
+// Location describes where kind is kept, for logs.
+func (a *Archive) Location(kind string) string { return a.Store.Location(kind + "/") }
+
+// Fetch makes key readable as a local file and returns its path. The file
+// keeps the key's base name, so Open and OriginalName work on it. done
+// removes any temporary copy and must be called once the caller is finished.
+func (a *Archive) Fetch(ctx context.Context, key string) (localPath string, done func(), err error) {
+	if l, ok := a.Store.(*LocalStorage); ok {
+		return l.path(key), func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "datahub-fetch-")
+	if err != nil {
+		return "", nil, err
+	}
+	done = func() { _ = os.RemoveAll(dir) }
+
+	rc, err := a.Store.Get(ctx, key)
+	if err != nil {
+		done()
+		return "", nil, fmt.Errorf("fetch %s: %w", a.Store.Location(key), err)
+	}
+	defer rc.Close()
+
+	localPath = filepath.Join(dir, path.Base(key))
+	f, err := os.Create(localPath)
+	if err != nil {
+		done()
+		return "", nil, err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		_ = f.Close()
+		done()
+		return "", nil, fmt.Errorf("fetch %s: %w", a.Store.Location(key), err)
+	}
+	if err := f.Close(); err != nil {
+		done()
+		return "", nil, err
+	}
+	return localPath, done, nil
+}
+
+// Prune deletes dated entries of kind archived more than Retention before
+// now and returns how many went. Legacy flat files are never pruned.
+func (a *Archive) Prune(ctx context.Context, kind string, now time.Time) (int, error) {
+	if a.Retention <= 0 {
+		return 0, nil
+	}
+	keys, err := a.Store.List(ctx, kind+"/")
+	if err != nil {
+		return 0, err
+	}
+	return a.prune(ctx, kind, keys, now)
+}
+
+func (a *Archive) prune(ctx context.Context, kind string, keys []string, now time.Time) (int, error) {
+	cutoff := now.UTC().Add(-a.Retention)
+
+	n := 0
+	for _, key := range keys {
+		day, ok := datedDay(kind, key)
+		if !ok || !day.AddDate(0, 0, 1).Before(cutoff) {
+			continue
+		}
+		if err := a.Store.Delete(ctx, key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if n > 0 {
+		a.lg.Printf("🧹 archive: pruned %d %s file(s) older than %s", n, kind, a.Retention)
+	}
+	return n, nil
+}
+
+// datedDay parses the archive day out of a <kind>/YYYY/MM/DD/<file> key.
+func datedDay(kind, key string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(key, kind+"/")
+	if !ok || strings.Count(rest, "/") != 3 {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("2006/01/02", path.Dir(rest))
+	return day, err == nil
+}
+
+// isCSV reports whether path is a CSV the readers understand.
+func isCSV(path string) bool {
+	p := strings.ToLower(path)
+	return strings.HasSuffix(p, ".csv") || strings.HasSuffix(p, ".csv.gz") || strings.HasSuffix(p, ".csv.zst")
+}
+
+// OriginalName is the file name path was archived from: the hash prefix
+// and compression extension are dropped, so
+// "2a1f…_clients_2024.csv.gz" gives "clients_2024.csv". Other names come
+// back as their base name.
+func OriginalName(path string) string {
+	name := filepath.Base(path)
+	if !isCSV(name) {
+		return name
+	}
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".gz", ".zst"} {
+		if strings.HasSuffix(lower, ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	if prefix, rest, ok := strings.Cut(name, "_"); ok && len(prefix) == hashPrefix && isHex(prefix) {
+		name = rest
+	}
+	return name
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Open opens path for reading, decompressing .gz / .zst transparently.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := NewReader(f, path)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &readCloser{Reader: rc, close: func() error {
+		err := rc.Close()
+		if ferr := f.Close(); err == nil {
+			err = ferr
+		}
+		return err
+	}}, nil
+}
+
+// NewReader decompresses r according to name's extension. Closing the
+// result does not close r.
+func NewReader(r io.Reader, name string) (io.ReadCloser, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz":
+		return gzip.NewReader(r)
+	case ".zst":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &readCloser{Reader: d, close: func() error { d.Close(); return nil }}, nil
+	}
+	return io.NopCloser(r), nil
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r *readCloser) Close() error { return r.close() }
+
+// SHA256 returns the hex SHA-256 of path's uncompressed contents.
+func SHA256(path string) (string, error) {
+	sum, _, err := SHA256Size(path)
+	return sum, err
+}
+
+// SHA256Size is SHA256 plus the uncompressed size in bytes.
+func SHA256Size(path string) (string, int64, error) {
+	rc, err := Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/fakes3"
+)
+
+// Runs an Archive on S3Storage against the in-memory fake, which checks
+// every request's SigV4 signature.
+func TestS3StorageAgainstFake(t *testing.T) {
+	ctx := context.Background()
+	fake := fakes3.New(fakes3.Options{MaxKeys: 2}) // force List to page
+	srv := fake.Start()
+	defer srv.Close()
+
+	cfg := S3Config{Endpoint: srv.URL, Bucket: "seed", Prefix: "datahub", AccessKey: "fake", SecretKey: "fakesecret"}
+	store, err := NewS3Storage(cfg, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	arc := New(store, Gzip, 30*24*time.Hour, log.New(io.Discard, "", 0))
+
+	const contents = "id,name\n1,Ann\n"
+	src := filepath.Join(t.TempDir(), "clients 2024+v2.csv") // needs escaping in the signed path
+	if err := os.WriteFile(src, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := arc.Put(ctx, "clients", src)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if again, err := arc.Put(ctx, "clients", src); err != nil || again != key {
+		t.Fatalf("put again = %q, %v; want the existing key %q", again, err, key)
+	}
+
+	put := func(key, body string) {
+		t.Helper()
+		if err := store.Put(ctx, key, strings.NewReader(body), int64(len(body))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	const old = "clients/2020/01/01/0123456789abcdef_old.csv.gz"
+	put("clients/legacy.csv", contents)
+	put(old, "")
+	put("clients/notes.txt", "not a CSV")
+	put("reviews/r.csv", contents)
+
+	if got := fake.Keys("seed"); len(got) != 5 || !strings.HasPrefix(got[0], "datahub/") {
+		t.Fatalf("bucket keys = %v, want 5 keys under datahub/", got)
+	}
+
+	keys, err := arc.List(ctx, "clients")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if want := []string{"clients/legacy.csv", old, key}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("list = %v, want %v", keys, want)
+	}
+
+	local, done, err := arc.Fetch(ctx, key)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	defer done()
+	if got := OriginalName(local); got != filepath.Base(src) {
+		t.Errorf("fetched name = %q, want %q", got, filepath.Base(src))
+	}
+	rc, err := Open(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil || string(got) != contents {
+		t.Fatalf("fetched contents = %q, %v; want %q", got, err, contents)
+	}
+
+	if n, err := arc.Prune(ctx, "clients", time.Now()); err != nil || n != 1 {
+		t.Fatalf("prune = %d, %v; want 1", n, err)
+	}
+	if keys, err = arc.List(ctx, "clients"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"clients/legacy.csv", key}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("list after prune = %v, want %v", keys, want)
+	}
+
+	if err := store.Delete(ctx, "clients/missing.csv"); err != nil {
+		t.Errorf("delete of a missing key: %v", err)
+	}
+	if _, err := store.Get(ctx, "clients/missing.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("get of a missing key = %v, want fs.ErrNotExist", err)
+	}
+
+	cfg.SecretKey = "wrong"
+	bad, err := NewS3Storage(cfg, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bad.List(ctx, "clients/"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("list with a wrong secret = %v, want a signature error", err)
+	}
+}
@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage is where archived files live. Keys are slash-separated and
+// relative to the storage root, e.g. "transactions/2024/05/01/<file>".
+type Storage interface {
+	// Put stores size bytes from r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens key; a missing key gives an error wrapping fs.ErrNotExist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns every key under prefix, in no particular order. An
+	// empty or missing prefix is not an error.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes key; a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Location renders key for logs (a path or an s3:// URL).
+	Location(key string) string
+}
+
+// LocalStorage keeps files under a directory.
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+func (l *LocalStorage) path(key string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(key))
+}
+
+func (l *LocalStorage) Location(key string) string { return l.path(key) }
+
+// Put writes through a temp file in the target directory, so a crash never
+// leaves a truncated file behind.
+func (l *LocalStorage) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	dst := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (l *LocalStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(l.path(key))
+}
+
+func (l *LocalStorage) List(_ context.Context, prefix string) ([]string, error) {
+	// Walk the deepest directory the prefix names, then filter.
+	dir := prefix
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	root := l.path(dir)
+
+	var keys []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", root, err)
+	}
+	return keys, nil
+}
+
+// Delete also drops the directories above key (up to Root) once empty.
+func (l *LocalStorage) Delete(_ context.Context, key string) error {
+	p := l.path(key)
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	root := filepath.Clean(l.Root)
+	for d := filepath.Dir(p); d != root && d != "." && d != string(filepath.Separator); d = filepath.Dir(d) {
+		if os.Remove(d) != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -4,15 +4,62 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/araquach/phorest-datahub/internal/util"
 )
 
+// Phorest API regions. The business lives in exactly one of these; the
+// third-party API is served from that region's gateway.
+const (
+	RegionEU = "eu"
+	RegionUS = "us"
+)
+
+// phorestRegionURLs are the third-party API roots per region (without /api).
+var phorestRegionURLs = map[string]string{
+	RegionEU: "https://api-gateway-eu.phorest.com/third-party-api-server",
+	RegionUS: "https://api-gateway-us.phorest.com/third-party-api-server",
+}
+
+// DefaultPhorestBaseURL is the EU gateway, which is where we've always been.
+var DefaultPhorestBaseURL = phorestRegionURLs[RegionEU]
+
+// Branch roles. A branch with no role is an ordinary salon.
+const (
+	// RoleStockHub is the branch that physically holds retail stock for the
+	// whole business; stock reconcile moves sales stock into it.
+	RoleStockHub = "stock_hub"
+)
+
 // BranchConfig holds the name + ID of each branch.
 type BranchConfig struct {
 	Name     string
 	BranchID string
+	Role     string // "" or RoleStockHub
+}
+
+// BusinessConfig is one Phorest business: its own credentials, gateway and
+// branches. Branch IDs are unique across businesses.
+type BusinessConfig struct {
+	Name       string
+	BusinessID string
+	Username   string
+	Password   string
+	Region     string
+	BaseURL    string // resolved API root, see PhorestBaseURL
+	Branches   []BranchConfig
+}
+
+// StockHub returns the business's RoleStockHub branch, if it has one.
+func (b BusinessConfig) StockHub() (BranchConfig, bool) {
+	for _, br := range b.Branches {
+		if br.Role == RoleStockHub {
+			return br, true
+		}
+	}
+	return BranchConfig{}, false
 }
 
 // Config centralises all environment and runtime configuration.
@@ -21,17 +68,58 @@ type Config struct {
 	DatabaseURL        string
 	SandboxDatabaseURL string
 	SandboxMode        bool
-	PhorestUsername    string
-	PhorestPassword    string
-	PhorestBusiness    string
 
-	Branches  []BranchConfig
+	// Businesses lists every configured Phorest business, from the config
+	// file (DATAHUB_CONFIG or ./datahub.{yaml,yml,toml}) or, without one,
+	// the single business described by the env vars. ConfigFile is the file
+	// used, "" for env-only.
+	Businesses []BusinessConfig
+	ConfigFile string
+
+	// The fields below describe the *current* business: Businesses[0] after
+	// Load, another one via ForBusiness. Clients and syncs read these.
+	BusinessName    string
+	PhorestUsername string
+	PhorestPassword string
+	PhorestBusiness string
+
+	// PhorestRegion picks a preset gateway (PHOREST_REGION, default "eu");
+	// PhorestBaseURL is the resolved API root every client uses, overridable
+	// with PHOREST_BASE_URL (e.g. a local fake server for testing).
+	PhorestRegion  string
+	PhorestBaseURL string
+
+	Branches []BranchConfig
+
 	ExportDir string
 
+	// Archive is where synced CSVs are kept for rebuilding a DB from scratch.
+	Archive ArchiveConfig
+
 	AutoMigrate bool
 }
 
+// ArchiveConfig picks the archive storage: the local Dir, or an
+// S3-compatible bucket when S3Bucket is set (ARCHIVE_S3_*).
+type ArchiveConfig struct {
+	Dir string // ARCHIVE_DIR, default "data"
+
+	S3Endpoint  string // e.g. https://s3.eu-west-2.amazonaws.com, http://127.0.0.1:9000
+	S3Bucket    string
+	S3Prefix    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+// UsesS3 reports whether archives go to object storage.
+func (a ArchiveConfig) UsesS3() bool { return a.S3Bucket != "" }
+
 // Load builds the Config struct, validating critical env vars.
+//
+// Businesses come from the config file when there is one; env vars then
+// override the first business (PHOREST_*, SITE_<n>_*). Without a file the
+// env vars alone describe a single business, as they always have.
 func Load() *Config {
 	logger := util.NewLogger()
 	logger.Println("Loading environment configuration...")
@@ -41,32 +129,151 @@ func Load() *Config {
 		DatabaseURL:        getEnvOrFail(logger, "DATABASE_URL"),
 		SandboxDatabaseURL: os.Getenv("SANDBOX_DATABASE_URL"),
 		SandboxMode:        parseBoolEnv(os.Getenv("SANDBOX_MODE")),
-		PhorestUsername:    getEnvOrFail(logger, "PHOREST_USERNAME"),
-		PhorestPassword:    getEnvOrFail(logger, "PHOREST_PASSWORD"),
-		PhorestBusiness:    getEnvOrFail(logger, "PHOREST_BUSINESS"),
 		AutoMigrate:        os.Getenv("AUTO_MIGRATE") == "1",
 		ExportDir:          getEnvOrDefault("EXPORT_DIR", "data/exports"),
-		Branches: []BranchConfig{
-			{
-				Name:     getEnvOrDefault("SITE_1_NAME", "Jakata"),
-				BranchID: getEnvOrFail(logger, "SITE_1_BRANCH_ID"),
-			},
-			{
-				Name:     getEnvOrDefault("SITE_2_NAME", "PK"),
-				BranchID: getEnvOrFail(logger, "SITE_2_BRANCH_ID"),
-			},
-			{
-				Name:     getEnvOrDefault("SITE_3_NAME", "Base"),
-				BranchID: getEnvOrFail(logger, "SITE_3_BRANCH_ID"),
-			},
+		Archive: ArchiveConfig{
+			Dir:         getEnvOrDefault("ARCHIVE_DIR", "data"),
+			S3Endpoint:  os.Getenv("ARCHIVE_S3_ENDPOINT"),
+			S3Bucket:    os.Getenv("ARCHIVE_S3_BUCKET"),
+			S3Prefix:    os.Getenv("ARCHIVE_S3_PREFIX"),
+			S3Region:    os.Getenv("ARCHIVE_S3_REGION"),
+			S3AccessKey: os.Getenv("ARCHIVE_S3_ACCESS_KEY"),
+			S3SecretKey: os.Getenv("ARCHIVE_S3_SECRET_KEY"),
 		},
 	}
 
-	logger.Printf("✅ Loaded config for %d branches\n", len(cfg.Branches))
+	if path := configFilePath(); path != "" {
+		businesses, err := loadBusinessesFile(path)
+		if err != nil {
+			logger.Fatalf("❌ %v", err)
+		}
+		if len(businesses) > 0 {
+			applyEnvOverrides(&businesses[0])
+		}
+		cfg.ConfigFile = path
+		cfg.Businesses = businesses
+		logger.Printf("📄 Config file: %s", path)
+	} else {
+		cfg.Businesses = []BusinessConfig{businessFromEnv(logger)}
+	}
+
+	if err := validateBusinesses(cfg.Businesses); err != nil {
+		logger.Fatalf("❌ %v", err)
+	}
+	cfg.useBusiness(cfg.Businesses[0])
+
+	branches := 0
+	for _, b := range cfg.Businesses {
+		branches += len(b.Branches)
+		logger.Printf("🏢 %s (%s): %d branches, Phorest API %s", b.Name, b.BusinessID, len(b.Branches), b.BaseURL)
+	}
+	logger.Printf("✅ Loaded config for %d businesses, %d branches\n", len(cfg.Businesses), branches)
 	logger.Printf("📁 ExportDir: %s", cfg.ExportDir)
+	if cfg.Archive.UsesS3() {
+		logger.Printf("🗄️  Archive: s3://%s/%s at %s", cfg.Archive.S3Bucket, cfg.Archive.S3Prefix, cfg.Archive.S3Endpoint)
+	} else {
+		logger.Printf("🗄️  Archive: %s", cfg.Archive.Dir)
+	}
 	return cfg
 }
 
+// ForBusiness returns a copy of c whose current-business fields describe b.
+func (c *Config) ForBusiness(b BusinessConfig) *Config {
+	cp := *c
+	cp.useBusiness(b)
+	return &cp
+}
+
+func (c *Config) useBusiness(b BusinessConfig) {
+	c.BusinessName = b.Name
+	c.PhorestBusiness = b.BusinessID
+	c.PhorestUsername = b.Username
+	c.PhorestPassword = b.Password
+	c.PhorestRegion = b.Region
+	c.PhorestBaseURL = b.BaseURL
+	c.Branches = b.Branches
+}
+
+// IsPrimaryBusiness reports whether the current business is the first
+// configured one (the only one before multi-business support).
+func (c *Config) IsPrimaryBusiness() bool {
+	return len(c.Businesses) == 0 || c.Businesses[0].BusinessID == c.PhorestBusiness
+}
+
+// FindBranch looks a branch up by name or ID across every business.
+func (c *Config) FindBranch(key string) (BusinessConfig, BranchConfig, bool) {
+	for _, b := range c.Businesses {
+		for _, br := range b.Branches {
+			if strings.EqualFold(key, br.BranchID) || strings.EqualFold(key, br.Name) {
+				return b, br, true
+			}
+		}
+	}
+	return BusinessConfig{}, BranchConfig{}, false
+}
+
+// FindBusiness looks a business up by name or ID.
+func (c *Config) FindBusiness(key string) (BusinessConfig, bool) {
+	for _, b := range c.Businesses {
+		if strings.EqualFold(key, b.BusinessID) || strings.EqualFold(key, b.Name) {
+			return b, true
+		}
+	}
+	return BusinessConfig{}, false
+}
+
+// businessFromEnv is the original env-only config: one business, three
+// required sites (SITE_2 being the stock hub), optionally SITE_4.. on top.
+func businessFromEnv(logger *log.Logger) BusinessConfig {
+	b := BusinessConfig{
+		BusinessID: getEnvOrFail(logger, "PHOREST_BUSINESS"),
+		Username:   getEnvOrFail(logger, "PHOREST_USERNAME"),
+		Password:   getEnvOrFail(logger, "PHOREST_PASSWORD"),
+		Branches: []BranchConfig{
+			{Name: "Jakata", BranchID: getEnvOrFail(logger, "SITE_1_BRANCH_ID")},
+			{Name: "PK", BranchID: getEnvOrFail(logger, "SITE_2_BRANCH_ID"), Role: RoleStockHub},
+			{Name: "Base", BranchID: getEnvOrFail(logger, "SITE_3_BRANCH_ID")},
+		},
+	}
+	applyEnvOverrides(&b)
+	return b
+}
+
+// applyEnvOverrides lets PHOREST_* and SITE_<n>_{BRANCH_ID,NAME,ROLE}
+// override b field by field. SITE_<n> past the last branch adds branches,
+// as long as the numbering has no gaps.
+func applyEnvOverrides(b *BusinessConfig) {
+	override := func(dst *string, key string) {
+		if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+			*dst = v
+		}
+	}
+
+	override(&b.Name, "PHOREST_BUSINESS_NAME")
+	override(&b.BusinessID, "PHOREST_BUSINESS")
+	override(&b.Username, "PHOREST_USERNAME")
+	override(&b.Password, "PHOREST_PASSWORD")
+	override(&b.Region, "PHOREST_REGION")
+	override(&b.BaseURL, "PHOREST_BASE_URL")
+	b.Region = strings.ToLower(b.Region)
+
+	for n := 1; ; n++ {
+		prefix := "SITE_" + strconv.Itoa(n) + "_"
+		if n > len(b.Branches) {
+			id := strings.TrimSpace(os.Getenv(prefix + "BRANCH_ID"))
+			if id == "" {
+				return
+			}
+			b.Branches = append(b.Branches, BranchConfig{BranchID: id})
+		}
+		br := &b.Branches[n-1]
+		override(&br.BranchID, prefix+"BRANCH_ID")
+		override(&br.Name, prefix+"NAME")
+		override(&br.Role, prefix+"ROLE")
+		br.Role = strings.ToLower(br.Role)
+	}
+}
+
 func (c *Config) ActiveDatabaseURL() (string, error) {
 	if c.SandboxMode {
 		if strings.TrimSpace(c.SandboxDatabaseURL) == "" {
@@ -81,6 +288,22 @@ func (c *Config) ActiveDatabaseURL() (string, error) {
 	return c.DatabaseURL, nil
 }
 
+// PhorestBaseURL resolves the API root: an explicit override wins, otherwise
+// the region preset. The result never has a trailing slash or "/api".
+func PhorestBaseURL(region, override string) (string, error) {
+	if o := strings.TrimSpace(override); o != "" {
+		return strings.TrimSuffix(strings.TrimRight(o, "/"), "/api"), nil
+	}
+	if region == "" {
+		region = RegionEU
+	}
+	u, ok := phorestRegionURLs[strings.ToLower(region)]
+	if !ok {
+		return "", fmt.Errorf("unknown PHOREST_REGION %q (want eu or us, or set PHOREST_BASE_URL)", region)
+	}
+	return u, nil
+}
+
 func getEnvOrFail(logger *log.Logger, key string) string {
 	val := os.Getenv(key)
 	if val == "" {
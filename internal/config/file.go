@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFiles are tried in the working directory when DATAHUB_CONFIG
+// is unset. None present = env-only config, as before.
+var defaultConfigFiles = []string{"datahub.yaml", "datahub.yml", "datahub.toml"}
+
+// fileConfig is the on-disk shape (YAML or TOML, picked by extension):
+//
+//	businesses:
+//	  - name: Jakata Group
+//	    id: <phorest business id>
+//	    username: global/api@example.com
+//	    password_env: PHOREST_PASSWORD   # or password: ... (prefer the env var)
+//	    region: eu                       # or base_url: https://...
+//	    branches:
+//	      - {name: Jakata, id: <branch id>}
+//	      - {name: PK, id: <branch id>, role: stock_hub}
+type fileConfig struct {
+	Businesses []fileBusiness `yaml:"businesses" toml:"businesses"`
+}
+
+type fileBusiness struct {
+	Name        string       `yaml:"name" toml:"name"`
+	ID          string       `yaml:"id" toml:"id"`
+	Username    string       `yaml:"username" toml:"username"`
+	Password    string       `yaml:"password" toml:"password"`
+	PasswordEnv string       `yaml:"password_env" toml:"password_env"`
+	Region      string       `yaml:"region" toml:"region"`
+	BaseURL     string       `yaml:"base_url" toml:"base_url"`
+	Branches    []fileBranch `yaml:"branches" toml:"branches"`
+}
+
+type fileBranch struct {
+	Name string `yaml:"name" toml:"name"`
+	ID   string `yaml:"id" toml:"id"`
+	Role string `yaml:"role" toml:"role"`
+}
+
+// configFilePath returns DATAHUB_CONFIG, else the first default file that
+// exists, else "".
+func configFilePath() string {
+	if p := strings.TrimSpace(os.Getenv("DATAHUB_CONFIG")); p != "" {
+		return p
+	}
+	for _, p := range defaultConfigFiles {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// loadBusinessesFile parses path into businesses. Base URLs are resolved
+// later, after env overrides.
+func loadBusinessesFile(path string) ([]BusinessConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var fc fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &fc)
+	case ".toml":
+		err = toml.Unmarshal(raw, &fc)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension (want .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	out := make([]BusinessConfig, 0, len(fc.Businesses))
+	for _, fb := range fc.Businesses {
+		password := fb.Password
+		if fb.PasswordEnv != "" {
+			password = os.Getenv(fb.PasswordEnv)
+		}
+
+		b := BusinessConfig{
+			Name:       fb.Name,
+			BusinessID: fb.ID,
+			Username:   fb.Username,
+			Password:   password,
+			Region:     strings.ToLower(fb.Region),
+			BaseURL:    fb.BaseURL,
+		}
+		for _, br := range fb.Branches {
+			b.Branches = append(b.Branches, BranchConfig{
+				Name:     br.Name,
+				BranchID: br.ID,
+				Role:     strings.ToLower(br.Role),
+			})
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// validateBusinesses checks what every sync relies on and resolves each
+// business's BaseURL from its region / override.
+func validateBusinesses(bs []BusinessConfig) error {
+	if len(bs) == 0 {
+		return fmt.Errorf("no businesses configured")
+	}
+
+	seenBusiness := map[string]bool{}
+	seenBranch := map[string]string{}
+	for i := range bs {
+		b := &bs[i]
+		label := b.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+
+		switch {
+		case b.BusinessID == "":
+			return fmt.Errorf("business %s: id is required", label)
+		case b.Username == "" || b.Password == "":
+			return fmt.Errorf("business %s: username and password are required", label)
+		case len(b.Branches) == 0:
+			return fmt.Errorf("business %s: at least one branch is required", label)
+		case seenBusiness[b.BusinessID]:
+			return fmt.Errorf("business %s: id %s listed twice", label, b.BusinessID)
+		}
+		seenBusiness[b.BusinessID] = true
+		if b.Name == "" {
+			b.Name = b.BusinessID
+		}
+
+		baseURL, err := PhorestBaseURL(b.Region, b.BaseURL)
+		if err != nil {
+			return fmt.Errorf("business %s: %w", label, err)
+		}
+		b.BaseURL = baseURL
+		if b.Region == "" {
+			b.Region = RegionEU
+		}
+
+		hubs := 0
+		for j := range b.Branches {
+			br := &b.Branches[j]
+			if br.BranchID == "" {
+				return fmt.Errorf("business %s: branch %q has no id", label, br.Name)
+			}
+			if other, dup := seenBranch[br.BranchID]; dup {
+				return fmt.Errorf("branch id %s is listed under both %s and %s", br.BranchID, other, label)
+			}
+			seenBranch[br.BranchID] = label
+			if br.Name == "" {
+				br.Name = br.BranchID
+			}
+
+			switch br.Role {
+			case "":
+			case RoleStockHub:
+				hubs++
+			default:
+				return fmt.Errorf("business %s: branch %q has unknown role %q (want %q or none)", label, br.Name, br.Role, RoleStockHub)
+			}
+		}
+		if hubs > 1 {
+			return fmt.Errorf("business %s: more than one %s branch", label, RoleStockHub)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ErrLockHeld means another session holds the advisory lock (i.e. the same
+// sync is already running elsewhere) and the wait timeout ran out.
+var ErrLockHeld = errors.New("already running in another process")
+
+// lockPollInterval is how often TryAdvisoryLock retries while waiting.
+const lockPollInterval = 500 * time.Millisecond
+
+// AdvisoryLock is a held Postgres session-level advisory lock.
+//
+// Session locks belong to a connection, not the pool, so the lock pins one
+// *sql.Conn until Unlock. If the process dies the connection drops and
+// Postgres releases the lock for us.
+type AdvisoryLock struct {
+	Name string
+	key  int64
+	conn *sql.Conn
+}
+
+// LockKey hashes a human-readable lock name into the bigint key space
+// pg_advisory_lock uses. Names look like "sync:transactions_csv:<branchID>".
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("datahub:" + name))
+	return int64(h.Sum64())
+}
+
+// TryAdvisoryLock takes the named lock, retrying for up to wait (0 = a single
+// attempt). Returns ErrLockHeld if it is still held by someone else after that.
+func TryAdvisoryLock(ctx context.Context, sqlDB *sql.DB, name string, wait time.Duration) (*AdvisoryLock, error) {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("advisory lock %s: get conn: %w", name, err)
+	}
+
+	key := LockKey(name)
+	deadline := time.Now().Add(wait)
+
+	for {
+		var ok bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("advisory lock %s: %w", name, err)
+		}
+		if ok {
+			return &AdvisoryLock{Name: name, key: key, conn: conn}, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			_ = conn.Close()
+			return nil, ErrLockHeld
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(min(lockPollInterval, time.Until(deadline))):
+		}
+	}
+}
+
+// Unlock releases the lock and returns the connection to the pool. Safe on nil.
+func (l *AdvisoryLock) Unlock() error {
+	if l == nil || l.conn == nil {
+		return nil
+	}
+	defer func() {
+		_ = l.conn.Close()
+		l.conn = nil
+	}()
+
+	// Use a fresh context: the caller's ctx may already be cancelled and we
+	// still want the lock released before the conn goes back to the pool.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var released bool
+	if err := l.conn.QueryRowContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key).Scan(&released); err != nil {
+		// Don't hand a conn that may still hold the lock back to the pool:
+		// returning ErrBadConn from Raw makes database/sql discard it.
+		_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+		return fmt.Errorf("advisory unlock %s: %w", l.Name, err)
+	}
+	if !released {
+		return fmt.Errorf("advisory unlock %s: lock was not held", l.Name)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"log"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -33,3 +34,37 @@ func RunMigrations(dsn string, migrationsDir string, lg *log.Logger) error {
 
 	return nil
 }
+
+// RollbackMigrations reverts the last `steps` migrations (steps must be > 0).
+func RollbackMigrations(dsn string, migrationsDir string, steps int, lg *log.Logger) error {
+	if steps <= 0 {
+		return errors.New("rollback steps must be > 0")
+	}
+
+	m, err := migrate.New("file://"+migrationsDir, dsn)
+	if err != nil {
+		return err
+	}
+
+	lg.Printf("↩️  Rolling back %d migration(s)...", steps)
+	if err := m.Steps(-steps); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	lg.Println("✅ Rollback complete.")
+	return nil
+}
+
+// MigrationVersion reports the current schema version and dirty flag.
+// version = 0 means no migrations have been applied yet.
+func MigrationVersion(dsn string, migrationsDir string) (version uint, dirty bool, err error) {
+	m, err := migrate.New("file://"+migrationsDir, dsn)
+	if err != nil {
+		return 0, false, err
+	}
+
+	version, dirty, err = m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
@@ -0,0 +1,194 @@
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/db"
+)
+
+// Postgres is a disposable database for one harness run. It is either a fresh
+// database on an existing server (admin DSN) or a whole throwaway cluster
+// started from the local initdb/pg_ctl binaries.
+type Postgres struct {
+	DSN string
+
+	lg      *log.Logger
+	cleanup func() error
+}
+
+// StartPostgres provisions the database. With adminDSN set (a URL-style DSN
+// for a role allowed to CREATE DATABASE) a randomly named database is created
+// and dropped on Close. Otherwise initdb + pg_ctl are looked up in $PG_BIN,
+// $PATH and the usual distro locations, and a cluster is started on a free
+// local port inside a temp dir.
+func StartPostgres(ctx context.Context, adminDSN string, lg *log.Logger) (*Postgres, error) {
+	if strings.TrimSpace(adminDSN) != "" {
+		return createDatabase(adminDSN, lg)
+	}
+	return startCluster(ctx, lg)
+}
+
+// Close drops the database / stops the cluster and removes its files.
+func (p *Postgres) Close() error {
+	if p == nil || p.cleanup == nil {
+		return nil
+	}
+	return p.cleanup()
+}
+
+func createDatabase(adminDSN string, lg *log.Logger) (*Postgres, error) {
+	u, err := url.Parse(adminDSN)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, fmt.Errorf("admin DSN must be a postgres:// URL")
+	}
+
+	admin, err := db.Open(adminDSN)
+	if err != nil {
+		return nil, fmt.Errorf("connect admin DSN: %w", err)
+	}
+
+	name := "datahub_e2e_" + randomSuffix()
+	if err := admin.Exec("CREATE DATABASE " + name).Error; err != nil {
+		_ = db.Close(admin)
+		return nil, fmt.Errorf("create database %s: %w", name, err)
+	}
+	lg.Printf("🐘 created throwaway database %s", name)
+
+	u.Path = "/" + name
+	return &Postgres{
+		DSN: u.String(),
+		lg:  lg,
+		cleanup: func() error {
+			defer db.Close(admin)
+			// FORCE (PG13+) kicks out any connection the Runner left behind.
+			if err := admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)").Error; err != nil {
+				return fmt.Errorf("drop database %s: %w", name, err)
+			}
+			lg.Printf("🧹 dropped database %s", name)
+			return nil
+		},
+	}, nil
+}
+
+func startCluster(ctx context.Context, lg *log.Logger) (*Postgres, error) {
+	initdb, err := findPGBinary("initdb")
+	if err != nil {
+		return nil, err
+	}
+	pgctl, err := findPGBinary("pg_ctl")
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "datahub-e2e-pg-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	dataDir := filepath.Join(dir, "data")
+	logFile := filepath.Join(dir, "postgres.log")
+
+	fail := func(err error) (*Postgres, error) {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// The migrations hand ownership to "postgres", so that's the superuser we create.
+	out, err := exec.CommandContext(ctx, initdb,
+		"-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync",
+	).CombinedOutput()
+	if err != nil {
+		return fail(fmt.Errorf("initdb: %w\n%s", err, out))
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return fail(err)
+	}
+
+	serverOpts := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off -c full_page_writes=off", port, dir)
+	out, err = exec.CommandContext(ctx, pgctl,
+		"-D", dataDir, "-l", logFile, "-o", serverOpts, "-w", "-t", "30", "start",
+	).CombinedOutput()
+	if err != nil {
+		logTail, _ := os.ReadFile(logFile)
+		return fail(fmt.Errorf("pg_ctl start: %w\n%s%s", err, out, logTail))
+	}
+	lg.Printf("🐘 started throwaway Postgres on 127.0.0.1:%d (%s)", port, dir)
+
+	return &Postgres{
+		DSN: fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port),
+		lg:  lg,
+		cleanup: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			out, err := exec.CommandContext(ctx, pgctl, "-D", dataDir, "-m", "immediate", "-w", "stop").CombinedOutput()
+			if rmErr := os.RemoveAll(dir); rmErr != nil && err == nil {
+				err = rmErr
+			}
+			if err != nil {
+				return fmt.Errorf("stop throwaway Postgres: %w\n%s", err, out)
+			}
+			lg.Printf("🧹 stopped throwaway Postgres on port %d", port)
+			return nil
+		},
+	}, nil
+}
+
+// findPGBinary prefers $PG_BIN, then $PATH, then the newest
+// /usr/lib/postgresql/<ver>/bin (Debian/Ubuntu keep initdb off $PATH).
+func findPGBinary(name string) (string, error) {
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err != nil {
+			return "", fmt.Errorf("PG_BIN=%s: %w", dir, err)
+		}
+		return p, nil
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p, nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql", "*", "bin", name))
+	sort.Slice(matches, func(i, j int) bool { return pgVersion(matches[i]) > pgVersion(matches[j]) })
+	if len(matches) > 0 {
+		return matches[0], nil
+	}
+
+	return "", errors.New("no Postgres to test against: install postgres (initdb/pg_ctl on PATH or PG_BIN) " +
+		"or set E2E_ADMIN_DSN")
+}
+
+func pgVersion(binPath string) int {
+	// /usr/lib/postgresql/<ver>/bin/<name>
+	v, _ := strconv.Atoi(filepath.Base(filepath.Dir(filepath.Dir(binPath))))
+	return v
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("find free port: %w", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
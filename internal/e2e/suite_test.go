@@ -0,0 +1,233 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/fakephorest"
+	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/araquach/phorest-datahub/internal/phorest"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// syncCase is what one sync job must leave behind after a run against the
+// default fixtures.
+type syncCase struct {
+	Job string // phorest.SyncJob name
+
+	// Tables must be non-empty after the first run and keep the same row
+	// count after the second.
+	Tables []string
+
+	// Watermark must be set afterwards for every configured branch, or for
+	// "ALL" when it is business-wide. Empty = the sync keeps no watermark.
+	Watermark repos.WatermarkEntity
+}
+
+// syncCases covers every sync in phorest.SyncJobs() order.
+var syncCases = []syncCase{
+	{Job: "staff", Tables: []string{"raw.staff"}, Watermark: repos.WatermarkStaffAPI},
+	{Job: "branches", Tables: []string{"raw.branches"}, Watermark: repos.WatermarkBranchesAPI},
+	{Job: "clients-csv", Tables: []string{"archive.clients"}, Watermark: repos.WatermarkClientsCSV},
+	{Job: "clients-api", Tables: []string{"raw.clients_api"}, Watermark: repos.WatermarkClientsAPI},
+	{Job: "transactions", Tables: []string{"raw.transactions", "raw.transaction_items"}, Watermark: repos.WatermarkTransactionsCSV},
+	{Job: "appointments", Tables: []string{"raw.appointments_api"}, Watermark: repos.WatermarkAppointmentsAPI},
+	{Job: "reviews", Tables: []string{"raw.reviews"}, Watermark: repos.WatermarkReviewsAPI},
+	{Job: "worktimetable", Tables: []string{"raw.staff_worktimetable_slots"}, Watermark: repos.WatermarkWorktimetableRolling},
+	{Job: "products", Tables: []string{"raw.ph_products"}, Watermark: repos.WatermarkProductsAPI},
+	{Job: "breaks", Tables: []string{"raw.breaks_api"}, Watermark: repos.WatermarkBreaksAPI},
+}
+
+// TestSyncs runs each sync twice (first run, then an idempotency re-run),
+// then a faulted re-run of the GET-only syncs and a sync_runs sanity check.
+// Cases run in order against the same DB, like a real full sync would.
+func TestSyncs(t *testing.T) {
+	h := NewTestHarness(t)
+	ctx := context.Background()
+	runs := 0
+
+	runJob := func(job phorest.SyncJob) error {
+		runs++
+		jobCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+		if err := job.Run(h.Runner(), jobCtx); err != nil {
+			return fmt.Errorf("sync %s: %w", job.Name, err)
+		}
+		return nil
+	}
+
+	for _, c := range syncCases {
+		t.Run(c.Job, func(t *testing.T) {
+			job, ok := phorest.FindSyncJob(c.Job)
+			if !ok {
+				t.Fatalf("unknown sync job %q", c.Job)
+			}
+
+			if err := runJob(job); err != nil {
+				t.Fatal(err)
+			}
+			counts, err := h.counts(c.Tables)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tbl := range c.Tables {
+				if counts[tbl] == 0 {
+					t.Fatalf("%s is empty after the first run", tbl)
+				}
+			}
+			if err := h.checkWatermarkSet(c); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := h.rerunUnchanged(c.Tables, func() error { return runJob(job) }); err != nil {
+				t.Fatalf("re-run: %v", err)
+			}
+		})
+	}
+
+	t.Run("faults", func(t *testing.T) {
+		var tables []string
+		var jobs []phorest.SyncJob
+		for _, c := range syncCases {
+			if c.Job == "appointments" || c.Job == "reviews" {
+				job, _ := phorest.FindSyncJob(c.Job)
+				jobs = append(jobs, job)
+				tables = append(tables, c.Tables...)
+			}
+		}
+
+		h.Fake.SetFaults(fakephorest.Faults{RateLimitEvery: 3, ErrorEvery: 5})
+		defer h.Fake.SetFaults(fakephorest.Faults{})
+
+		before := phorest.SharedHTTPStats()
+		err := h.rerunUnchanged(tables, func() error {
+			for _, job := range jobs {
+				if err := runJob(job); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delta := phorest.SharedHTTPStats().Sub(before); delta.Retries == 0 {
+			t.Fatalf("expected retries under injected faults, got %s", delta)
+		}
+	})
+
+	t.Run("sync_runs", func(t *testing.T) {
+		var total, running int64
+		if err := h.DB.Model(&models.SyncRun{}).Count(&total).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := h.DB.Model(&models.SyncRun{}).Where("status = ?", models.SyncRunRunning).Count(&running).Error; err != nil {
+			t.Fatal(err)
+		}
+		if total < int64(runs) {
+			t.Errorf("%d sync runs executed but only %d recorded", runs, total)
+		}
+		if running > 0 {
+			t.Errorf("%d sync runs still marked running", running)
+		}
+	})
+}
+
+// rerunUnchanged runs fn and fails if any table's row count moved or any
+// watermark went backwards.
+func (h *Harness) rerunUnchanged(tables []string, fn func() error) error {
+	countsBefore, err := h.counts(tables)
+	if err != nil {
+		return err
+	}
+	wmBefore, err := h.watermarks()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	countsAfter, err := h.counts(tables)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if countsAfter[t] != countsBefore[t] {
+			return fmt.Errorf("%s: %d rows before re-run, %d after", t, countsBefore[t], countsAfter[t])
+		}
+	}
+
+	wmAfter, err := h.watermarks()
+	if err != nil {
+		return err
+	}
+	for key, prev := range wmBefore {
+		cur, ok := wmAfter[key]
+		if !ok {
+			return fmt.Errorf("watermark %s disappeared", key)
+		}
+		if cur.Before(prev) {
+			return fmt.Errorf("watermark %s went backwards: %s → %s",
+				key, prev.Format(time.RFC3339), cur.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func (h *Harness) checkWatermarkSet(c syncCase) error {
+	if c.Watermark == "" {
+		return nil
+	}
+
+	branches := []string{repos.WatermarkAllBranches}
+	if !c.Watermark.BusinessWide() {
+		branches = branches[:0]
+		for _, b := range h.Cfg.Branches {
+			branches = append(branches, b.BranchID)
+		}
+	}
+
+	wr := repos.NewWatermarksRepo(h.DB, h.Logger)
+	for _, id := range branches {
+		wm, err := wr.GetLastUpdated(c.Watermark, id)
+		if err != nil {
+			return fmt.Errorf("read watermark %s/%s: %w", c.Watermark, id, err)
+		}
+		if wm == nil {
+			return fmt.Errorf("watermark %s/%s not set", c.Watermark, id)
+		}
+	}
+	return nil
+}
+
+func (h *Harness) counts(tables []string) (map[string]int64, error) {
+	out := make(map[string]int64, len(tables))
+	for _, t := range tables {
+		var n int64
+		if err := h.DB.Table(t).Count(&n).Error; err != nil {
+			return nil, fmt.Errorf("count %s: %w", t, err)
+		}
+		out[t] = n
+	}
+	return out, nil
+}
+
+// watermarks snapshots sync_watermarks keyed "entity/branch".
+func (h *Harness) watermarks() (map[string]time.Time, error) {
+	rows, err := repos.NewWatermarksRepo(h.DB, h.Logger).List()
+	if err != nil {
+		return nil, fmt.Errorf("list watermarks: %w", err)
+	}
+	out := make(map[string]time.Time, len(rows))
+	for _, wm := range rows {
+		if wm.LastUpdatedPhorest == nil {
+			continue
+		}
+		out[wm.Entity+"/"+wm.Branch()] = *wm.LastUpdatedPhorest
+	}
+	return out, nil
+}
@@ -0,0 +1,135 @@
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/db"
+)
+
+// AdminDSNEnv names a postgres:// URL of a role that may CREATE DATABASE.
+// Tests use it when set, otherwise they start a local cluster via
+// initdb/pg_ctl (see StartPostgres).
+const AdminDSNEnv = "E2E_ADMIN_DSN"
+
+// NewTestPostgres provisions a migrated throwaway database for t and drops it
+// when the test ends. With -short, or with no E2E_ADMIN_DSN and no local
+// initdb/pg_ctl, the test is skipped rather than failed.
+func NewTestPostgres(t testing.TB) *Postgres {
+	t.Helper()
+
+	adminDSN := skipWithoutPostgres(t)
+	lg := TestLogger(t)
+
+	pg, err := StartPostgres(context.Background(), adminDSN, lg)
+	if err != nil {
+		t.Fatalf("start Postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := pg.Close(); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+
+	if err := db.RunMigrations(pg.DSN, MigrationsDir(t), lg); err != nil {
+		t.Fatalf("migrate throwaway DB: %v", err)
+	}
+	return pg
+}
+
+// NewTestDB is NewTestPostgres opened as a raw *sql.DB, for repo tests.
+func NewTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	pg := NewTestPostgres(t)
+	gdb, err := db.Open(pg.DSN)
+	if err != nil {
+		t.Fatalf("open throwaway DB: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close(gdb) })
+
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		t.Fatalf("get raw sql DB: %v", err)
+	}
+	return sqlDB
+}
+
+// NewTestHarness is Setup for a test: skipped like NewTestPostgres, closed
+// when the test ends.
+func NewTestHarness(t testing.TB) *Harness {
+	t.Helper()
+
+	adminDSN := skipWithoutPostgres(t)
+	h, err := Setup(context.Background(), Options{
+		AdminDSN:      adminDSN,
+		MigrationsDir: MigrationsDir(t),
+		Logger:        TestLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+// skipWithoutPostgres returns the admin DSN to use ("" = local cluster) or
+// skips t when there is no Postgres to test against.
+func skipWithoutPostgres(t testing.TB) string {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("needs Postgres; skipped with -short")
+	}
+	adminDSN := strings.TrimSpace(os.Getenv(AdminDSNEnv))
+	if adminDSN != "" {
+		return adminDSN
+	}
+	if _, err := findPGBinary("initdb"); err != nil {
+		t.Skipf("%v", err)
+	}
+	if _, err := findPGBinary("pg_ctl"); err != nil {
+		t.Skipf("%v", err)
+	}
+	return ""
+}
+
+// MigrationsDir is the repo's migrations/ directory, found by walking up
+// from the test's working directory to go.mod.
+func MigrationsDir(t testing.TB) string {
+	t.Helper()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return filepath.Join(dir, "migrations")
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			t.Fatalf("no go.mod above the test directory")
+		}
+		dir = parent
+	}
+}
+
+// TestLogger sends log output to t.Log, so it only shows for failing or -v
+// tests.
+func TestLogger(t testing.TB) *log.Logger {
+	return log.New(testWriter{t}, "", 0)
+}
+
+type testWriter struct{ t testing.TB }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,253 @@
+package fakephorest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	jobTypeTransactions = "TRANSACTIONS_CSV"
+	jobTypeClients      = "CLIENT_CSV"
+)
+
+// csvJob is one csvexportjob. The CSV is rendered at creation, so later
+// fixture/time changes don't move under a job mid-poll.
+type csvJob struct {
+	ID           string
+	Type         string
+	BranchID     string
+	StartFilter  string
+	FinishFilter string
+	FilterExpr   string
+	Started      time.Time
+	Polls        int
+	CSV          []byte
+	Rows         int
+}
+
+type jobRequest struct {
+	JobType          string `json:"jobType"`
+	StartFilter      string `json:"startFilter"`
+	FinishFilter     string `json:"finishFilter"`
+	FilterExpression string `json:"filterExpression"`
+}
+
+// jobResponse mirrors the gateway's ExportResponse.
+type jobResponse struct {
+	JobID              string  `json:"jobId"`
+	JobType            string  `json:"jobType"`
+	JobStatus          string  `json:"jobStatus"`
+	Started            *string `json:"started,omitempty"`
+	Finished           *string `json:"finished,omitempty"`
+	StartFilter        string  `json:"startFilter"`
+	FinishFilter       string  `json:"finishFilter"`
+	FilterExpression   string  `json:"filterExpression,omitempty"`
+	TotalRows          *int32  `json:"totalRows,omitempty"`
+	SucceededRows      *int32  `json:"succeededRows,omitempty"`
+	TempCSVExternalURL *string `json:"tempCsvExternalUrl,omitempty"`
+	FailureReason      *string `json:"failureReason,omitempty"`
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var req jobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	var (
+		file, tsCol string
+		branchID    = r.PathValue("branch")
+		fixtureFor  = branchID
+	)
+	switch req.JobType {
+	case jobTypeTransactions:
+		file, tsCol = fileTransactionCSV, "purchase_updated_at"
+	case jobTypeClients:
+		// Client exports are business-wide even though the URL names a branch.
+		file, tsCol, fixtureFor = fileClientsCSV, "updated_at", ""
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported jobType %q", req.JobType))
+		return
+	}
+
+	raw, err := readFixture(s.fsys, fixtureFor, file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	after, before := parseFilterExpr(req.FilterExpression)
+	body, rows, err := filterCSV(raw, tsCol, req.StartFilter, req.FinishFilter, after, before)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.nextJobID++
+	job := &csvJob{
+		ID:           fmt.Sprintf("fake-job-%d", s.nextJobID),
+		Type:         req.JobType,
+		BranchID:     branchID,
+		StartFilter:  req.StartFilter,
+		FinishFilter: req.FinishFilter,
+		FilterExpr:   req.FilterExpression,
+		Started:      time.Now().UTC(),
+		CSV:          body,
+		Rows:         rows,
+	}
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, job.response("QUEUED", r))
+}
+
+// handlePollJob reports IN_PROGRESS until Faults.JobPolls polls have been
+// seen, then DONE (or FAILED). An empty export fails with "No records found",
+// which is what the real gateway does.
+func (s *Server) handlePollJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("job")]
+	if ok {
+		job.Polls++
+	}
+	f := s.faults
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+
+	need := f.JobPolls
+	if need <= 0 {
+		need = 1
+	}
+
+	switch {
+	case job.Polls < need:
+		writeJSON(w, http.StatusOK, job.response("IN_PROGRESS", r))
+	case f.FailJobs:
+		resp := job.response("FAILED", r)
+		reason := "injected: job failed"
+		resp.FailureReason = &reason
+		writeJSON(w, http.StatusOK, resp)
+	case job.Rows == 0:
+		resp := job.response("FAILED", r)
+		reason := "No records found"
+		resp.FailureReason = &reason
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		writeJSON(w, http.StatusOK, job.response("DONE", r))
+	}
+}
+
+// handleDownload stands in for the signed S3 URL the gateway hands out.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("job")]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	_, _ = w.Write(job.CSV)
+}
+
+func (j *csvJob) response(status string, r *http.Request) jobResponse {
+	started := j.Started.Format(time.RFC3339)
+	resp := jobResponse{
+		JobID:            j.ID,
+		JobType:          j.Type,
+		JobStatus:        status,
+		Started:          &started,
+		StartFilter:      j.StartFilter,
+		FinishFilter:     j.FinishFilter,
+		FilterExpression: j.FilterExpr,
+	}
+	if status == "DONE" {
+		finished := time.Now().UTC().Format(time.RFC3339)
+		rows := int32(j.Rows)
+		url := fmt.Sprintf("http://%s/_fake/csv/%s", r.Host, j.ID)
+		resp.Finished = &finished
+		resp.TotalRows = &rows
+		resp.SucceededRows = &rows
+		resp.TempCSVExternalURL = &url
+	}
+	return resp
+}
+
+// parseFilterExpr understands the only expressions we send:
+// "updated=>TS", "updated=<TS" and both joined with "&".
+func parseFilterExpr(expr string) (after, before *time.Time) {
+	for _, part := range strings.Split(expr, "&") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "updated=>"):
+			if t, ok := parseTS(strings.TrimPrefix(part, "updated=>")); ok {
+				after = &t
+			}
+		case strings.HasPrefix(part, "updated=<"):
+			if t, ok := parseTS(strings.TrimPrefix(part, "updated=<")); ok {
+				before = &t
+			}
+		}
+	}
+	return after, before
+}
+
+// filterCSV keeps rows whose tsCol falls on a date within [startDate,
+// finishDate] and inside the filterExpression bounds. Missing column or
+// bounds → rows pass through unfiltered.
+func filterCSV(raw []byte, tsCol, startDate, finishDate string, after, before *time.Time) ([]byte, int, error) {
+	if raw == nil {
+		return nil, 0, nil
+	}
+
+	recs, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse fixture csv: %w", err)
+	}
+	if len(recs) == 0 {
+		return nil, 0, nil
+	}
+
+	col := -1
+	for i, h := range recs[0] {
+		if strings.EqualFold(strings.TrimSpace(h), tsCol) {
+			col = i
+		}
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	_ = cw.Write(recs[0])
+
+	rows := 0
+	for _, rec := range recs[1:] {
+		if col >= 0 && col < len(rec) {
+			d := strings.TrimSpace(rec[col])
+			if len(d) >= 10 {
+				d = d[:10]
+			}
+			if (startDate != "" && d < startDate) || (finishDate != "" && d > finishDate) {
+				continue
+			}
+			if t, ok := parseTS(rec[col]); ok {
+				if (after != nil && t.Before(*after)) || (before != nil && t.After(*before)) {
+					continue
+				}
+			}
+		}
+		_ = cw.Write(rec)
+		rows++
+	}
+	cw.Flush()
+	return buf.Bytes(), rows, cw.Error()
+}
@@ -0,0 +1,106 @@
+package fakephorest
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultFixtures is a small business with one staff member per branch,
+// enough for every sync to find something. See fixtures/README.
+//
+//go:embed all:fixtures
+var defaultFixtures embed.FS
+
+// DefaultFixtures returns the built-in fixture set.
+func DefaultFixtures() fs.FS {
+	sub, err := fs.Sub(defaultFixtures, "fixtures")
+	if err != nil {
+		panic(err) // embedded path is fixed at build time
+	}
+	return sub
+}
+
+// defaultBranchDir holds per-branch fixtures used for any branch ID that has
+// no directory of its own, so the fake works with whatever SITE_*_BRANCH_ID
+// the caller has configured.
+const defaultBranchDir = "_default"
+
+// Fixture files are templates, expanded on every read so dates stay inside
+// the syncs' rolling windows however old the fixture is:
+//
+//	{{branch}}                       requested branch ID
+//	{{today}} {{today-3}} {{today+7}} UTC date, YYYY-MM-DD
+//	{{now}} {{now-2h}} {{now+30m}}    UTC timestamp, RFC3339 with millis
+var templateRe = regexp.MustCompile(`\{\{\s*(branch|today|now)\s*(?:([+-])\s*(\d+)([a-z]*))?\s*\}\}`)
+
+func expand(raw []byte, branchID string, now time.Time) []byte {
+	return templateRe.ReplaceAllFunc(raw, func(m []byte) []byte {
+		g := templateRe.FindSubmatch(m)
+		kind, sign, num, unit := string(g[1]), string(g[2]), string(g[3]), string(g[4])
+
+		n := 0
+		if num != "" {
+			n, _ = strconv.Atoi(num)
+			if sign == "-" {
+				n = -n
+			}
+		}
+
+		switch kind {
+		case "branch":
+			return []byte(branchID)
+		case "today":
+			return []byte(now.AddDate(0, 0, n).Format("2006-01-02"))
+		default: // now
+			d := time.Duration(n) * time.Hour
+			switch unit {
+			case "m":
+				d = time.Duration(n) * time.Minute
+			case "d":
+				d = time.Duration(n) * 24 * time.Hour
+			}
+			return []byte(now.Add(d).Format("2006-01-02T15:04:05.000Z"))
+		}
+	})
+}
+
+// readFixture reads name for branchID (or business-level when branchID is
+// ""), falling back to the _default branch dir. Missing files read as nil.
+func readFixture(fsys fs.FS, branchID, name string) ([]byte, error) {
+	candidates := []string{name}
+	if branchID != "" {
+		candidates = []string{path.Join(branchID, name), path.Join(defaultBranchDir, name)}
+	}
+
+	for _, p := range candidates {
+		raw, err := fs.ReadFile(fsys, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read fixture %s: %w", p, err)
+		}
+		return expand(raw, branchID, time.Now().UTC()), nil
+	}
+	return nil, nil
+}
+
+// readList reads a JSON array fixture into generic objects.
+func readList(fsys fs.FS, branchID, name string) ([]map[string]any, error) {
+	raw, err := readFixture(fsys, branchID, name)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	var out []map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", name, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,239 @@
+package fakephorest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fixture file names. Business-level files live at the fixture root, the rest
+// under <branchID>/ (or _default/).
+const (
+	fileBranches       = "branches.json"
+	fileClients        = "clients.json"
+	fileAppointments   = "appointments.json"
+	fileBreaks         = "breaks.json"
+	fileReviews        = "reviews.json"
+	fileStaff          = "staff.json"
+	fileWorkTimetables = "worktimetables.json"
+	fileProducts       = "products.json"
+	fileTransactionCSV = "transactions.csv"
+	fileClientsCSV     = "clients.csv"
+)
+
+func (s *Server) handleBranches(w http.ResponseWriter, r *http.Request) {
+	s.serveList(w, r, "", fileBranches, "branches", nil)
+}
+
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.serveList(w, r, "", fileClients, "clients",
+		timeRange("updatedAt", q.Get("updatedAfter"), ""))
+}
+
+func (s *Server) handleAppointments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.serveList(w, r, r.PathValue("branch"), fileAppointments, "appointments",
+		all(
+			dateRange("appointmentDate", q.Get("from_date"), q.Get("to_date")),
+			timeRange("updatedAt", q.Get("updated_from"), q.Get("updated_to")),
+		))
+}
+
+func (s *Server) handleBreaks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.serveList(w, r, r.PathValue("branch"), fileBreaks, "breaks",
+		dateRange("breakDate", q.Get("from_date"), q.Get("to_date")))
+}
+
+func (s *Server) handleReviews(w http.ResponseWriter, r *http.Request) {
+	s.serveList(w, r, r.PathValue("branch"), fileReviews, "reviews", nil)
+}
+
+func (s *Server) handleStaff(w http.ResponseWriter, r *http.Request) {
+	var keep func(map[string]any) bool
+	if r.URL.Query().Get("fetch_archived") != "true" {
+		keep = func(m map[string]any) bool { archived, _ := m["archived"].(bool); return !archived }
+	}
+	s.serveList(w, r, r.PathValue("branch"), fileStaff, "staffs", keep)
+}
+
+// handleWorkTimetables filters each staff member's timeSlots to the window,
+// mirroring the real endpoint.
+func (s *Server) handleWorkTimetables(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	inWindow := dateRange("date", q.Get("from_date"), q.Get("to_date"))
+
+	s.serveList(w, r, r.PathValue("branch"), fileWorkTimetables, "workTimeTables", func(m map[string]any) bool {
+		slots, _ := m["timeSlots"].([]any)
+		kept := make([]any, 0, len(slots))
+		for _, sl := range slots {
+			if sm, ok := sl.(map[string]any); ok && inWindow(sm) {
+				kept = append(kept, sm)
+			}
+		}
+		m["timeSlots"] = kept
+		return true
+	})
+}
+
+func (s *Server) handleProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	keep := timeRange("updatedAt", q.Get("updatedAfter"), q.Get("updatedBefore"))
+	if pt := q.Get("productType"); pt != "" {
+		keep = all(keep, func(m map[string]any) bool { return m["type"] == pt })
+	}
+	s.serveList(w, r, r.PathValue("branch"), fileProducts, "products", keep)
+}
+
+func (s *Server) handleStockAdjustment(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	s.mu.Lock()
+	s.adjustments = append(s.adjustments, Adjustment{
+		BusinessID: r.PathValue("business"),
+		BranchID:   r.PathValue("branch"),
+		Body:       body,
+		At:         time.Now().UTC(),
+	})
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveList reads a fixture list, filters it and writes one page in the
+// gateway's HAL shape: {"_embedded": {key: [...]}, "page": {...}}.
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, branchID, file, key string, keep func(map[string]any) bool) {
+	items, err := readList(s.fsys, branchID, file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filtered := items[:0]
+	for _, it := range items {
+		if keep == nil || keep(it) {
+			filtered = append(filtered, it)
+		}
+	}
+
+	q := r.URL.Query()
+	size := atoiDefault(q.Get("size"), 20)
+	page := atoiDefault(q.Get("page"), 0)
+
+	s.mu.Lock()
+	p := s.paging
+	s.mu.Unlock()
+
+	if p.MaxPageSize > 0 && size > p.MaxPageSize {
+		size = p.MaxPageSize
+	}
+	if size <= 0 {
+		size = 20
+	}
+
+	total := len(filtered)
+	totalPages := (total + size - 1) / size
+	if p.ExtraEmptyPage {
+		totalPages++
+	}
+
+	start, end := page*size, page*size+size
+	if p.OverlapPages && page > 0 {
+		start-- // replay the previous page's last item
+	}
+	start, end = clamp(start, 0, total), clamp(end, 0, total)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"_embedded": map[string]any{key: filtered[start:end]},
+		"page": map[string]int{
+			"size":          size,
+			"totalElements": total,
+			"totalPages":    totalPages,
+			"number":        page,
+		},
+	})
+}
+
+// ---------- filters ----------
+
+func all(fs ...func(map[string]any) bool) func(map[string]any) bool {
+	return func(m map[string]any) bool {
+		for _, f := range fs {
+			if f != nil && !f(m) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// dateRange keeps items whose YYYY-MM-DD field is within [from, to] (inclusive).
+func dateRange(field, from, to string) func(map[string]any) bool {
+	return func(m map[string]any) bool {
+		v, _ := m[field].(string)
+		if len(v) >= 10 {
+			v = v[:10]
+		}
+		if from != "" && v < from {
+			return false
+		}
+		if to != "" && v > to {
+			return false
+		}
+		return true
+	}
+}
+
+// timeRange keeps items whose timestamp field is >= after and < before.
+// Unparseable bounds are ignored, like a lenient gateway would.
+func timeRange(field, after, before string) func(map[string]any) bool {
+	a, aok := parseTS(after)
+	b, bok := parseTS(before)
+	return func(m map[string]any) bool {
+		v, _ := m[field].(string)
+		t, ok := parseTS(v)
+		if !ok {
+			return !aok && !bok
+		}
+		if aok && t.Before(a) {
+			return false
+		}
+		if bok && !t.Before(b) {
+			return false
+		}
+		return true
+	}
+}
+
+func parseTS(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func atoiDefault(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,265 @@
+// Package fakephorest is a stand-in for the Phorest third-party API, good
+// enough to run every datahub sync end to end without touching production.
+//
+// It serves fixture JSON/CSV (see DefaultFixtures) on the same paths the real
+// gateway uses, so pointing PHOREST_BASE_URL at it is all a caller needs.
+// Faults (429s, 500s, latency) and pagination quirks can be switched on at
+// start-up or at runtime via PUT /_fake/faults.
+package fakephorest
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Faults injects failures into /api requests. Counters are per server and
+// count every /api request, so "every 3rd" is across all endpoints.
+type Faults struct {
+	FailFirst      int           `json:"failFirst"`      // first N requests → 503
+	RateLimitEvery int           `json:"rateLimitEvery"` // every Nth request → 429
+	RetryAfter     time.Duration `json:"retryAfter"`     // Retry-After on 429s (0 = omit header)
+	ErrorEvery     int           `json:"errorEvery"`     // every Nth request → 500
+	Latency        time.Duration `json:"latency"`        // delay before every response
+
+	// CSV export jobs.
+	JobPolls int  `json:"jobPolls"` // polls before a job reports DONE (default 1)
+	FailJobs bool `json:"failJobs"` // jobs end FAILED instead of DONE
+}
+
+// Paging simulates the awkward corners of Phorest's page/size pagination.
+type Paging struct {
+	MaxPageSize    int  `json:"maxPageSize"`    // cap size regardless of what was asked
+	ExtraEmptyPage bool `json:"extraEmptyPage"` // overstate totalPages by one (last page empty)
+	OverlapPages   bool `json:"overlapPages"`   // each page repeats the previous page's last item
+}
+
+type Options struct {
+	Faults Faults
+	Paging Paging
+	Logger *log.Logger // nil = silent
+}
+
+// Adjustment is one stock adjustment POST received by the fake.
+type Adjustment struct {
+	BusinessID string          `json:"businessId"`
+	BranchID   string          `json:"branchId"`
+	Body       json.RawMessage `json:"body"`
+	At         time.Time       `json:"at"`
+}
+
+type Server struct {
+	fsys fs.FS
+	lg   *log.Logger
+
+	mu          sync.Mutex
+	faults      Faults
+	paging      Paging
+	requests    int            // /api requests seen (drives the *Every faults)
+	hits        map[string]int // route pattern → count
+	jobs        map[string]*csvJob
+	nextJobID   int
+	adjustments []Adjustment
+}
+
+// New builds a fake over fsys (nil = DefaultFixtures).
+func New(fsys fs.FS, opts Options) *Server {
+	if fsys == nil {
+		fsys = DefaultFixtures()
+	}
+	lg := opts.Logger
+	if lg == nil {
+		lg = log.New(io.Discard, "", 0)
+	}
+	return &Server{
+		fsys:   fsys,
+		lg:     lg,
+		faults: opts.Faults,
+		paging: opts.Paging,
+		hits:   map[string]int{},
+		jobs:   map[string]*csvJob{},
+	}
+}
+
+// Start serves the fake on a random local port. Use srv.URL as
+// PHOREST_BASE_URL / config.Config.PhorestBaseURL.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s.Handler())
+}
+
+// Handler exposes the routes so callers can mount them on their own listener
+// (the `datahub fake-phorest` command does).
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	api := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, s.instrument(pattern, h))
+	}
+
+	api("GET /api/business/{business}/branch", s.handleBranches)
+	api("GET /api/business/{business}/client", s.handleClients)
+	api("GET /api/business/{business}/branch/{branch}/appointment", s.handleAppointments)
+	api("GET /api/business/{business}/branch/{branch}/break", s.handleBreaks)
+	api("GET /api/business/{business}/branch/{branch}/review", s.handleReviews)
+	api("GET /api/business/{business}/branch/{branch}/staff", s.handleStaff)
+	api("GET /api/business/{business}/branch/{branch}/staff/worktimetable", s.handleWorkTimetables)
+	api("GET /api/business/{business}/branch/{branch}/product", s.handleProducts)
+	api("POST /api/business/{business}/branch/{branch}/stock/adjustment", s.handleStockAdjustment)
+	api("POST /api/business/{business}/branch/{branch}/csvexportjob", s.handleCreateJob)
+	api("GET /api/business/{business}/branch/{branch}/csvexportjob/{job}", s.handlePollJob)
+
+	// Control plane: never faulted, no auth.
+	mux.HandleFunc("GET /_fake/csv/{job}", s.handleDownload)
+	mux.HandleFunc("GET /_fake/faults", s.handleGetFaults)
+	mux.HandleFunc("PUT /_fake/faults", s.handlePutFaults)
+	mux.HandleFunc("GET /_fake/adjustments", s.handleAdjustments)
+	mux.HandleFunc("GET /_fake/stats", s.handleStats)
+
+	return mux
+}
+
+// SetFaults replaces the fault switches and resets the request counter.
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+	s.requests = 0
+}
+
+func (s *Server) SetPaging(p Paging) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paging = p
+}
+
+// Adjustments returns the stock adjustments received so far.
+func (s *Server) Adjustments() []Adjustment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Adjustment(nil), s.adjustments...)
+}
+
+// Hits returns request counts per route pattern (faulted requests included).
+func (s *Server) Hits() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.hits))
+	for k, v := range s.hits {
+		out[k] = v
+	}
+	return out
+}
+
+// instrument applies auth, latency and fault injection in front of an API route.
+func (s *Server) instrument(pattern string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		s.hits[pattern]++
+		n, f := s.requests, s.faults
+		s.mu.Unlock()
+
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if _, _, ok := r.BasicAuth(); !ok {
+			writeError(w, http.StatusUnauthorized, "missing basic auth")
+			return
+		}
+
+		switch {
+		case n <= f.FailFirst:
+			s.lg.Printf("💥 fake: %s %s → 503 (fail-first %d/%d)", r.Method, r.URL.Path, n, f.FailFirst)
+			writeError(w, http.StatusServiceUnavailable, "injected: fail-first")
+			return
+		case f.RateLimitEvery > 0 && n%f.RateLimitEvery == 0:
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter.Round(time.Second)/time.Second)))
+			}
+			s.lg.Printf("💥 fake: %s %s → 429", r.Method, r.URL.Path)
+			writeError(w, http.StatusTooManyRequests, "injected: rate limited")
+			return
+		case f.ErrorEvery > 0 && n%f.ErrorEvery == 0:
+			s.lg.Printf("💥 fake: %s %s → 500", r.Method, r.URL.Path)
+			writeError(w, http.StatusInternalServerError, "injected: server error")
+			return
+		}
+
+		s.lg.Printf("➡️  fake: %s %s", r.Method, r.URL.RequestURI())
+		next(w, r)
+	})
+}
+
+func (s *Server) handleGetFaults(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := struct {
+		Faults Faults `json:"faults"`
+		Paging Paging `json:"paging"`
+	}{s.faults, s.paging}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handlePutFaults accepts {"faults": {...}, "paging": {...}}; either may be omitted.
+func (s *Server) handlePutFaults(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Faults *Faults `json:"faults"`
+		Paging *Paging `json:"paging"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if in.Faults != nil {
+		s.SetFaults(*in.Faults)
+	}
+	if in.Paging != nil {
+		s.SetPaging(*in.Paging)
+	}
+	s.handleGetFaults(w, r)
+}
+
+func (s *Server) handleAdjustments(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Adjustments())
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	hits := s.Hits()
+	keys := make([]string, 0, len(hits))
+	for k := range hits {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	type row struct {
+		Route string `json:"route"`
+		Hits  int    `json:"hits"`
+	}
+	out := make([]row, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, row{k, hits[k]})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": http.StatusText(status), "detail": msg})
+}
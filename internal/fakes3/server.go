@@ -0,0 +1,329 @@
+// Package fakes3 is a small in-memory stand-in for an S3-compatible object
+// store (AWS S3, MinIO), good enough to point the archive's S3 storage at
+// without a real bucket.
+//
+// It speaks path-style requests (/<bucket>/<key>): PUT, GET, HEAD and DELETE
+// on objects and ListObjectsV2 on buckets. Buckets spring into existence on
+// first use. Every request must carry a valid SigV4 signature for the
+// configured credentials, so signing bugs show up here rather than in
+// production.
+package fakes3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	AccessKey string // default "fake"
+	SecretKey string // default "fakesecret"
+
+	// MaxKeys caps ListObjectsV2 pages (default 1000, as S3), so callers'
+	// pagination can be exercised with small values.
+	MaxKeys int
+
+	Logger *log.Logger // nil = silent
+}
+
+type object struct {
+	data     []byte
+	modified time.Time
+}
+
+type Server struct {
+	opts Options
+	lg   *log.Logger
+
+	mu      sync.Mutex
+	buckets map[string]map[string]object
+}
+
+func New(opts Options) *Server {
+	if opts.AccessKey == "" {
+		opts.AccessKey = "fake"
+	}
+	if opts.SecretKey == "" {
+		opts.SecretKey = "fakesecret"
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 1000
+	}
+	lg := opts.Logger
+	if lg == nil {
+		lg = log.New(io.Discard, "", 0)
+	}
+	return &Server{opts: opts, lg: lg, buckets: map[string]map[string]object{}}
+}
+
+// Start serves the fake on a random local port; use srv.URL as the endpoint.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s.Handler())
+}
+
+// Handler exposes the routes so callers can mount them on their own listener
+// (the `datahub fake-s3` command does).
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.serve)
+}
+
+// Keys returns every key in bucket, sorted.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for k := range s.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		writeError(w, http.StatusBadRequest, "InvalidBucketName", "path-style requests only: /<bucket>/<key>")
+		return
+	}
+	if err := s.verify(r); err != nil {
+		s.lg.Printf("🔒 fake s3: %s %s → 403: %v", r.Method, r.URL.Path, err)
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+	s.lg.Printf("➡️  fake s3: %s %s", r.Method, r.URL.RequestURI())
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.handleList(w, r, bucket)
+	case key == "":
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" on a bucket")
+	case r.Method == http.MethodPut:
+		s.handlePut(w, r, bucket, key)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		s.handleGet(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.buckets[bucket], key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *Server) handlePut(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if r.ContentLength >= 0 && int64(len(data)) != r.ContentLength {
+		writeError(w, http.StatusBadRequest, "IncompleteBody",
+			fmt.Sprintf("got %d bytes, Content-Length %d", len(data), r.ContentLength))
+		return
+	}
+	s.mu.Lock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = map[string]object{}
+	}
+	s.buckets[bucket][key] = object{data: data, modified: time.Now().UTC()}
+	s.mu.Unlock()
+
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.mu.Lock()
+	obj, ok := s.buckets[bucket][key]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	w.Header().Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(obj.data)
+	}
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string   `xml:"Name"`
+	Prefix                string   `xml:"Prefix"`
+	KeyCount              int      `xml:"KeyCount"`
+	MaxKeys               int      `xml:"MaxKeys"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	ContinuationToken     string   `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
+	Contents              []listEntry
+}
+
+type listEntry struct {
+	XMLName      xml.Name `xml:"Contents"`
+	Key          string   `xml:"Key"`
+	LastModified string   `xml:"LastModified"`
+	Size         int      `xml:"Size"`
+}
+
+// handleList is ListObjectsV2. The continuation token is simply the last
+// key of the previous page.
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 (list-type=2) is supported")
+		return
+	}
+	prefix, after := q.Get("prefix"), q.Get("continuation-token")
+	maxKeys := s.opts.MaxKeys
+	if n, err := strconv.Atoi(q.Get("max-keys")); err == nil && n > 0 && n < maxKeys {
+		maxKeys = n
+	}
+
+	s.mu.Lock()
+	objs := s.buckets[bucket]
+	var keys []string
+	for k := range objs {
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	out := listResult{Name: bucket, Prefix: prefix, MaxKeys: maxKeys, ContinuationToken: after}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		out.IsTruncated = true
+		out.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		out.Contents = append(out.Contents, listEntry{
+			Key:          k,
+			LastModified: objs[k].modified.Format(time.RFC3339),
+			Size:         len(objs[k].data),
+		})
+	}
+	s.mu.Unlock()
+	out.KeyCount = len(out.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(out)
+}
+
+// verify checks r's SigV4 Authorization header against the configured
+// credentials.
+func (s *Server) verify(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	rest, ok := strings.CutPrefix(auth, "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return fmt.Errorf("missing AWS4-HMAC-SHA256 authorization")
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(rest, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[k] = v
+	}
+	cred := strings.Split(fields["Credential"], "/")
+	if len(cred) != 5 || cred[3] != "s3" || cred[4] != "aws4_request" {
+		return fmt.Errorf("malformed credential %q", fields["Credential"])
+	}
+	if cred[0] != s.opts.AccessKey {
+		return fmt.Errorf("unknown access key %q", cred[0])
+	}
+	day, region := cred[1], cred[2]
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, day) {
+		return fmt.Errorf("x-amz-date %q does not match credential day %s", amzDate, day)
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	var headers strings.Builder
+	for _, h := range signed {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.opts.SecretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = mac(key, part)
+	}
+	want := hex.EncodeToString(mac(key, toSign))
+	if !hmac.Equal([]byte(want), []byte(fields["Signature"])) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func mac(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery is SigV4's query string: sorted, every reserved byte
+// percent-encoded (url.QueryEscape encodes a space as "+", so fix that up).
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := slices.Clone(q[k])
+		slices.Sort(vs)
+		for _, v := range vs {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: msg})
+}
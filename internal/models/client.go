@@ -69,4 +69,4 @@ type Client struct {
 	UpdatedAt time.Time
 }
 
-func (Client) TableName() string { return "clients" }
+func (Client) TableName() string { return "archive.clients" }
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// CSV export job statuses. The first four are Phorest's own jobStatus values;
+// IMPORTED and EXPIRED are set by us and end a job's life.
+const (
+	CSVExportQueued     = "QUEUED"
+	CSVExportInProgress = "IN_PROGRESS"
+	CSVExportDone       = "DONE"
+	CSVExportFailed     = "FAILED"
+	CSVExportImported   = "IMPORTED" // downloaded + imported successfully
+	CSVExportExpired    = "EXPIRED"  // Phorest no longer knows the job / URL
+)
+
+// CSVExportJob is one Phorest csvexportjob we created, kept so a later run can
+// resume it instead of queueing a duplicate export for the same window.
+type CSVExportJob struct {
+	ID               int64      `gorm:"column:id;primaryKey"`
+	Entity           string     `gorm:"column:entity"`
+	BranchID         string     `gorm:"column:branch_id"`
+	JobType          string     `gorm:"column:job_type"`
+	JobID            string     `gorm:"column:job_id"`
+	StartFilter      string     `gorm:"column:start_filter"`
+	FinishFilter     string     `gorm:"column:finish_filter"`
+	FilterExpression string     `gorm:"column:filter_expression"`
+	Status           string     `gorm:"column:status"`
+	FailureReason    *string    `gorm:"column:failure_reason"`
+	TotalRows        *int32     `gorm:"column:total_rows"`
+	CreatedAt        time.Time  `gorm:"column:created_at"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at"`
+	FinishedAt       *time.Time `gorm:"column:finished_at"`
+	ImportedAt       *time.Time `gorm:"column:imported_at"`
+}
+
+func (CSVExportJob) TableName() string { return "core.csv_export_jobs" }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ImportLedgerEntry records one CSV file imported into the DB, so the same
+// contents are never imported twice and `datahub imports list` can show
+// what the data was built from.
+type ImportLedgerEntry struct {
+	ID             int64     `gorm:"column:id;primaryKey"`
+	Entity         string    `gorm:"column:entity"`
+	FileName       string    `gorm:"column:file_name"`
+	Path           string    `gorm:"column:path"`
+	SHA256         string    `gorm:"column:sha256"`
+	SizeBytes      int64     `gorm:"column:size_bytes"`
+	RowCount       int       `gorm:"column:row_count"`
+	RejectedCount  int       `gorm:"column:rejected_count"`
+	Changed        bool      `gorm:"column:changed"`
+	PreviousSHA256 *string   `gorm:"column:previous_sha256"`
+	RunID          *int64    `gorm:"column:run_id"`
+	ImportedAt     time.Time `gorm:"column:imported_at"`
+}
+
+func (ImportLedgerEntry) TableName() string { return "core.import_ledger" }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ImportReject is one CSV cell that failed its column's schema, so its row
+// was kept out of raw.* instead of being stored with a zero or NULL.
+type ImportReject struct {
+	ID         int64     `gorm:"column:id;primaryKey"`
+	Source     string    `gorm:"column:source"`
+	File       string    `gorm:"column:file"`
+	Line       int       `gorm:"column:line"`
+	ColumnName string    `gorm:"column:column_name"`
+	RawValue   string    `gorm:"column:raw_value"`
+	Error      string    `gorm:"column:error"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+}
+
+func (ImportReject) TableName() string { return "raw.import_rejects" }
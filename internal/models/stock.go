@@ -11,6 +11,7 @@ type PhProduct struct {
 	CategoryID      *string    `gorm:"column:category_id"`
 	CategoryName    *string    `gorm:"column:category_name"`
 	Code            *string    `gorm:"column:code"`
+	Barcode         *string    `gorm:"column:barcode"`
 	TypeRaw         *string    `gorm:"column:type_raw"` // e.g. "RETAIL, COLOUR, PROFESSIONAL"
 	MeasurementQty  *float64   `gorm:"column:measurement_qty"`
 	MeasurementUnit *string    `gorm:"column:measurement_unit"`
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+const (
+	SyncRunRunning = "running"
+	SyncRunSuccess = "success"
+	SyncRunFailed  = "failed"
+	SyncRunSkipped = "skipped" // lock held by another process
+)
+
+// SyncRun is one invocation of a Runner sync (all branches).
+type SyncRun struct {
+	ID           int64      `gorm:"column:id;primaryKey"`
+	Entity       string     `gorm:"column:entity"`
+	Status       string     `gorm:"column:status"`
+	StartedAt    time.Time  `gorm:"column:started_at"`
+	FinishedAt   *time.Time `gorm:"column:finished_at"`
+	RowsFetched  int64      `gorm:"column:rows_fetched"`
+	RowsUpserted int64      `gorm:"column:rows_upserted"`
+	Error        *string    `gorm:"column:error"`
+
+	Branches []SyncRunBranch `gorm:"foreignKey:RunID;references:ID"`
+}
+
+func (SyncRun) TableName() string { return "core.sync_runs" }
+
+// SyncRunBranch is the per-branch outcome within a SyncRun.
+type SyncRunBranch struct {
+	ID              int64      `gorm:"column:id;primaryKey"`
+	RunID           int64      `gorm:"column:run_id"`
+	BranchID        string     `gorm:"column:branch_id"`
+	Status          string     `gorm:"column:status"`
+	StartedAt       time.Time  `gorm:"column:started_at"`
+	FinishedAt      *time.Time `gorm:"column:finished_at"`
+	WindowFrom      *time.Time `gorm:"column:window_from"`
+	WindowTo        *time.Time `gorm:"column:window_to"`
+	RowsFetched     int64      `gorm:"column:rows_fetched"`
+	RowsUpserted    int64      `gorm:"column:rows_upserted"`
+	WatermarkBefore *time.Time `gorm:"column:watermark_before"`
+	WatermarkAfter  *time.Time `gorm:"column:watermark_after"`
+	Error           *string    `gorm:"column:error"`
+}
+
+func (SyncRunBranch) TableName() string { return "core.sync_run_branches" }
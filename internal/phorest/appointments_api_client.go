@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"time"
@@ -74,24 +73,13 @@ type AppointmentsAPIClient struct {
 	HTTP     *http.Client
 }
 
-func NewAppointmentsAPIClient(user, pass, business string) *AppointmentsAPIClient {
+func NewAppointmentsAPIClient(baseURL, user, pass, business string) *AppointmentsAPIClient {
 	return &AppointmentsAPIClient{
-		BaseURL:  "https://api-gateway-eu.phorest.com/third-party-api-server/api",
+		BaseURL:  apiRoot(baseURL),
 		User:     user,
 		Pass:     pass,
 		Business: business,
-		HTTP: &http.Client{
-			Timeout: 25 * time.Second,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
-		},
+		HTTP:     SharedHTTPClient(),
 	}
 }
 
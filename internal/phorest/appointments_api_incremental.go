@@ -8,20 +8,24 @@ import (
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
+func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) (err error) {
 	lg := r.Logger
 	db := r.DB
 
 	lg.Printf("▶️ Starting APPOINTMENTS_API sync...")
 
+	run := r.startRun(repos.WatermarkAppointmentsAPI)
+	defer run.finish(&err)
+
 	c := NewAppointmentsAPIClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
 	)
 
 	repo := repos.NewAppointmentsAPIRepo(db, lg)
-	wr := repos.NewWatermarksRepo(db, lg)
+	wr := run.watermarks()
 
 	const (
 		pageSize = 100
@@ -31,9 +35,6 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 		fetchDeleted        = true
 		fetchArchived       = true
 		fetchOnlineCategory = true
-
-		// small overlap to avoid missing boundary updates
-		overlap = 2 * time.Minute
 	)
 
 	// Tunables (cron-friendly)
@@ -41,17 +42,17 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 	futureDays := getIntEnv("APPOINTMENTS_FUTURE_DAYS", 120)
 
 	// Backfill mode (do not use updated_from AND do not update watermark)
-	ignoreWM := getBoolEnv("APPOINTMENTS_IGNORE_WATERMARK", false)
+	ignoreWM := r.Opts.IgnoreWatermark || getBoolEnv("APPOINTMENTS_IGNORE_WATERMARK", false)
 
 	now := time.Now().UTC()
 	defaultStart := dateOnly(now.AddDate(0, 0, -historyDays))
 	defaultEnd := dateOnly(now.AddDate(0, 0, futureDays))
 
-	fromOverride, err := getDateEnv("APPOINTMENTS_FROM_DATE")
+	fromOverride, err := dateOverride(r.Opts.FromDate, "APPOINTMENTS_FROM_DATE")
 	if err != nil {
 		return err
 	}
-	toOverride, err := getDateEnv("APPOINTMENTS_TO_DATE")
+	toOverride, err := dateOverride(r.Opts.ToDate, "APPOINTMENTS_TO_DATE")
 	if err != nil {
 		return err
 	}
@@ -74,7 +75,7 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 	}
 
 	if ignoreWM {
-		lg.Printf("🟥 APPOINTMENTS_IGNORE_WATERMARK=1 → BACKFILL MODE (no updated_from, no watermark updates)")
+		lg.Printf("🟥 ignore-watermark → BACKFILL MODE (no updated_from, no watermark updates)")
 	} else {
 		lg.Printf("🟩 Normal incremental mode (uses watermark + overlap, updates watermark)")
 	}
@@ -86,8 +87,16 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 		)
 	}
 
-	for _, br := range r.Cfg.Branches {
+	for _, br := range r.branches() {
 		branchID := br.BranchID
+		rb, ok, err := run.lockBranch(ctx, branchID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		rb.window(appointmentStart, appointmentEnd)
 
 		// Determine updated_from (nil = full fetch within window)
 		var updatedFrom *time.Time
@@ -95,7 +104,7 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 
 		if !ignoreWM {
 			var err error
-			last, err = wr.GetLastUpdated("appointments_api", branchID)
+			last, err = wr.GetLastUpdated(repos.WatermarkAppointmentsAPI, branchID)
 			if err != nil {
 				return fmt.Errorf("get appointments_api watermark branch=%s: %w", branchID, err)
 			}
@@ -105,7 +114,7 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 			updatedFrom = nil
 			lg.Printf("ℹ️ appointments_api/%s: IGNORE watermark enabled → no updated_from filter", branchID)
 		} else if last != nil {
-			uf := last.UTC().Add(-overlap)
+			uf := last.UTC().Add(-appointmentsOverlap)
 			updatedFrom = &uf
 			lg.Printf("ℹ️ appointments_api/%s: watermark=%s (updated_from=%s)",
 				branchID,
@@ -179,10 +188,12 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 				if len(rows) == 0 {
 					break
 				}
+				rb.fetched(len(rows))
 
 				if err := repo.UpsertBatch(rows, 500); err != nil {
 					return fmt.Errorf("upsert appointments branch=%s page=%d: %w", branchID, page, err)
 				}
+				rb.upserted(len(rows))
 
 				touchedCount += len(rows)
 
@@ -206,6 +217,7 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 
 		if touchedCount == 0 {
 			lg.Printf("✅ appointments_api/%s: no rows returned (nothing to do)", branchID)
+			rb.finish(nil)
 			continue
 		}
 
@@ -214,7 +226,7 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 			lg.Printf("🧱 appointments_api/%s: BACKFILL MODE → watermark not updated", branchID)
 		} else {
 			if maxUpdated != nil {
-				if err := wr.UpsertLastUpdated("appointments_api", branchID, *maxUpdated); err != nil {
+				if err := wr.UpsertLastUpdated(repos.WatermarkAppointmentsAPI, branchID, *maxUpdated); err != nil {
 					return fmt.Errorf("update appointments_api watermark branch=%s: %w", branchID, err)
 				}
 				lg.Printf("💾 appointments_api/%s: watermark → %s", branchID, maxUpdated.UTC().Format(time.RFC3339))
@@ -223,6 +235,7 @@ func (r *Runner) RunIncrementalAppointmentsAPISync(ctx context.Context) error {
 			}
 		}
 
+		rb.finish(nil)
 		lg.Printf("✅ appointments_api/%s: finished (%d rows touched)", branchID, touchedCount)
 	}
 
@@ -1,141 +1,151 @@
 package phorest
 
 import (
+	"fmt"
 	"time"
 
-	"gorm.io/gorm"
+	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-// small helper DTOs
-type txWatermarkRow struct {
-	BranchID       string     `gorm:"column:branch_id"`
-	MaxUpdatedAtPh *time.Time `gorm:"column:max_updated_at"`
+// watermarkSeed derives one entity's watermark from rows already in the DB.
+//
+// Query selects branch_id + max_updated_at and may use these named args:
+//
+//	@branches      the current business's branch IDs
+//	@whole         true when there is only one business, so business-wide
+//	               rows need no branch filter
+//	@rolling_start start of the worktimetable rolling window
+//
+// Business-wide entities read a single row and ignore its branch_id.
+type watermarkSeed struct {
+	Entity repos.WatermarkEntity
+	Query  string
 }
 
-type clientsWatermarkRow struct {
-	MaxUpdatedAtPh *time.Time `gorm:"column:max_updated_at"`
+type watermarkSeedRow struct {
+	BranchID     string     `gorm:"column:branch_id"`
+	MaxUpdatedAt *time.Time `gorm:"column:max_updated_at"`
 }
 
-type apptWatermarkRow struct {
-	BranchID       string     `gorm:"column:branch_id"`
-	MaxUpdatedAtPh *time.Time `gorm:"column:max_updated_at"`
+// watermarkSeeds covers every entity. Each value is what the sync itself
+// would have stored after importing those rows.
+var watermarkSeeds = []watermarkSeed{
+	{repos.WatermarkTransactionsCSV, `
+		SELECT branch_id, MAX(updated_at_phorest) AS max_updated_at
+		FROM raw.transaction_items
+		WHERE updated_at_phorest IS NOT NULL AND branch_id IN @branches
+		GROUP BY branch_id`},
+	// Legacy CSV clients, archived by 0012.
+	{repos.WatermarkClientsCSV, `
+		SELECT '' AS branch_id, MAX(updated_at_phorest) AS max_updated_at
+		FROM archive.clients
+		WHERE updated_at_phorest IS NOT NULL AND (@whole OR creating_branch_id IN @branches)`},
+	{repos.WatermarkClientsAPI, `
+		SELECT '' AS branch_id, MAX(updated_at_phorest) AS max_updated_at
+		FROM raw.clients_api
+		WHERE updated_at_phorest IS NOT NULL AND (@whole OR creating_branch_id IN @branches)`},
+	{repos.WatermarkAppointmentsAPI, `
+		SELECT branch_id, MAX(updated_at_phorest) AS max_updated_at
+		FROM raw.appointments_api
+		WHERE updated_at_phorest IS NOT NULL AND branch_id IN @branches
+		GROUP BY branch_id`},
+	{repos.WatermarkReviewsAPI, `
+		SELECT branch_id, (MAX(review_date)::timestamp AT TIME ZONE 'UTC') AS max_updated_at
+		FROM raw.reviews
+		WHERE review_date IS NOT NULL AND branch_id IN @branches
+		GROUP BY branch_id`},
+	{repos.WatermarkProductsAPI, `
+		SELECT branch_id, MAX(updated_at_ph) AS max_updated_at
+		FROM raw.ph_product_stock
+		WHERE updated_at_ph IS NOT NULL AND branch_id IN @branches
+		GROUP BY branch_id`},
+	// The remaining syncs re-fetch by window (or in full) every run and
+	// store "last ran at": our own updated_at is the closest thing.
+	{repos.WatermarkBreaksAPI, `
+		SELECT branch_id, MAX(updated_at) AS max_updated_at
+		FROM raw.breaks_api
+		WHERE branch_id IN @branches
+		GROUP BY branch_id`},
+	{repos.WatermarkWorktimetableRolling, `
+		SELECT branch_id, MAX(updated_at) AS max_updated_at
+		FROM raw.staff_worktimetable_slots
+		WHERE branch_id IN @branches
+		GROUP BY branch_id`},
+	// Slots older than the rolling window can only have come from a backfill.
+	{repos.WatermarkWorktimetableBackfillDone, `
+		SELECT branch_id, MAX(updated_at) AS max_updated_at
+		FROM raw.staff_worktimetable_slots
+		WHERE branch_id IN @branches AND slot_date < @rolling_start
+		GROUP BY branch_id`},
+	{repos.WatermarkStaffAPI, `
+		SELECT branch_id, MAX(updated_at) AS max_updated_at
+		FROM raw.staff
+		WHERE branch_id IN @branches
+		GROUP BY branch_id`},
+	{repos.WatermarkBranchesAPI, `
+		SELECT '' AS branch_id, MAX(updated_at) AS max_updated_at
+		FROM raw.branches
+		WHERE branch_id IN @branches`},
 }
 
-// BootstrapWatermarks inspects existing data and seeds sync_watermarks.
-//
-// - transactions_csv: per-branch, from transaction_items.updated_at_phorest
-// - clients_csv: global (branch_id NULL), from clients.updated_at_phorest
+// BootstrapWatermarks inspects existing data and seeds sync_watermarks for
+// every entity and business (see watermarkSeeds). Watermarks only ever move
+// forward here, so it is safe to re-run.
 func (r *Runner) BootstrapWatermarks() error {
-	lg := r.Logger
-	db := r.DB
-
-	lg.Printf("🔧 Bootstrapping sync_watermarks from existing data...")
+	r.Logger.Printf("🔧 Bootstrapping sync_watermarks from existing data...")
 
-	// 1) Per-branch watermark for transaction_items
-	var txRows []txWatermarkRow
-	if err := db.
-		Raw(`
-			SELECT
-				branch_id,
-				MAX(updated_at_phorest) AS max_updated_at
-			FROM transaction_items
-			WHERE updated_at_phorest IS NOT NULL
-			GROUP BY branch_id
-		`).Scan(&txRows).Error; err != nil {
+	if err := r.eachBusiness((*Runner).bootstrapWatermarks); err != nil {
 		return err
 	}
 
-	for _, row := range txRows {
-		if row.MaxUpdatedAtPh == nil {
-			continue
-		}
-		if err := upsertWatermark(db,
-			"transactions_csv",
-			&row.BranchID,
-			*row.MaxUpdatedAtPh,
-		); err != nil {
-			return err
-		}
-		lg.Printf("  • seeded watermark for transactions_csv / branch %s at %s",
-			row.BranchID, row.MaxUpdatedAtPh.UTC().Format(time.RFC3339))
-	}
+	r.Logger.Printf("✅ sync_watermarks bootstrap complete.")
+	return nil
+}
 
-	// 2) Global watermark for clients (branch_id = 'ALL')
-	var clientRow clientsWatermarkRow
-	if err := db.Raw(`
-    SELECT MAX(updated_at_phorest) AS max_updated_at
-    FROM clients
-    WHERE updated_at_phorest IS NOT NULL
-`).Scan(&clientRow).Error; err != nil {
-		return err
-	}
+func (r *Runner) bootstrapWatermarks() error {
+	lg := r.Logger
+	wr := r.watermarks().ForBootstrap()
 
-	if clientRow.MaxUpdatedAtPh != nil {
-		all := "ALL"
-		if err := upsertWatermark(db,
-			"clients_csv",
-			&all, // <── HERE: use "ALL"
-			*clientRow.MaxUpdatedAtPh,
-		); err != nil {
-			return err
-		}
-		lg.Printf("  • seeded watermark for clients_csv (ALL) at %s",
-			clientRow.MaxUpdatedAtPh.UTC().Format(time.RFC3339))
-	} else {
-		lg.Printf("  • no clients.updated_at_phorest found; skipping clients_csv")
+	branchIDs := make([]string, 0, len(r.Cfg.Branches))
+	for _, b := range r.Cfg.Branches {
+		branchIDs = append(branchIDs, b.BranchID)
 	}
+	if len(branchIDs) == 0 {
+		return fmt.Errorf("no branches configured")
+	}
+	rollingStart, _ := worktimetableRollingWindow(time.Now().UTC())
 
-	// 3) Per-branch watermark for appointments_api (seed CSV bootstrap watermark)
-	var apptRows []apptWatermarkRow
-	if err := db.
-		Raw(`
-			SELECT
-				branch_id,
-				MAX(updated_at_phorest) AS max_updated_at
-			FROM appointments_api
-			WHERE updated_at_phorest IS NOT NULL
-			GROUP BY branch_id
-		`).Scan(&apptRows).Error; err != nil {
-		return err
+	args := map[string]any{
+		"branches":      branchIDs,
+		"whole":         len(r.Cfg.Businesses) <= 1,
+		"rolling_start": rollingStart,
 	}
 
-	for _, row := range apptRows {
-		if row.MaxUpdatedAtPh == nil || row.BranchID == "" {
-			continue
+	for _, seed := range watermarkSeeds {
+		var rows []watermarkSeedRow
+		if err := r.DB.Raw(seed.Query, args).Scan(&rows).Error; err != nil {
+			return fmt.Errorf("bootstrap %s watermarks: %w", seed.Entity, err)
 		}
-		if err := upsertWatermark(db,
-			"appointments_api",
-			&row.BranchID,
-			*row.MaxUpdatedAtPh,
-		); err != nil {
-			return err
+
+		seeded := 0
+		for _, row := range rows {
+			if row.MaxUpdatedAt == nil {
+				continue
+			}
+			branchID := row.BranchID
+			if seed.Entity.BusinessWide() {
+				branchID = r.businessWideKey()
+			}
+			if err := wr.UpsertLastUpdated(seed.Entity, branchID, *row.MaxUpdatedAt); err != nil {
+				return fmt.Errorf("seed %s/%s watermark: %w", seed.Entity, branchID, err)
+			}
+			lg.Printf("  • seeded watermark for %s / %s at %s",
+				seed.Entity, branchID, row.MaxUpdatedAt.UTC().Format(time.RFC3339))
+			seeded++
+		}
+		if seeded == 0 {
+			lg.Printf("  • no existing data for %s; skipping", seed.Entity)
 		}
-		lg.Printf("  • seeded watermark for appointments_api_csv / branch %s at %s",
-			row.BranchID, row.MaxUpdatedAtPh.UTC().Format(time.RFC3339))
 	}
-
-	lg.Printf("✅ sync_watermarks bootstrap complete.")
 	return nil
 }
-
-// upsertWatermark inserts or updates a single (entity, branch_id) row.
-func upsertWatermark(db *gorm.DB, entity string, branchID *string, lastUpdatedPh time.Time) error {
-	if lastUpdatedPh.IsZero() {
-		return nil
-	}
-
-	// Normalise "global" to ALL
-	if branchID == nil || *branchID == "" {
-		all := "ALL"
-		branchID = &all
-	}
-
-	return db.Exec(`
-		INSERT INTO sync_watermarks (entity, branch_id, last_updated_phorest, created_at, updated_at)
-		VALUES ($1, $2, $3, now(), now())
-		ON CONFLICT (entity, branch_id)
-		DO UPDATE SET
-			last_updated_phorest = GREATEST(sync_watermarks.last_updated_phorest, EXCLUDED.last_updated_phorest),
-			updated_at           = now()
-	`, entity, *branchID, lastUpdatedPh).Error
-}
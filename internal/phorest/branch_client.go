@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"time"
 
@@ -42,32 +41,21 @@ type BranchClient struct {
 	Logger   *log.Logger
 }
 
-func NewBranchClient(user, pass, business string, lg *log.Logger) *BranchClient {
+func NewBranchClient(baseURL, user, pass, business string, lg *log.Logger) *BranchClient {
 	return &BranchClient{
-		BaseURL:  "https://api-gateway-eu.phorest.com/third-party-api-server/api",
+		BaseURL:  apiRoot(baseURL),
 		User:     user,
 		Pass:     pass,
 		Business: business,
 		Logger:   lg,
-		HTTP: &http.Client{
-			Timeout: 20 * time.Second,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
-		},
+		HTTP:     SharedHTTPClient(),
 	}
 }
 
-func (c *BranchClient) FetchBranches() ([]models.Branch, error) {
+func (c *BranchClient) FetchBranches(ctx context.Context) ([]models.Branch, error) {
 	url := fmt.Sprintf("%s/business/%s/branch", c.BaseURL, c.Business)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute) // room for retries
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
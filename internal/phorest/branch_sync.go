@@ -1,22 +1,24 @@
 package phorest
 
 import (
+	"context"
 	"time"
 
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-func (r *Runner) SyncBranchesFromAPI() error {
+func (r *Runner) SyncBranchesFromAPI(ctx context.Context) error {
 	c := NewBranchClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
 		r.Logger,
 	)
 	repo := repos.NewBranchRepo(r.DB, r.Logger)
-	wr := repos.NewWatermarksRepo(r.DB, r.Logger)
+	wr := r.watermarks()
 
-	rows, err := c.FetchBranches()
+	rows, err := c.FetchBranches(ctx)
 	if err != nil {
 		r.Logger.Printf("❌ branch fetch failed: %v", err)
 		return err
@@ -33,7 +35,7 @@ func (r *Runner) SyncBranchesFromAPI() error {
 
 	// 🔹 Record a global "branches_api" watermark (branches are fetched in one shot)
 	now := time.Now().UTC()
-	if err := wr.UpsertLastUpdated("branches_api", "ALL", now); err != nil {
+	if err := wr.UpsertLastUpdated(repos.WatermarkBranchesAPI, r.businessWideKey(), now); err != nil {
 		r.Logger.Printf("⚠️ failed to update branches_api watermark: %v", err)
 		// you can choose to return err here if you want it to be fatal
 	}
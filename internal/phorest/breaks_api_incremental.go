@@ -22,19 +22,24 @@ import (
 // Notes:
 //   - Breaks API has no updated_from, so we re-fetch windows and upsert by (branch_id, break_id)
 //   - Repo upsert should be version-gated (only overwrite when EXCLUDED.version >= existing.version)
-func (r *Runner) RunIncrementalBreaksAPISync(ctx context.Context) error {
+func (r *Runner) RunIncrementalBreaksAPISync(ctx context.Context) (err error) {
 	lg := r.Logger
 	db := r.DB
 
 	lg.Printf("▶️ Starting BREAKS_API sync...")
 
+	run := r.startRun(repos.WatermarkBreaksAPI)
+	defer run.finish(&err)
+
 	client := NewBreaksAPIClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
 	)
 
 	repo := repos.NewBreaksAPIRepo(db, lg)
+	wr := run.watermarks()
 
 	now := time.Now().UTC()
 
@@ -46,11 +51,16 @@ func (r *Runner) RunIncrementalBreaksAPISync(ctx context.Context) error {
 
 	backfillEnabled := strings.EqualFold(strings.TrimSpace(os.Getenv("BREAKS_BACKFILL")), "true")
 
-	fromOverride, err := getDateEnv("BREAKS_FROM_DATE")
+	// An explicit --from/--to on the CLI implies a backfill of that window.
+	if r.Opts.FromDate != nil && r.Opts.ToDate != nil {
+		backfillEnabled = true
+	}
+
+	fromOverride, err := dateOverride(r.Opts.FromDate, "BREAKS_FROM_DATE")
 	if err != nil {
 		return err
 	}
-	toOverride, err := getDateEnv("BREAKS_TO_DATE")
+	toOverride, err := dateOverride(r.Opts.ToDate, "BREAKS_TO_DATE")
 	if err != nil {
 		return err
 	}
@@ -77,21 +87,36 @@ func (r *Runner) RunIncrementalBreaksAPISync(ctx context.Context) error {
 		)
 	}
 
-	for _, br := range r.Cfg.Branches {
+	for _, br := range r.branches() {
 		branchID := br.BranchID
+		rb, ok, err := run.lockBranch(ctx, branchID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		rb.window(rollingStart, rollingEnd)
 
 		// Always do rolling window
-		if err := scanBreaksRange(ctx, client, repo, branchID, rollingStart, rollingEnd, lg); err != nil {
+		if err := scanBreaksRange(ctx, client, repo, rb, branchID, rollingStart, rollingEnd, lg); err != nil {
 			return err
 		}
 
 		// Optional backfill
 		if backfillEnabled {
-			if err := scanBreaksRange(ctx, client, repo, branchID, *fromOverride, *toOverride, lg); err != nil {
+			if err := scanBreaksRange(ctx, client, repo, rb, branchID, *fromOverride, *toOverride, lg); err != nil {
 				return err
 			}
 		}
 
+		// Breaks are re-scanned by window every run; the watermark only
+		// records when this branch last completed.
+		if err := wr.UpsertLastUpdated(repos.WatermarkBreaksAPI, branchID, time.Now().UTC()); err != nil {
+			lg.Printf("⚠️ breaks_api/%s: failed to update watermark: %v", branchID, err)
+		}
+
+		rb.finish(nil)
 		lg.Printf("✅ breaks_api/%s: done", branchID)
 	}
 
@@ -102,6 +127,7 @@ func scanBreaksRange(
 	ctx context.Context,
 	c *BreaksAPIClient,
 	repo *repos.BreaksAPIRepo,
+	rb *runBranch,
 	branchID string,
 	start, end time.Time,
 	lg *log.Logger,
@@ -154,9 +180,11 @@ func scanBreaksRange(
 			}
 
 			if len(rows) > 0 {
+				rb.fetched(len(rows))
 				if err := repo.UpsertBatch(rows, 500); err != nil {
 					return fmt.Errorf("upsert breaks branch=%s page=%d: %w", branchID, page, err)
 				}
+				rb.upserted(len(rows))
 			}
 
 			page++
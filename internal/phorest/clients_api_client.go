@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -88,24 +87,13 @@ type ClientsAPIClient struct {
 	HTTP     *http.Client
 }
 
-func NewClientsAPIClient(user, pass, business string) *ClientsAPIClient {
+func NewClientsAPIClient(baseURL, user, pass, business string) *ClientsAPIClient {
 	return &ClientsAPIClient{
-		BaseURL:  "https://api-gateway-eu.phorest.com/third-party-api-server/api",
+		BaseURL:  apiRoot(baseURL),
 		User:     user,
 		Pass:     pass,
 		Business: business,
-		HTTP: &http.Client{
-			Timeout: 20 * time.Second,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
-		},
+		HTTP:     SharedHTTPClient(),
 	}
 }
 
@@ -3,7 +3,6 @@ package phorest
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -11,22 +10,33 @@ import (
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-func (r *Runner) RunIncrementalClientsAPISync(ctx context.Context) error {
+func (r *Runner) RunIncrementalClientsAPISync(ctx context.Context) (err error) {
 	lg := r.Logger
 	db := r.DB
 
 	lg.Printf("▶️ Starting incremental CLIENTS_API sync...")
 
+	run := r.startRun(repos.WatermarkClientsAPI)
+	defer run.finish(&err)
+	rb, ok, err := run.lockBranch(ctx, r.businessWideKey())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
 	c := NewClientsAPIClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
 	)
 	repo := repos.NewClientsAPIRepo(db, lg)
-	wr := repos.NewWatermarksRepo(db, lg)
+	wr := run.watermarks()
 
-	// business-wide, so branchID = "ALL"
-	last, err := wr.GetLastUpdated("clients_api", "ALL")
+	// business-wide, so branchID = "ALL" (see businessWideKey)
+	last, err := wr.GetLastUpdated(repos.WatermarkClientsAPI, r.businessWideKey())
 	if err != nil {
 		return fmt.Errorf("get clients_api watermark: %w", err)
 	}
@@ -58,9 +68,11 @@ func (r *Runner) RunIncrementalClientsAPISync(ctx context.Context) error {
 			break
 		}
 
+		rb.fetched(len(rows))
 		if err := repo.UpsertBatch(rows, 500); err != nil {
 			return fmt.Errorf("upsert clients_api page=%d: %w", page, err)
 		}
+		rb.upserted(len(rows))
 
 		allNew = append(allNew, rows...)
 
@@ -94,20 +106,16 @@ func (r *Runner) RunIncrementalClientsAPISync(ctx context.Context) error {
 	}
 	lg.Printf("💾 clients_api: saved CSV to %s", tmpPath)
 
-	// 2) archive into data/clients_api for future bootstrap
-	archiveDir := "data/clients_api"
-	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
-		return fmt.Errorf("mkdir %s: %w", archiveDir, err)
-	}
-	finalPath := filepath.Join(archiveDir, filename)
-	if err := os.Rename(tmpPath, finalPath); err != nil {
+	// 2) archive for future bootstrap
+	finalPath, err := r.moveToSeedArchive(ctx, archiveClientsAPI, tmpPath)
+	if err != nil {
 		return fmt.Errorf("archive clients_api CSV: %w", err)
 	}
 	lg.Printf("📦 clients_api: archived %s → %s", tmpPath, finalPath)
 
 	// 3) update watermark
 	if maxUpdated != nil {
-		if err := wr.UpsertLastUpdated("clients_api", "ALL", *maxUpdated); err != nil {
+		if err := wr.UpsertLastUpdated(repos.WatermarkClientsAPI, r.businessWideKey(), *maxUpdated); err != nil {
 			return fmt.Errorf("update clients_api watermark: %w", err)
 		}
 		lg.Printf("💾 clients_api: updated watermark → %s", maxUpdated.UTC().Format(time.RFC3339))
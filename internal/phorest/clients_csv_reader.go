@@ -5,22 +5,71 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/araquach/phorest-datahub/internal/archive"
 	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
 type ParsedClients struct {
 	Clients []models.Client
+	Rejects []models.ImportReject // cells that failed clientsCSVSchema
+}
+
+// clientsCSVSchema declares the CLIENT_CSV columns we read. created_at /
+// updated_at carry a time as well as a date.
+var clientsCSVSchema = csvSchema{
+	Name: string(repos.WatermarkClientsCSV),
+	Columns: []csvColumn{
+		{Name: "client_id", Type: colText, Required: true},
+		{Name: "version", Type: colInteger},
+		{Name: "first_name", Type: colText},
+		{Name: "last_name", Type: colText},
+		{Name: "mobile", Type: colText},
+		{Name: "linked_client_mobile", Type: colText},
+		{Name: "land_line", Type: colText},
+		{Name: "email", Type: colText},
+		{Name: "created_at", Type: colDateOrTimestamp},
+		{Name: "updated_at", Type: colDateOrTimestamp},
+		{Name: "birth_date", Type: colDate},
+		{Name: "gender", Type: colText},
+		{Name: "sms_marketing_consent", Type: colBoolean},
+		{Name: "email_marketing_consent", Type: colBoolean},
+		{Name: "sms_reminder_consent", Type: colBoolean},
+		{Name: "email_reminder_consent", Type: colBoolean},
+		{Name: "archived", Type: colBoolean},
+		{Name: "deleted", Type: colBoolean},
+		{Name: "banned", Type: colBoolean},
+		{Name: "merged_to_client_id", Type: colText},
+		{Name: "street_address_1", Type: colText},
+		{Name: "street_address_2", Type: colText},
+		{Name: "city", Type: colText},
+		{Name: "state", Type: colText},
+		{Name: "postal_code", Type: colText},
+		{Name: "country", Type: colText},
+		{Name: "client_since", Type: colDate},
+		{Name: "first_visit", Type: colDate},
+		{Name: "last_visit", Type: colDate},
+		{Name: "notes", Type: colText},
+		{Name: "photo_url", Type: colText},
+		{Name: "preferred_staff_id", Type: colText},
+		{Name: "credit_account_credit_days", Type: colInteger},
+		{Name: "credit_account_credit_limit", Type: colNumber},
+		{Name: "loyalty_card_serial_number", Type: colText},
+		{Name: "external_id", Type: colText},
+		{Name: "creating_branch_id", Type: colText},
+		{Name: "client_category_ids", Type: colText},
+	},
 }
 
 // ParseClientsCSV reads a Phorest clients CSV and returns a unique set by client_id.
 // If multiple rows per client_id exist, we keep the one with the newest UpdatedAtPhorest.
+// Rows failing clientsCSVSchema are returned as Rejects instead.
 func ParseClientsCSV(path string, lg *log.Logger) (*ParsedClients, error) {
-	f, err := os.Open(path)
+	f, err := archive.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open csv: %w", err)
 	}
@@ -37,6 +86,10 @@ func ParseClientsCSV(path string, lg *log.Logger) (*ParsedClients, error) {
 	for i, h := range header {
 		idx[strings.TrimSpace(strings.ToLower(h))] = i
 	}
+	v, err := clientsCSVSchema.validator(path, header, lg)
+	if err != nil {
+		return nil, err
+	}
 
 	clean := func(s string) string {
 		// force UTF-8 by decoding into runes and back out
@@ -98,27 +151,20 @@ func ParseClientsCSV(path string, lg *log.Logger) (*ParsedClients, error) {
 		}
 		return nil
 	}
-	//parseTS := func(s string) *time.Time {
-	//	s = strings.TrimSpace(s)
-	//	if s == "" {
-	//		return nil
-	//	}
-	//	for _, layout := range []string{
-	//		time.RFC3339Nano,
-	//		time.RFC3339,
-	//		"2006-01-02 15:04:05",
-	//		"2006-01-02T15:04:05.000",
-	//		"2006-01-02T15:04:05",
-	//	} {
-	//		if t, err := time.Parse(layout, s); err == nil {
-	//			return &t
-	//		}
-	//	}
-	//	return nil
-	//}
+	parseTS := func(s string) *time.Time {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil
+		}
+		if t, ok := parseLayouts(s, csvDateOrTSLayouts); ok {
+			return &t
+		}
+		return nil
+	}
 
 	// Deduplicate by newest UpdatedAtPhorest (a.k.a. CSV "updated_at")
 	byID := map[string]models.Client{}
+	var rejects []models.ImportReject
 	row := 0
 
 	for {
@@ -130,12 +176,15 @@ func ParseClientsCSV(path string, lg *log.Logger) (*ParsedClients, error) {
 			return nil, fmt.Errorf("read row %d: %w", row, err)
 		}
 		row++
+		line, _ := r.FieldPos(0)
 
-		clientID := get(rec, "client_id")
-		if clientID == "" {
+		if rj := v.validate(rec, line); len(rj) > 0 {
+			rejects = append(rejects, rj...)
 			continue
 		}
 
+		clientID := get(rec, "client_id")
+
 		updated := get(rec, "updated_at") // CSV header
 		created := get(rec, "created_at")
 
@@ -148,8 +197,8 @@ func ParseClientsCSV(path string, lg *log.Logger) (*ParsedClients, error) {
 			LinkedClientMobile: get(rec, "linked_client_mobile"),
 			LandLine:           get(rec, "land_line"),
 			Email:              get(rec, "email"),
-			CreatedAtPhorest:   parseDate(created),
-			UpdatedAtPhorest:   parseDate(updated),
+			CreatedAtPhorest:   parseTS(created),
+			UpdatedAtPhorest:   parseTS(updated),
 			BirthDate:          parseDate(get(rec, "birth_date")),
 			Gender:             get(rec, "gender"),
 
@@ -206,5 +255,5 @@ func ParseClientsCSV(path string, lg *log.Logger) (*ParsedClients, error) {
 	}
 
 	lg.Printf("Parsed clients CSV: %d unique clients", len(out))
-	return &ParsedClients{Clients: out}, nil
+	return &ParsedClients{Clients: out, Rejects: rejects}, nil
 }
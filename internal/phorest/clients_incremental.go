@@ -2,6 +2,7 @@ package phorest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"time"
@@ -9,16 +10,26 @@ import (
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-func (r *Runner) RunIncrementalClientsSync(ctx context.Context) error {
+func (r *Runner) RunIncrementalClientsSync(ctx context.Context) (err error) {
 	lg := r.Logger
-	db := r.DB
 
 	lg.Printf("▶️ Starting incremental CLIENT_CSV sync...")
 
-	wr := repos.NewWatermarksRepo(db, lg)
+	// Business-wide export, so history is recorded against branch "ALL" (see businessWideKey).
+	run := r.startRun(repos.WatermarkClientsCSV)
+	defer run.finish(&err)
+	rb, ok, err := run.lockBranch(ctx, r.businessWideKey())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	wr := run.watermarks()
 
 	// --- 1) Read watermark
-	last, err := wr.GetLastUpdated("clients_csv", "ALL")
+	last, err := wr.GetLastUpdated(repos.WatermarkClientsCSV, r.businessWideKey())
 	if err != nil {
 		return fmt.Errorf("get clients_csv watermark: %w", err)
 	}
@@ -40,53 +51,37 @@ func (r *Runner) RunIncrementalClientsSync(ctx context.Context) error {
 	b := r.Cfg.Branches[0]
 	lg.Printf("🏢 Using branch %s (%s) for CLIENT_CSV", b.Name, b.BranchID)
 
-	// --- 3) Create CSV export job
-	job, err := r.Export.CreateCSVExport(
-		ctx,
-		b.BranchID,
-		JobTypeClientsCSV, // "CLIENT_CSV"
-		filterExpr,
-		"", // startFilter – not required for clients
-		"", // finishFilter
-	)
-	if err != nil {
-		return fmt.Errorf("create CLIENT_CSV export: %w", err)
-	}
-	lg.Printf("📝 Created CLIENT_CSV job %s (%s)", job.JobID, job.JobStatus)
-
-	// --- 4) Poll job
-	waitMax := 5 * time.Minute
-	final, err := r.Export.WaitForCSVJob(
-		r.Cfg.PhorestBusiness,
-		b.BranchID,
-		job.JobID,
-		waitMax,
-	)
-	if err != nil {
-		return fmt.Errorf("wait for job %s: %w", job.JobID, err)
-	}
-
-	if final.TempCSVExternalURL == nil || *final.TempCSVExternalURL == "" {
-		return fmt.Errorf("job %s DONE but no csv URL", job.JobID)
-	}
-	lg.Printf("📥 job done, URL received")
-
-	// --- 5) Download CSV to export dir
+	// --- 3) Create (or resume) the export job, wait for it and download
 	filename := time.Now().UTC().Format("clients_incremental_20060102_150405.csv")
 	dest := filepath.Join(r.Cfg.ExportDir, filename)
 
-	if err := r.Export.DownloadCSV(*final.TempCSVExternalURL, dest); err != nil {
-		return fmt.Errorf("download csv: %w", err)
+	job, err := r.fetchCSVExport(ctx, csvExportSpec{
+		Entity:     repos.WatermarkClientsCSV,
+		BranchID:   b.BranchID,
+		JobType:    JobTypeClientsCSV, // "CLIENT_CSV"
+		FilterExpr: filterExpr,
+		// startFilter / finishFilter – not required for clients
+	}, dest)
+	if err != nil {
+		return fmt.Errorf("CLIENT_CSV export: %w", err)
 	}
 	lg.Printf("💾 Saved CLIENT_CSV to %s", dest)
 
 	// --- 6) Re-use your existing CSV import logic
-	if err := r.importSingleClientsCSV(dest); err != nil {
+	n, err := r.importSingleClientsCSV(dest, wr, run.id())
+	switch {
+	case errors.Is(err, errAlreadyImported):
+		lg.Printf("⏭  %v", err)
+	case err != nil:
 		return fmt.Errorf("import incremental clients csv: %w", err)
 	}
+	rb.fetched(n)
+	rb.upserted(n)
+
+	r.markCSVImported(job)
 
 	// Archive this CSV into the bootstrap clients dir
-	r.archiveCSVToSeed(dest, "data/clients")
+	r.archiveCSVToSeed(ctx, dest, archiveClients)
 
 	lg.Printf("✅ Incremental CLIENT_CSV sync finished")
 	return nil
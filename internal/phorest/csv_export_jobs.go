@@ -0,0 +1,158 @@
+package phorest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+const (
+	// csvJobWait is how long one run polls a job before leaving it for the
+	// next run to resume.
+	csvJobWait = 5 * time.Minute
+
+	// csvJobResumeMaxAge bounds how old a job may be and still be resumed;
+	// Phorest's temp download URLs don't live much longer than this.
+	csvJobResumeMaxAge = 24 * time.Hour
+
+	// csvProgressEvery throttles poll progress lines; status changes always log.
+	csvProgressEvery = 30 * time.Second
+
+	noRecordsFound = "No records found"
+)
+
+var (
+	// errCSVNoRecords is Phorest failing a job because the window was empty.
+	errCSVNoRecords = errors.New("no records found")
+
+	// errCSVNoURL is a DONE job without a download URL.
+	errCSVNoURL = errors.New("job DONE but no csv URL")
+)
+
+// csvExportSpec identifies one export window. Two runs asking for the same
+// spec get the same Phorest job.
+type csvExportSpec struct {
+	Entity       repos.WatermarkEntity
+	BranchID     string
+	JobType      string
+	FilterExpr   string
+	StartFilter  string
+	FinishFilter string
+}
+
+// fetchCSVExport downloads the CSV for spec into dest. It resumes a job an
+// earlier run created for the same window if there is one, otherwise queues
+// a new export; either way the job is tracked in core.csv_export_jobs.
+// Callers mark the returned job imported once the CSV is in.
+//
+// A job still running after csvJobWait is left pending and an error returned,
+// so the next run picks it up instead of queueing a duplicate.
+func (r *Runner) fetchCSVExport(ctx context.Context, spec csvExportSpec, dest string) (*models.CSVExportJob, error) {
+	lg := r.Logger
+	jobs := repos.NewCSVExportJobsRepo(r.DB, lg)
+
+	job, err := jobs.FindResumable(string(spec.Entity), spec.BranchID, spec.StartFilter, spec.FinishFilter, spec.FilterExpr, csvJobResumeMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("look up pending %s jobs: %w", spec.JobType, err)
+	}
+
+	if job != nil {
+		lg.Printf("🔁 %s: resuming %s job %s (%s, created %s)",
+			spec.BranchID, spec.JobType, job.JobID, job.Status, job.CreatedAt.Format(time.RFC3339))
+
+		err := r.awaitAndDownload(ctx, jobs, job, dest)
+		var dead *downloadStatusError
+		if !errors.Is(err, ErrCSVJobNotFound) && !errors.As(err, &dead) {
+			return job, err
+		}
+
+		// Phorest has forgotten the job or its URL is dead: start over.
+		lg.Printf("⚠️ %s: job %s can't be resumed (%v); creating a new one", spec.BranchID, job.JobID, err)
+		if merr := jobs.MarkExpired(job, err.Error()); merr != nil {
+			return nil, fmt.Errorf("mark job %s expired: %w", job.JobID, merr)
+		}
+	}
+
+	created, err := r.Export.CreateCSVExport(ctx, spec.BranchID, spec.JobType, spec.FilterExpr, spec.StartFilter, spec.FinishFilter)
+	if err != nil {
+		return nil, fmt.Errorf("create %s export: %w", spec.JobType, err)
+	}
+	lg.Printf("📝 %s: created %s job %s (%s)", spec.BranchID, spec.JobType, created.JobID, created.JobStatus)
+
+	job = &models.CSVExportJob{
+		Entity:           string(spec.Entity),
+		BranchID:         spec.BranchID,
+		JobType:          spec.JobType,
+		JobID:            created.JobID,
+		StartFilter:      spec.StartFilter,
+		FinishFilter:     spec.FinishFilter,
+		FilterExpression: spec.FilterExpr,
+		Status:           created.JobStatus,
+	}
+	if job.Status == "" {
+		job.Status = models.CSVExportQueued
+	}
+	if err := jobs.Record(job); err != nil {
+		return nil, fmt.Errorf("record %s job %s: %w", spec.JobType, created.JobID, err)
+	}
+
+	return job, r.awaitAndDownload(ctx, jobs, job, dest)
+}
+
+func (r *Runner) awaitAndDownload(ctx context.Context, jobs *repos.CSVExportJobsRepo, job *models.CSVExportJob, dest string) error {
+	lg := r.Logger
+
+	var (
+		lastStatus string
+		lastLog    time.Time
+	)
+	progress := func(p ExportProgress) {
+		if p.Status != lastStatus || time.Since(lastLog) >= csvProgressEvery {
+			lg.Printf("⏳ %s: %s", job.BranchID, p)
+			lastStatus, lastLog = p.Status, time.Now()
+		}
+	}
+
+	final, err := r.Export.WaitForCSVJob(ctx, r.Cfg.PhorestBusiness, job.BranchID, job.JobID, csvJobWait, progress)
+	if final != nil {
+		if uerr := jobs.UpdateStatus(job, final.JobStatus, final.FailureReason, final.TotalRows); uerr != nil {
+			lg.Printf("⚠️ %s: failed to record status of job %s: %v", job.BranchID, job.JobID, uerr)
+		}
+	}
+	if err != nil {
+		if final != nil && final.FailureReason != nil && *final.FailureReason == noRecordsFound {
+			return errCSVNoRecords
+		}
+		if final == nil && !errors.Is(err, ErrCSVJobNotFound) {
+			lg.Printf("⏳ %s: job %s not finished (%v); leaving it for the next run to resume", job.BranchID, job.JobID, err)
+		}
+		return fmt.Errorf("wait for %s job %s: %w", job.JobType, job.JobID, err)
+	}
+
+	if final.TempCSVExternalURL == nil || *final.TempCSVExternalURL == "" {
+		return fmt.Errorf("%w (job %s)", errCSVNoURL, job.JobID)
+	}
+	lg.Printf("📥 %s: job %s DONE, URL received", job.BranchID, job.JobID)
+
+	res, err := r.Export.DownloadCSV(ctx, *final.TempCSVExternalURL, dest, DefaultDownloadBudget())
+	if err != nil {
+		return fmt.Errorf("download csv for job %s: %w", job.JobID, err)
+	}
+	lg.Printf("💾 %s: downloaded %d bytes, %d rows", job.BranchID, res.Bytes, res.Rows)
+	if final.TotalRows != nil && int(*final.TotalRows) != res.Rows {
+		lg.Printf("ℹ️ %s: job %s reported %d rows, file has %d", job.BranchID, job.JobID, *final.TotalRows, res.Rows)
+	}
+	return nil
+}
+
+// markCSVImported closes job after a successful import. Failing to record it
+// only means the next run downloads the same CSV again, so it's not fatal.
+func (r *Runner) markCSVImported(job *models.CSVExportJob) {
+	if err := repos.NewCSVExportJobsRepo(r.DB, r.Logger).MarkImported(job); err != nil {
+		r.Logger.Printf("⚠️ %s: failed to mark job %s imported: %v", job.BranchID, job.JobID, err)
+	}
+}
@@ -0,0 +1,184 @@
+package phorest
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+)
+
+// csvType checks one CSV cell. Empty cells always pass (they map to zero /
+// NULL on purpose); check == nil accepts anything.
+type csvType struct {
+	name  string
+	check func(s string) error
+}
+
+// Layouts the readers' parsers accept, shared so validation and parsing
+// can't drift apart.
+var (
+	csvDateLayouts  = []string{"2006-01-02"}
+	csvClockLayouts = []string{"15:04:05.000", "15:04:05", "15:04"}
+	csvTSLayouts    = []string{
+		time.RFC3339Nano,
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05.000",
+		"2006-01-02T15:04:05",
+	}
+	csvDateOrTSLayouts = append(slices.Clone(csvDateLayouts), csvTSLayouts...)
+)
+
+var (
+	colText   = csvType{name: "text"}
+	colNumber = csvType{name: "number", check: func(s string) error {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("not a number")
+		}
+		return nil
+	}}
+	colInteger = csvType{name: "integer", check: func(s string) error {
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return fmt.Errorf("not an integer")
+		}
+		return nil
+	}}
+	colBoolean = csvType{name: "boolean", check: func(s string) error {
+		switch strings.ToLower(s) {
+		case "1", "0", "true", "false", "t", "f", "yes", "no", "y", "n":
+			return nil
+		}
+		return fmt.Errorf("not a boolean")
+	}}
+	colDate      = csvLayoutType("date", csvDateLayouts...)
+	colClock     = csvLayoutType("time", csvClockLayouts...)
+	colTimestamp = csvLayoutType("timestamp", csvTSLayouts...)
+
+	colDateOrTimestamp = csvLayoutType("date or timestamp", csvDateOrTSLayouts...)
+)
+
+func csvLayoutType(name string, layouts ...string) csvType {
+	return csvType{name: name, check: func(s string) error {
+		if _, ok := parseLayouts(s, layouts); ok {
+			return nil
+		}
+		return fmt.Errorf("want %s", strings.Join(layouts, " | "))
+	}}
+}
+
+// parseLayouts tries each layout in turn.
+func parseLayouts(s string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// csvColumn declares one column of an export. Required columns must be in
+// the header and non-empty on every row.
+type csvColumn struct {
+	Name     string
+	Type     csvType
+	Required bool
+}
+
+// csvSchema is the declared column set of one CSV export type. Name is
+// recorded as raw.import_rejects.source.
+type csvSchema struct {
+	Name    string
+	Columns []csvColumn
+}
+
+// csvValidator checks the rows of one file against a schema.
+type csvValidator struct {
+	schema csvSchema
+	file   string
+	idx    map[string]int // declared column → header position, present ones only
+}
+
+// validator compares header with the schema, logs unknown and missing
+// columns, and fails if a required column is missing.
+func (s csvSchema) validator(file string, header []string, lg *log.Logger) (*csvValidator, error) {
+	v := &csvValidator{schema: s, file: file, idx: make(map[string]int, len(s.Columns))}
+
+	seen := make(map[string]int, len(header))
+	for i, h := range header {
+		seen[strings.TrimSpace(strings.ToLower(h))] = i
+	}
+
+	var missing, missingRequired, unknown []string
+	for _, c := range s.Columns {
+		i, ok := seen[c.Name]
+		if !ok {
+			missing = append(missing, c.Name)
+			if c.Required {
+				missingRequired = append(missingRequired, c.Name)
+			}
+			continue
+		}
+		v.idx[c.Name] = i
+	}
+	for name := range seen {
+		if _, ok := v.idx[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+
+	if len(unknown) > 0 {
+		lg.Printf("⚠️ %s %s: %d unknown column(s), ignored (did Phorest change the export?): %s",
+			s.Name, file, len(unknown), strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		lg.Printf("ℹ️ %s %s: %d declared column(s) not in this file: %s",
+			s.Name, file, len(missing), strings.Join(missing, ", "))
+	}
+	if len(missingRequired) > 0 {
+		return nil, fmt.Errorf("%s %s: missing required column(s): %s",
+			s.Name, file, strings.Join(missingRequired, ", "))
+	}
+	return v, nil
+}
+
+// validate returns one reject per bad cell of rec, found at line; a row
+// with any is kept out of the import.
+func (v *csvValidator) validate(rec []string, line int) []models.ImportReject {
+	var out []models.ImportReject
+	for _, c := range v.schema.Columns {
+		raw := ""
+		if i, ok := v.idx[c.Name]; ok && i < len(rec) {
+			raw = rec[i]
+		}
+		s := strings.TrimSpace(raw)
+
+		var err error
+		switch {
+		case s == "" && c.Required:
+			err = fmt.Errorf("required value missing")
+		case s == "" || c.Type.check == nil:
+			continue
+		default:
+			if err = c.Type.check(s); err != nil {
+				err = fmt.Errorf("invalid %s: %w", c.Type.name, err)
+			}
+		}
+		if err == nil {
+			continue
+		}
+		out = append(out, models.ImportReject{
+			Source:     v.schema.Name,
+			File:       v.file,
+			Line:       line,
+			ColumnName: c.Name,
+			RawValue:   raw,
+			Error:      err.Error(),
+		})
+	}
+	return out
+}
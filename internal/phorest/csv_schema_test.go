@@ -0,0 +1,220 @@
+package phorest
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+)
+
+var testSchema = csvSchema{
+	Name: "test_csv",
+	Columns: []csvColumn{
+		{Name: "id", Type: colText, Required: true},
+		{Name: "amount", Type: colNumber},
+		{Name: "count", Type: colInteger},
+		{Name: "day", Type: colDate},
+		{Name: "at", Type: colTimestamp},
+		{Name: "flag", Type: colBoolean},
+	},
+}
+
+func TestCSVSchemaValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		wantErr string   // "" = no error
+		wantLog []string // substrings of the log
+	}{
+		{
+			name:   "every column",
+			header: []string{"id", "amount", "count", "day", "at", "flag"},
+		},
+		{
+			name:   "header matched ignoring case and spaces",
+			header: []string{" ID ", "Amount"},
+		},
+		{
+			name:    "missing required column",
+			header:  []string{"amount", "day"},
+			wantErr: "missing required column(s): id",
+		},
+		{
+			name:    "unknown columns only warn",
+			header:  []string{"id", "zeta", "amount", "alpha"},
+			wantLog: []string{"2 unknown column(s), ignored", "alpha, zeta"},
+		},
+		{
+			name:    "missing optional columns are noted",
+			header:  []string{"id"},
+			wantLog: []string{"5 declared column(s) not in this file: amount, count, day, at, flag"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			v, err := testSchema.validator("f.csv", tt.header, log.New(&buf, "", 0))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validator() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || v == nil {
+				t.Fatalf("validator() = %v, %v", v, err)
+			}
+			for _, want := range tt.wantLog {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("log %q, want it to contain %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVValidatorValidate(t *testing.T) {
+	header := []string{"id", "amount", "count", "day", "at", "flag"}
+	v, err := testSchema.validator("f.csv", header, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reject := func(col, raw, msg string) models.ImportReject {
+		return models.ImportReject{Source: "test_csv", File: "f.csv", Line: 7, ColumnName: col, RawValue: raw, Error: msg}
+	}
+
+	tests := []struct {
+		name string
+		rec  []string
+		want []models.ImportReject
+	}{
+		{
+			name: "valid row",
+			rec:  []string{"a1", "-12.50", "3", "2024-01-31", "2024-01-31T09:15:00Z", "true"},
+		},
+		{
+			name: "empty optional cells pass",
+			rec:  []string{"a1", "", " ", "", "", ""},
+		},
+		{
+			name: "short row: missing cells are empty",
+			rec:  []string{"a1"},
+		},
+		{
+			name: "bad number",
+			rec:  []string{"a1", "12,50"},
+			want: []models.ImportReject{reject("amount", "12,50", "invalid number: not a number")},
+		},
+		{
+			name: "fraction where an integer is declared",
+			rec:  []string{"a1", "", "1.5"},
+			want: []models.ImportReject{reject("count", "1.5", "invalid integer: not an integer")},
+		},
+		{
+			name: "bad date keeps the raw cell",
+			rec:  []string{"a1", "", "", " 31/01/2024"},
+			want: []models.ImportReject{reject("day", " 31/01/2024", "invalid date: want 2006-01-02")},
+		},
+		{
+			name: "required value missing",
+			rec:  []string{"  ", "1"},
+			want: []models.ImportReject{reject("id", "  ", "required value missing")},
+		},
+		{
+			name: "every bad cell, in column order",
+			rec:  []string{"a1", "lots", "", "", "yesterday", "maybe"},
+			want: []models.ImportReject{
+				reject("amount", "lots", "invalid number: not a number"),
+				reject("at", "yesterday", "invalid timestamp: want "+strings.Join(csvTSLayouts, " | ")),
+				reject("flag", "maybe", "invalid boolean: not a boolean"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.validate(tt.rec, 7); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate(%q)\n got %+v\nwant %+v", tt.rec, got, tt.want)
+			}
+		})
+	}
+}
+
+// A row with a rejected cell must not reach the import with a zero in its
+// place.
+func TestCSVRejectedRowsKeptOut(t *testing.T) {
+	lg := log.New(&bytes.Buffer{}, "", 0)
+	write := func(name, contents string) string {
+		t.Helper()
+		p := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(p, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	t.Run("clients", func(t *testing.T) {
+		p := write("clients.csv", "client_id,version,birth_date\n"+
+			"c1,1,1990-05-01\n"+
+			"c2,x,1990-05-01\n"+
+			"c3,2,01/05/1990\n"+
+			"c4,3,\n")
+		batch, err := ParseClientsCSV(p, lg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, c := range batch.Clients {
+			ids = append(ids, c.ClientID)
+		}
+		slices.Sort(ids) // deduplicated through a map: no row order
+		if want := []string{"c1", "c4"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("clients = %v, want %v", ids, want)
+		}
+		if got := rejectCells(batch.Rejects); !reflect.DeepEqual(got, []string{"3 version x", "4 birth_date 01/05/1990"}) {
+			t.Errorf("rejects = %v", got)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		p := write("transactions.csv", "transaction_id,transaction_item_id,quantity,total_amount\n"+
+			"t1,i1,1,10.00\n"+
+			"t1,i2,1,£10.00\n"+
+			"t2,i3,two,5\n"+
+			"t3,i4,2,20\n")
+		var items []string
+		var rejects []models.ImportReject
+		err := StreamTransactionsCSV(p, 10, lg, func(b *ParsedBatch) error {
+			for _, it := range b.Items {
+				items = append(items, it.TransactionItemID)
+			}
+			rejects = append(rejects, b.Rejects...)
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"i1", "i4"}; !reflect.DeepEqual(items, want) {
+			t.Errorf("items = %v, want %v", items, want)
+		}
+		if got := rejectCells(rejects); !reflect.DeepEqual(got, []string{"3 total_amount £10.00", "4 quantity two"}) {
+			t.Errorf("rejects = %v", got)
+		}
+	})
+}
+
+// rejectCells renders rejects as "line column raw".
+func rejectCells(rejects []models.ImportReject) []string {
+	var out []string
+	for _, r := range rejects {
+		out = append(out, fmt.Sprintf("%d %s %s", r.Line, r.ColumnName, r.RawValue))
+	}
+	return out
+}
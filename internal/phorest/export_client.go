@@ -3,7 +3,9 @@ package phorest
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,6 +18,9 @@ const (
 	JobTypeClientsCSV      = "CLIENT_CSV"
 )
 
+// ErrCSVJobNotFound means Phorest no longer knows the job (404 on poll).
+var ErrCSVJobNotFound = errors.New("CSV export job not found")
+
 type ExportRequest struct {
 	JobType          string `json:"jobType"`
 	StartFilter      string `json:"startFilter,omitempty"`
@@ -40,16 +45,16 @@ type ExportResponse struct {
 
 type ExportClient struct {
 	http     *http.Client
+	baseURL  string // API root including /api
 	username string
 	password string
 	business string
 }
 
-func NewExportClient(username, password, business string) *ExportClient {
+func NewExportClient(baseURL, username, password, business string) *ExportClient {
 	return &ExportClient{
-		http: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		http:     SharedHTTPClient(),
+		baseURL:  apiRoot(baseURL),
 		username: username,
 		password: password,
 		business: business,
@@ -66,8 +71,8 @@ func (c *ExportClient) CreateCSVExport(
 ) (*ExportResponse, error) {
 
 	url := fmt.Sprintf(
-		"https://api-gateway-eu.phorest.com/third-party-api-server/api/business/%s/branch/%s/csvexportjob",
-		c.business, branchID,
+		"%s/business/%s/branch/%s/csvexportjob",
+		c.baseURL, c.business, branchID,
 	)
 
 	reqPayload := ExportRequest{
@@ -107,87 +112,214 @@ func (c *ExportClient) CreateCSVExport(
 	return &out, nil
 }
 
-// Wait for a job to finish:
+// ExportProgress is reported after every poll of a CSV export job.
+type ExportProgress struct {
+	JobID         string
+	Status        string
+	Polls         int
+	Elapsed       time.Duration
+	TotalRows     *int32
+	SucceededRows *int32
+}
+
+func (p ExportProgress) String() string {
+	s := fmt.Sprintf("job %s %s after %s (%d polls)", p.JobID, p.Status, p.Elapsed.Round(time.Second), p.Polls)
+	if p.TotalRows != nil {
+		done := int32(0)
+		if p.SucceededRows != nil {
+			done = *p.SucceededRows
+		}
+		s += fmt.Sprintf(", rows %d/%d", done, *p.TotalRows)
+	}
+	return s
+}
+
+// WaitForCSVJob polls a job until it is DONE or FAILED, ctx is cancelled or
+// maxWait passes. progress (may be nil) is called after every poll.
 func (c *ExportClient) WaitForCSVJob(
+	ctx context.Context,
 	businessID string,
 	branchID string,
 	jobID string,
 	maxWait time.Duration,
+	progress func(ExportProgress),
 ) (*ExportResponse, error) {
 
 	url := fmt.Sprintf(
-		"https://api-gateway-eu.phorest.com/third-party-api-server/api/business/%s/branch/%s/csvexportjob/%s",
-		businessID, branchID, jobID,
+		"%s/business/%s/branch/%s/csvexportjob/%s",
+		c.baseURL, businessID, branchID, jobID,
 	)
 
-	deadline := time.Now().Add(maxWait)
-	backoff := 2 * time.Second
-
-	for {
-		if time.Now().After(deadline) {
-			return nil, fmt.Errorf("timeout waiting for job %s", jobID)
-		}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
 
-		req, _ := http.NewRequest("GET", url, nil)
-		req.SetBasicAuth(c.username, c.password)
-		req.Header.Set("Accept", "application/json")
+	start := time.Now()
+	backoff := 2 * time.Second
 
-		res, err := c.http.Do(req)
+	for polls := 1; ; polls++ {
+		out, err := c.pollCSVJob(ctx, url, jobID)
 		if err != nil {
-			return nil, fmt.Errorf("poll failed: %w", err)
-		}
-		b, _ := io.ReadAll(res.Body)
-		res.Body.Close()
-
-		if res.StatusCode < 200 || res.StatusCode > 299 {
-			return nil, fmt.Errorf("poll non-2xx: %s — %s", res.Status, string(b))
+			if ctx.Err() != nil {
+				return nil, waitErr(parent, jobID, maxWait)
+			}
+			return nil, err
 		}
 
-		var out ExportResponse
-		if err := json.Unmarshal(b, &out); err != nil {
-			return nil, fmt.Errorf("decode poll: %w — body=%s", err, string(b))
+		if progress != nil {
+			progress(ExportProgress{
+				JobID:         jobID,
+				Status:        out.JobStatus,
+				Polls:         polls,
+				Elapsed:       time.Since(start),
+				TotalRows:     out.TotalRows,
+				SucceededRows: out.SucceededRows,
+			})
 		}
 
 		switch out.JobStatus {
 		case "DONE":
-			return &out, nil
+			return out, nil
 
 		case "FAILED":
 			// 👇 this is the important bit
 			if out.FailureReason != nil && *out.FailureReason != "" {
-				return &out, fmt.Errorf(
+				return out, fmt.Errorf(
 					"CSV job FAILED: %s — reason: %s",
 					jobID, *out.FailureReason,
 				)
 			}
-			return &out, fmt.Errorf("CSV job FAILED: %s", jobID)
+			return out, fmt.Errorf("CSV job FAILED: %s", jobID)
+		}
 
-		default:
-			time.Sleep(backoff)
-			if backoff < 10*time.Second {
-				backoff += 2 * time.Second
-			}
+		select {
+		case <-ctx.Done():
+			return nil, waitErr(parent, jobID, maxWait)
+		case <-time.After(backoff):
+		}
+		if backoff < 10*time.Second {
+			backoff += 2 * time.Second
 		}
 	}
 }
 
-// Download the CSV (signed URL is usually enough)
-func (c *ExportClient) DownloadCSV(url string, outPath string) error {
+func (c *ExportClient) pollCSVJob(ctx context.Context, url, jobID string) (*ExportResponse, error) {
+	// The shared client has no overall timeout, so bound each poll here.
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("poll failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("poll read: %w", err)
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrCSVJobNotFound, jobID)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("poll non-2xx: %s — %s", res.Status, string(b))
+	}
+
+	var out ExportResponse
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("decode poll: %w — body=%s", err, string(b))
+	}
+	return &out, nil
+}
+
+// waitErr tells the caller giving up (parent done) apart from our own maxWait.
+func waitErr(parent context.Context, jobID string, maxWait time.Duration) error {
+	if err := parent.Err(); err != nil {
+		return fmt.Errorf("waiting for job %s: %w", jobID, err)
+	}
+	return fmt.Errorf("timeout waiting for job %s after %s: %w", jobID, maxWait, context.DeadlineExceeded)
+}
+
+// DownloadBudget caps a single CSV download.
+type DownloadBudget struct {
+	MaxBytes int64         // 0 = unlimited
+	Timeout  time.Duration // 0 = only the caller's ctx
+}
+
+// DefaultDownloadBudget reads CSV_DOWNLOAD_MAX_MB (default 2048) and
+// CSV_DOWNLOAD_TIMEOUT (default 10m).
+func DefaultDownloadBudget() DownloadBudget {
+	return DownloadBudget{
+		MaxBytes: int64(getIntEnv("CSV_DOWNLOAD_MAX_MB", 2048)) << 20,
+		Timeout:  getDurationEnv("CSV_DOWNLOAD_TIMEOUT", 10*time.Minute),
+	}
+}
+
+// ErrDownloadTooLarge means the CSV exceeded DownloadBudget.MaxBytes.
+var ErrDownloadTooLarge = errors.New("download exceeds size budget")
+
+// DownloadResult describes a verified download.
+type DownloadResult struct {
+	Bytes int64
+	Rows  int // data rows, header excluded
+}
+
+// DownloadCSV streams url to outPath within budget, then checks the file
+// parses as CSV before it is moved into place, so a truncated or HTML error
+// body never reaches the importer. The signed URL is tried first, then with
+// BasicAuth.
+func (c *ExportClient) DownloadCSV(ctx context.Context, url string, outPath string, budget DownloadBudget) (DownloadResult, error) {
+	if budget.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget.Timeout)
+		defer cancel()
+	}
+
+	part := outPath + ".part"
+	defer os.Remove(part) // no-op once renamed
 
 	// first try unsigned
-	if err := c.tryDownload(url, outPath, false); err == nil {
-		return nil
+	n, err := c.tryDownload(ctx, url, part, false, budget.MaxBytes)
+	var statusErr *downloadStatusError
+	if errors.As(err, &statusErr) {
+		// fallback: BasicAuth
+		n, err = c.tryDownload(ctx, url, part, true, budget.MaxBytes)
+	}
+	if err != nil {
+		return DownloadResult{}, err
 	}
 
-	// fallback: BasicAuth
-	return c.tryDownload(url, outPath, true)
+	rows, err := VerifyCSV(part)
+	if err != nil {
+		return DownloadResult{}, fmt.Errorf("downloaded file is not valid CSV: %w", err)
+	}
+	if err := os.Rename(part, outPath); err != nil {
+		return DownloadResult{}, err
+	}
+	return DownloadResult{Bytes: n, Rows: rows}, nil
+}
+
+type downloadStatusError struct {
+	status string
+	body   string
 }
 
-func (c *ExportClient) tryDownload(url string, outPath string, withAuth bool) error {
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("download non-2xx: %s — %s", e.status, e.body)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+func (c *ExportClient) tryDownload(ctx context.Context, url string, outPath string, withAuth bool, maxBytes int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if withAuth {
@@ -196,21 +328,67 @@ func (c *ExportClient) tryDownload(url string, outPath string, withAuth bool) er
 
 	res, err := c.http.Do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		b, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("download non-2xx: %s — %s", res.Status, string(b))
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return 0, &downloadStatusError{status: res.Status, body: string(b)}
+	}
+	if maxBytes > 0 && res.ContentLength > maxBytes {
+		return 0, fmt.Errorf("%w: Content-Length %d > %d bytes", ErrDownloadTooLarge, res.ContentLength, maxBytes)
 	}
 
 	f, err := os.Create(outPath)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer f.Close()
 
-	_, err = io.Copy(f, res.Body)
-	return err
+	var body io.Reader = res.Body
+	if maxBytes > 0 {
+		body = io.LimitReader(res.Body, maxBytes+1)
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return n, fmt.Errorf("download: %w", err)
+	}
+	if maxBytes > 0 && n > maxBytes {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrDownloadTooLarge, maxBytes)
+	}
+	return n, f.Close()
+}
+
+// VerifyCSV reads the whole file as CSV and returns the number of data rows.
+// It fails on an empty file, a parse error or a row whose field count
+// differs from the header.
+func VerifyCSV(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.ReuseRecord = true
+
+	if _, err := r.Read(); err != nil {
+		if err == io.EOF {
+			return 0, errors.New("empty file")
+		}
+		return 0, fmt.Errorf("header: %w", err)
+	}
+
+	rows := 0
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows++
+	}
 }
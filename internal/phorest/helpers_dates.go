@@ -24,3 +24,20 @@ func firstDayOfNextMonth(t time.Time) time.Time {
 func dayBefore(d time.Time) time.Time {
 	return dateOnly(d.AddDate(0, 0, -1))
 }
+
+// splitWindow cuts [from, to] into consecutive spans of at most size. Each
+// span starts where the previous one ended.
+func splitWindow(from, to time.Time, size time.Duration) [][2]time.Time {
+	if size <= 0 || !to.After(from) {
+		return [][2]time.Time{{from, to}}
+	}
+	var out [][2]time.Time
+	for start := from; start.Before(to); start = start.Add(size) {
+		end := start.Add(size)
+		if end.After(to) {
+			end = to
+		}
+		out = append(out, [2]time.Time{start, end})
+	}
+	return out
+}
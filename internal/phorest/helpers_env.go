@@ -8,6 +8,13 @@ import (
 	"time"
 )
 
+func getStringEnv(key, def string) string {
+	if raw := strings.TrimSpace(os.Getenv(key)); raw != "" {
+		return raw
+	}
+	return def
+}
+
 func getIntEnv(key string, def int) int {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -20,6 +27,30 @@ func getIntEnv(key string, def int) int {
 	return n
 }
 
+func getFloatEnv(key string, def float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil || f < 0 {
+		return def
+	}
+	return f
+}
+
+func getDurationEnv(key string, def time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
 func getDateEnv(key string) (*time.Time, error) {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -0,0 +1,282 @@
+package phorest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/config"
+	"golang.org/x/time/rate"
+)
+
+// HTTPOptions tunes the shared Phorest transport.
+type HTTPOptions struct {
+	// RPS / Burst is the global request budget shared by every client and
+	// every concurrent branch sync in this process (Phorest throttles per
+	// business, not per connection). RPS <= 0 disables the limiter.
+	RPS   float64
+	Burst int
+
+	// MaxRetries is how many times an idempotent request (GET/HEAD) is
+	// retried after a network error, 429 or 5xx.
+	MaxRetries int
+
+	// Backoff grows exponentially from BaseBackoff to MaxBackoff with full
+	// jitter. A Retry-After header wins over the computed delay (capped at
+	// MaxRetryAfter).
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration
+
+	// ResponseHeaderTimeout bounds each attempt up to the response headers;
+	// the body (e.g. a large CSV download) is bounded by the caller's ctx.
+	ResponseHeaderTimeout time.Duration
+}
+
+// DefaultHTTPOptions reads PHOREST_RPS, PHOREST_BURST and PHOREST_MAX_RETRIES,
+// falling back to conservative defaults.
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		RPS:                   getFloatEnv("PHOREST_RPS", 5),
+		Burst:                 getIntEnv("PHOREST_BURST", 5),
+		MaxRetries:            getIntEnv("PHOREST_MAX_RETRIES", 4),
+		BaseBackoff:           500 * time.Millisecond,
+		MaxBackoff:            30 * time.Second,
+		MaxRetryAfter:         2 * time.Minute,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+}
+
+// HTTPStats is a snapshot of the transport counters.
+type HTTPStats struct {
+	Requests    int64 // attempts actually sent (including retries)
+	Retries     int64 // attempts that were a retry of an earlier one
+	Throttled   int64 // 429 responses from Phorest
+	ServerErrs  int64 // 5xx responses from Phorest
+	LimiterWait time.Duration
+}
+
+// Sub returns the counters accumulated since an earlier snapshot.
+func (s HTTPStats) Sub(prev HTTPStats) HTTPStats {
+	return HTTPStats{
+		Requests:    s.Requests - prev.Requests,
+		Retries:     s.Retries - prev.Retries,
+		Throttled:   s.Throttled - prev.Throttled,
+		ServerErrs:  s.ServerErrs - prev.ServerErrs,
+		LimiterWait: s.LimiterWait - prev.LimiterWait,
+	}
+}
+
+func (s HTTPStats) String() string {
+	return fmt.Sprintf("requests=%d retries=%d throttled=%d 5xx=%d limiter_wait=%s",
+		s.Requests, s.Retries, s.Throttled, s.ServerErrs, s.LimiterWait.Round(time.Millisecond))
+}
+
+// retryTransport is the RoundTripper behind every Phorest client: global rate
+// limit, then retries with backoff for idempotent requests.
+type retryTransport struct {
+	base    http.RoundTripper
+	opts    HTTPOptions
+	limiter *rate.Limiter // nil = unlimited
+
+	requests    atomic.Int64
+	retries     atomic.Int64
+	throttled   atomic.Int64
+	serverErrs  atomic.Int64
+	limiterWait atomic.Int64 // nanoseconds
+}
+
+// NewHTTPClient builds an *http.Client on a retrying, rate-limited transport.
+// Most callers want SharedHTTPClient so the RPS budget is actually shared.
+func NewHTTPClient(opts HTTPOptions) *http.Client {
+	return &http.Client{Transport: newRetryTransport(opts)}
+}
+
+func newRetryTransport(opts HTTPOptions) *retryTransport {
+	t := &retryTransport{
+		base: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   20,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		},
+		opts: opts,
+	}
+	if opts.RPS > 0 {
+		burst := opts.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(opts.RPS), burst)
+	}
+	return t
+}
+
+var (
+	sharedOnce      sync.Once
+	sharedTransport *retryTransport
+	sharedClient    *http.Client
+)
+
+// SharedHTTPClient returns the process-wide Phorest client. No Client.Timeout
+// is set: it would cap the total time including retries, so callers bound the
+// whole call with their ctx instead.
+func SharedHTTPClient() *http.Client {
+	sharedOnce.Do(func() {
+		sharedTransport = newRetryTransport(DefaultHTTPOptions())
+		sharedClient = &http.Client{Transport: sharedTransport}
+	})
+	return sharedClient
+}
+
+// SharedHTTPStats returns the counters of the shared client.
+func SharedHTTPStats() HTTPStats {
+	SharedHTTPClient()
+	return sharedTransport.Stats()
+}
+
+func (t *retryTransport) Stats() HTTPStats {
+	return HTTPStats{
+		Requests:    t.requests.Load(),
+		Retries:     t.retries.Load(),
+		Throttled:   t.throttled.Load(),
+		ServerErrs:  t.serverErrs.Load(),
+		LimiterWait: time.Duration(t.limiterWait.Load()),
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retryable := isIdempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody)
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 {
+			t.retries.Add(1)
+		}
+		t.requests.Add(1)
+
+		resp, err := t.base.RoundTrip(req)
+
+		if resp != nil {
+			switch {
+			case resp.StatusCode == http.StatusTooManyRequests:
+				t.throttled.Add(1)
+			case resp.StatusCode >= 500:
+				t.serverErrs.Add(1)
+			}
+		}
+
+		if !retryable || attempt >= t.opts.MaxRetries || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if ra, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = min(ra, t.opts.MaxRetryAfter)
+			}
+			// Drain so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// wait blocks on the global RPS budget.
+func (t *retryTransport) wait(ctx context.Context) error {
+	if t.limiter == nil {
+		return nil
+	}
+	start := time.Now()
+	err := t.limiter.Wait(ctx)
+	t.limiterWait.Add(int64(time.Since(start)))
+	return err
+}
+
+// backoff is exponential with full jitter: rand[0, min(max, base*2^attempt)).
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.opts.BaseBackoff << attempt
+	if d <= 0 || d > t.opts.MaxBackoff {
+		d = t.opts.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true // network error / header timeout
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// apiRoot turns a configured base URL (config.Config.PhorestBaseURL) into the
+// "/api" root the clients build their paths on. Empty means the EU default.
+func apiRoot(baseURL string) string {
+	if baseURL == "" {
+		baseURL = config.DefaultPhorestBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + "/api"
+}
+
+// retryAfter parses a Retry-After header (delta-seconds or HTTP-date).
+func retryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,74 @@
+package phorest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/archive"
+	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/araquach/phorest-datahub/internal/repos"
+	"gorm.io/gorm"
+)
+
+// errAlreadyImported means core.import_ledger already has a file with the
+// same contents for the entity; the import is skipped.
+var errAlreadyImported = errors.New("already imported")
+
+// checkLedger hashes path and looks it up in core.import_ledger. Identical
+// contents imported before give errAlreadyImported. A file whose name was
+// imported before with other contents is flagged as changed. The returned
+// entry is recorded with recordImport in the import's transaction.
+func (r *Runner) checkLedger(entity, path string, runID *int64) (*models.ImportLedgerEntry, error) {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return nil, fmt.Errorf("checksum %s: %w", path, err)
+	}
+
+	ledger := repos.NewImportLedgerRepo(r.DB, r.Logger)
+	prev, err := ledger.FindBySHA256(entity, sum)
+	if err != nil {
+		return nil, fmt.Errorf("check import ledger: %w", err)
+	}
+	if prev != nil {
+		return nil, fmt.Errorf("%w: %s has the same contents as %s (ledger #%d, %s)",
+			errAlreadyImported, archive.OriginalName(path), prev.FileName, prev.ID, prev.ImportedAt.UTC().Format(time.RFC3339))
+	}
+
+	e := &models.ImportLedgerEntry{
+		Entity:    entity,
+		FileName:  archive.OriginalName(path),
+		Path:      path,
+		SHA256:    sum,
+		SizeBytes: size,
+		RunID:     runID,
+	}
+
+	named, err := ledger.LatestByName(entity, e.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("check import ledger: %w", err)
+	}
+	if named != nil {
+		e.Changed, e.PreviousSHA256 = true, &named.SHA256
+		r.Logger.Printf("🚩 %s changed since it was imported on %s (sha256 %.12s → %.12s); importing again",
+			e.FileName, named.ImportedAt.UTC().Format(time.RFC3339), named.SHA256, sum)
+	}
+	return e, nil
+}
+
+// recordImport adds e to the ledger through db, the import's transaction,
+// so a file is never committed without its entry (or the other way round).
+func (r *Runner) recordImport(db *gorm.DB, e *models.ImportLedgerEntry, rows, rejected int) error {
+	e.RowCount, e.RejectedCount = rows, rejected
+	if err := repos.NewImportLedgerRepo(db, r.Logger).Record(e); err != nil {
+		return fmt.Errorf("record %s in import ledger: %w", e.FileName, err)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex SHA-256 and size of the file at path, of its
+// uncompressed contents for .gz / .zst so an archived copy matches the
+// original import.
+func fileSHA256(path string) (string, int64, error) {
+	return archive.SHA256Size(path)
+}
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/araquach/phorest-datahub/internal/config"
 )
 
 type ProductsClient struct {
@@ -21,14 +23,14 @@ type ProductsClient struct {
 
 func NewProductsClient(baseURL, businessID, username, password string) *ProductsClient {
 	if baseURL == "" {
-		baseURL = "https://api-gateway-eu.phorest.com/third-party-api-server"
+		baseURL = config.DefaultPhorestBaseURL
 	}
 	return &ProductsClient{
 		BaseURL:    baseURL,
 		BusinessID: businessID,
 		Username:   username,
 		Password:   password,
-		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		HTTPClient: SharedHTTPClient(),
 	}
 }
 
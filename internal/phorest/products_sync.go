@@ -12,13 +12,16 @@ import (
 
 // SyncProductsFromAPI pulls products/stock for all configured branches
 // and writes to ph_products, ph_product_stock, and ph_product_stock_history.
-func (r *Runner) SyncProductsFromAPI() error {
+func (r *Runner) SyncProductsFromAPI(ctx context.Context) (err error) {
 	lg := r.Logger
 
 	lg.Println("🚿 Starting PRODUCTS sync from Phorest API…")
 
+	run := r.startRun(repos.WatermarkProductsAPI)
+	defer run.finish(&err)
+
 	pc := NewProductsClient(
-		"",
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestBusiness,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
@@ -26,9 +29,7 @@ func (r *Runner) SyncProductsFromAPI() error {
 
 	productRepo := repos.NewPhProductRepo(r.DB)
 	stockRepo := repos.NewPhProductStockRepo(r.DB)
-	watermarks := repos.NewWatermarksRepo(r.DB, r.Logger)
-
-	ctx := context.Background()
+	watermarks := run.watermarks()
 
 	productType := os.Getenv("PRODUCT_TYPE_FILTER") // "" = all
 	if productType == "" {
@@ -37,10 +38,17 @@ func (r *Runner) SyncProductsFromAPI() error {
 		lg.Printf("   PRODUCT_TYPE_FILTER=%s → syncing only this type", productType)
 	}
 
-	for _, b := range r.Cfg.Branches {
+	for _, b := range r.branches() {
 		lg.Printf("➡️  Syncing PRODUCTS for branch %s (ID: %s)", b.Name, b.BranchID)
+		rb, ok, err := run.lockBranch(ctx, b.BranchID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
 
-		wm, err := watermarks.GetLastUpdated("products_api", b.BranchID)
+		wm, err := watermarks.GetLastUpdated(repos.WatermarkProductsAPI, b.BranchID)
 		if err != nil {
 			return fmt.Errorf("get products watermark for %s: %w", b.BranchID, err)
 		}
@@ -48,7 +56,7 @@ func (r *Runner) SyncProductsFromAPI() error {
 		var updatedAfter, updatedBefore *time.Time
 
 		if wm != nil {
-			after := wm.UTC().Add(1 * time.Second)
+			after := wm.UTC().Add(productsWatermarkStep)
 			now := time.Now().UTC()
 			updatedAfter = &after
 			updatedBefore = &now
@@ -61,6 +69,7 @@ func (r *Runner) SyncProductsFromAPI() error {
 		maxUpdatedAt, err := r.syncProductsForBranch(
 			ctx,
 			pc,
+			rb,
 			productRepo,
 			stockRepo,
 			b.BranchID,
@@ -73,10 +82,12 @@ func (r *Runner) SyncProductsFromAPI() error {
 		}
 
 		if maxUpdatedAt != nil {
-			if err := watermarks.UpsertLastUpdated("products_api", b.BranchID, *maxUpdatedAt); err != nil {
+			if err := watermarks.UpsertLastUpdated(repos.WatermarkProductsAPI, b.BranchID, *maxUpdatedAt); err != nil {
 				return fmt.Errorf("update products_api watermark for %s: %w", b.BranchID, err)
 			}
 		}
+
+		rb.finish(nil)
 	}
 
 	lg.Println("✅ PRODUCTS sync complete for all branches.")
@@ -88,6 +99,7 @@ func (r *Runner) SyncProductsFromAPI() error {
 func (r *Runner) syncProductsForBranch(
 	ctx context.Context,
 	pc *ProductsClient,
+	rb *runBranch,
 	productRepo *repos.PhProductRepo,
 	stockRepo *repos.PhProductStockRepo,
 	branchID string,
@@ -116,11 +128,13 @@ func (r *Runner) syncProductsForBranch(
 		if len(resp.Embedded.Products) == 0 {
 			break
 		}
+		rb.fetched(len(resp.Embedded.Products))
 
 		for _, pp := range resp.Embedded.Products {
 			if err := r.processProductRecord(ctx, productRepo, stockRepo, branchID, pp); err != nil {
 				return nil, err
 			}
+			rb.upserted(1)
 
 			// Track max UpdatedAt from Phorest
 			if maxUpdatedAt == nil || pp.UpdatedAt.After(*maxUpdatedAt) {
@@ -174,6 +188,9 @@ func (r *Runner) processProductRecord(
 	if pp.Code != "" {
 		product.Code = &pp.Code
 	}
+	if pp.Barcode != "" {
+		product.Barcode = &pp.Barcode
+	}
 	if pp.Type != "" {
 		product.TypeRaw = &pp.Type
 	}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"time"
 
@@ -46,24 +45,13 @@ type ReviewsClient struct {
 	HTTP     *http.Client
 }
 
-func NewReviewsClient(user, pass, business string) *ReviewsClient {
+func NewReviewsClient(baseURL, user, pass, business string) *ReviewsClient {
 	return &ReviewsClient{
-		BaseURL:  "https://api-gateway-eu.phorest.com/third-party-api-server/api",
+		BaseURL:  apiRoot(baseURL),
 		User:     user,
 		Pass:     pass,
 		Business: business,
-		HTTP: &http.Client{
-			Timeout: 20 * time.Second,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
-		},
+		HTTP:     SharedHTTPClient(),
 	}
 }
 
@@ -79,7 +67,7 @@ func (c *ReviewsClient) FetchReviews(branchID, sinceDate string, page, size int)
 
 	// (If the endpoint supports filtering by reviewDate, you can append `&reviewDateStart=%s`)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute) // room for retries
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -1,16 +1,19 @@
 package phorest
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
 
+	"github.com/araquach/phorest-datahub/internal/archive"
 	"github.com/araquach/phorest-datahub/internal/models"
 	"github.com/araquach/phorest-datahub/internal/repos"
+	"gorm.io/gorm"
 )
 
 // BootstrapReviewsFromCSVsIfNeeded:
 // - If the DB already has reviews, do nothing.
-// - Otherwise, import all reviews from data/reviews/*.csv (if any).
+// - Otherwise, import all reviews CSVs in the seed archive (if any).
 func (r *Runner) BootstrapReviewsFromCSVsIfNeeded() error {
 	lg := r.Logger
 	db := r.DB
@@ -26,38 +29,50 @@ func (r *Runner) BootstrapReviewsFromCSVsIfNeeded() error {
 		return nil
 	}
 
-	// 2) Look for local backup CSVs
-	reviewsDir := "data/reviews"
-	paths, err := filepath.Glob(filepath.Join(reviewsDir, "*.csv"))
+	// 2) Import every archived CSV (compressed or not, local or object storage)
+	arc, err := r.seedArchive()
 	if err != nil {
-		return fmt.Errorf("scan reviews dir: %w", err)
+		return err
 	}
-	if len(paths) == 0 {
-		lg.Printf("ℹ️ No reviews CSV files found in %s; nothing to bootstrap", reviewsDir)
-		return nil
-	}
-
-	lg.Printf("📂 Found %d reviews CSV files in %s; bootstrapping…", len(paths), reviewsDir)
 
-	repo := repos.NewReviewsRepo(db, lg)
+	err = r.eachArchivedCSV(context.Background(), arc, archiveReviews, func(p string) error {
+		lg.Printf("📥 Importing reviews CSV: %s", archive.OriginalName(p))
 
-	for _, p := range paths {
-		lg.Printf("📥 Importing reviews CSV: %s", p)
+		entry, err := r.checkLedger(reviewsCSVSchema.Name, p, nil)
+		if errors.Is(err, errAlreadyImported) {
+			lg.Printf("⏭  %v", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
 		batch, err := ParseReviewsCSV(p, lg)
 		if err != nil {
 			return fmt.Errorf("parse reviews csv %s: %w", p, err)
 		}
-		if len(batch.Reviews) == 0 {
-			lg.Printf("⚠️  No reviews in %s; skipping", p)
-			continue
-		}
 
-		if err := repo.UpsertMany(batch.Reviews); err != nil {
-			return fmt.Errorf("upsert reviews from %s: %w", p, err)
+		// The reviews and their ledger entry commit together.
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if len(batch.Reviews) == 0 {
+				lg.Printf("⚠️  No reviews in %s; skipping", p)
+			} else if err := repos.NewReviewsRepo(tx, lg).UpsertMany(batch.Reviews); err != nil {
+				return fmt.Errorf("upsert reviews from %s: %w", p, err)
+			}
+			if err := r.recordRejects(tx, p, batch.Rejects); err != nil {
+				return err
+			}
+			return r.recordImport(tx, entry, len(batch.Reviews), len(batch.Rejects))
+		})
+		if err != nil {
+			return err
 		}
 
-		lg.Printf("✅ Bootstrapped %d reviews from %s", len(batch.Reviews), p)
+		lg.Printf("✅ Bootstrapped %d reviews from %s", len(batch.Reviews), archive.OriginalName(p))
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	lg.Printf("🎉 Reviews bootstrap from CSV complete.")
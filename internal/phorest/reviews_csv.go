@@ -5,23 +5,49 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/araquach/phorest-datahub/internal/archive"
 	"github.com/araquach/phorest-datahub/internal/models"
 )
 
 // ParsedReviews wraps the slice for symmetry with other parsers
 type ParsedReviews struct {
 	Reviews []models.Review
+	Rejects []models.ImportReject // cells that failed reviewsCSVSchema
+}
+
+// reviewDateLayouts covers what writeReviewsCSV and older backups used.
+var reviewDateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05"}
+
+// reviewsCSVSchema declares the columns of our own reviews backups.
+var reviewsCSVSchema = csvSchema{
+	Name: "reviews_csv",
+	Columns: []csvColumn{
+		{Name: "review_id", Type: colText, Required: true},
+		{Name: "branch_id", Type: colText, Required: true},
+		{Name: "client_id", Type: colText},
+		{Name: "client_first_name", Type: colText},
+		{Name: "client_last_name", Type: colText},
+		{Name: "review_date", Type: csvLayoutType("date", reviewDateLayouts...)},
+		{Name: "visit_date", Type: csvLayoutType("date", reviewDateLayouts...)},
+		{Name: "staff_id", Type: colText},
+		{Name: "staff_first_name", Type: colText},
+		{Name: "staff_last_name", Type: colText},
+		{Name: "text", Type: colText},
+		{Name: "rating", Type: colInteger},
+		{Name: "facebook_review", Type: colBoolean},
+		{Name: "twitter_review", Type: colBoolean},
+	},
 }
 
 // ParseReviewsCSV reads a reviews CSV we’ve previously written and converts it
-// into []models.Review ready to upsert.
+// into []models.Review ready to upsert. Rows failing reviewsCSVSchema are
+// returned as Rejects instead.
 func ParseReviewsCSV(path string, lg *log.Logger) (*ParsedReviews, error) {
-	f, err := os.Open(path)
+	f, err := archive.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open reviews csv %q: %w", path, err)
 	}
@@ -40,6 +66,10 @@ func ParseReviewsCSV(path string, lg *log.Logger) (*ParsedReviews, error) {
 		h = strings.TrimSpace(strings.ToLower(h))
 		idx[h] = i
 	}
+	v, err := reviewsCSVSchema.validator(path, header, lg)
+	if err != nil {
+		return nil, err
+	}
 
 	get := func(rec []string, name string) string {
 		i, ok := idx[name]
@@ -54,10 +84,8 @@ func ParseReviewsCSV(path string, lg *log.Logger) (*ParsedReviews, error) {
 		if s == "" {
 			return nil
 		}
-		for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05"} {
-			if t, err := time.Parse(layout, s); err == nil {
-				return &t
-			}
+		if t, ok := parseLayouts(s, reviewDateLayouts); ok {
+			return &t
 		}
 		return nil
 	}
@@ -84,7 +112,10 @@ func ParseReviewsCSV(path string, lg *log.Logger) (*ParsedReviews, error) {
 		}
 	}
 
-	var out []models.Review
+	var (
+		out     []models.Review
+		rejects []models.ImportReject
+	)
 	row := 0
 
 	for {
@@ -96,17 +127,17 @@ func ParseReviewsCSV(path string, lg *log.Logger) (*ParsedReviews, error) {
 			return nil, fmt.Errorf("read row %d: %w", row, err)
 		}
 		row++
+		line, _ := r.FieldPos(0)
 
-		reviewID := get(rec, "review_id")
-		if reviewID == "" {
+		// review_id and branch_id are required: rows without them are
+		// rejected along with any unparseable cells
+		if rj := v.validate(rec, line); len(rj) > 0 {
+			rejects = append(rejects, rj...)
 			continue
 		}
 
+		reviewID := get(rec, "review_id")
 		branchID := get(rec, "branch_id")
-		if branchID == "" {
-			// we *expect* branch_id, but skip rather than failing hard
-			continue
-		}
 
 		rv := models.Review{
 			ReviewID:        reviewID,
@@ -129,5 +160,5 @@ func ParseReviewsCSV(path string, lg *log.Logger) (*ParsedReviews, error) {
 	}
 
 	lg.Printf("Parsed reviews CSV %s: %d reviews", path, len(out))
-	return &ParsedReviews{Reviews: out}, nil
+	return &ParsedReviews{Reviews: out, Rejects: rejects}, nil
 }
@@ -3,7 +3,6 @@ package phorest
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -11,23 +10,27 @@ import (
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-func (r *Runner) RunIncrementalReviewsSync(ctx context.Context) error {
+func (r *Runner) RunIncrementalReviewsSync(ctx context.Context) (err error) {
 	lg := r.Logger
 	db := r.DB
 
 	lg.Printf("▶️ Starting incremental REVIEWS sync...")
 
+	run := r.startRun(repos.WatermarkReviewsAPI)
+	defer run.finish(&err)
+
 	rc := NewReviewsClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
 	)
 
 	rr := repos.NewReviewsRepo(db, lg)
-	wr := repos.NewWatermarksRepo(db, lg)
+	wr := run.watermarks()
 
 	// Process branch by branch
-	for _, b := range r.Cfg.Branches {
+	for _, b := range r.branches() {
 		branchID := b.BranchID
 		if branchID == "" {
 			lg.Printf("⚠️ Skipping branch %q with empty BranchID", b.Name)
@@ -35,17 +38,33 @@ func (r *Runner) RunIncrementalReviewsSync(ctx context.Context) error {
 		}
 
 		lg.Printf("🏢 Branch %s (%s): starting REVIEWS sync", b.Name, branchID)
+		rb, ok, err := run.lockBranch(ctx, branchID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
 
-		// Last known review date (in DB, not from watermark)
-		lastDateStr, err := rr.MaxReviewDate(branchID)
+		// Last known review date: the watermark, else what's already in the DB
+		// (e.g. reviews imported from CSV before the watermark existed).
+		last, err := wr.GetLastUpdated(repos.WatermarkReviewsAPI, branchID)
 		if err != nil {
-			return fmt.Errorf("max review_date for %s: %w", branchID, err)
+			return fmt.Errorf("get reviews_api watermark for %s: %w", branchID, err)
 		}
 
-		if lastDateStr != nil && *lastDateStr != "" {
-			lg.Printf("ℹ️ %s: existing max review_date = %s", branchID, *lastDateStr)
+		if last != nil {
+			lg.Printf("ℹ️ %s: reviews_api watermark = %s", branchID, last.Format("2006-01-02"))
 		} else {
-			lg.Printf("ℹ️ %s: no existing reviews in DB, treating as full bootstrap", branchID)
+			lastDateStr, err := rr.MaxReviewDate(branchID)
+			if err != nil {
+				return fmt.Errorf("max review_date for %s: %w", branchID, err)
+			}
+			if lastDateStr != nil && *lastDateStr != "" {
+				lg.Printf("ℹ️ %s: no watermark yet; existing max review_date = %s", branchID, *lastDateStr)
+			} else {
+				lg.Printf("ℹ️ %s: no existing reviews in DB, treating as full bootstrap", branchID)
+			}
 		}
 
 		const pageSize = 100
@@ -87,9 +106,11 @@ func (r *Runner) RunIncrementalReviewsSync(ctx context.Context) error {
 				branchID, page, len(rows), existingCount, dupRatio)
 
 			// Upsert entire page – UpsertMany() is idempotent (DO NOTHING on conflict).
+			rb.fetched(len(rows))
 			if err := rr.UpsertMany(rows); err != nil {
 				return fmt.Errorf("upsert reviews branch=%s page=%d: %w", branchID, page, err)
 			}
+			rb.upserted(len(rows) - int(existingCount))
 
 			// Only consider pages that have at least one non-duplicate for CSV + watermark.
 			if dupRatio < 1.0 {
@@ -135,6 +156,7 @@ func (r *Runner) RunIncrementalReviewsSync(ctx context.Context) error {
 
 		if len(allNew) == 0 {
 			lg.Printf("✅ %s: no new reviews detected; nothing to archive", branchID)
+			rb.finish(nil)
 			continue
 		}
 
@@ -148,26 +170,23 @@ func (r *Runner) RunIncrementalReviewsSync(ctx context.Context) error {
 		}
 		lg.Printf("💾 %s: saved reviews CSV to %s", branchID, tmpPath)
 
-		// 2) Archive into data/reviews for future bootstrap
-		archiveDir := "data/reviews"
-		if err := os.MkdirAll(archiveDir, 0o755); err != nil {
-			return fmt.Errorf("mkdir %s: %w", archiveDir, err)
-		}
-		finalPath := filepath.Join(archiveDir, filename)
-		if err := os.Rename(tmpPath, finalPath); err != nil {
+		// 2) Archive for future bootstrap
+		finalPath, err := r.moveToSeedArchive(ctx, archiveReviews, tmpPath)
+		if err != nil {
 			return fmt.Errorf("archive reviews CSV for %s: %w", branchID, err)
 		}
 		lg.Printf("📦 %s: archived %s → %s (for future bootstrap)", branchID, tmpPath, finalPath)
 
 		// 3) Update watermark if we actually saw newer review dates
 		if latestInRun != nil {
-			if err := wr.UpsertLastUpdated("reviews_api", branchID, *latestInRun); err != nil {
+			if err := wr.UpsertLastUpdated(repos.WatermarkReviewsAPI, branchID, *latestInRun); err != nil {
 				return fmt.Errorf("update reviews_api watermark for %s: %w", branchID, err)
 			}
 			lg.Printf("💾 %s: updated reviews_api watermark → %s",
 				branchID, latestInRun.Format("2006-01-02"))
 		}
 
+		rb.finish(nil)
 		lg.Printf("✅ %s: incremental REVIEWS sync finished (%d rows touched)", branchID, len(allNew))
 	}
 
@@ -5,10 +5,10 @@ import (
 )
 
 func (r *Runner) SyncReviewsFromAPI() error {
-	client := NewReviewsClient(r.Cfg.PhorestUsername, r.Cfg.PhorestPassword, r.Cfg.PhorestBusiness)
+	client := NewReviewsClient(r.Cfg.PhorestBaseURL, r.Cfg.PhorestUsername, r.Cfg.PhorestPassword, r.Cfg.PhorestBusiness)
 	repo := repos.NewReviewsRepo(r.DB, r.Logger)
 
-	for _, b := range r.Cfg.Branches {
+	for _, b := range r.branches() {
 		if b.BranchID == "" {
 			r.Logger.Printf("⚠️  Skipping reviews: empty BranchID (name=%q)", b.Name)
 			continue
@@ -52,10 +52,10 @@ func (r *Runner) SyncReviewsFromAPI() error {
 
 // SyncLatestReviewsFromAPI fetches only the latest N reviews per branch and upserts them.
 func (r *Runner) SyncLatestReviewsFromAPI(n int) error {
-	client := NewReviewsClient(r.Cfg.PhorestUsername, r.Cfg.PhorestPassword, r.Cfg.PhorestBusiness)
+	client := NewReviewsClient(r.Cfg.PhorestBaseURL, r.Cfg.PhorestUsername, r.Cfg.PhorestPassword, r.Cfg.PhorestBusiness)
 	repo := repos.NewReviewsRepo(r.DB, r.Logger)
 
-	for _, b := range r.Cfg.Branches {
+	for _, b := range r.branches() {
 		if b.BranchID == "" {
 			r.Logger.Printf("⚠️  Skipping reviews: empty BranchID (name=%q)", b.Name)
 			continue
@@ -0,0 +1,184 @@
+package phorest
+
+import (
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/db"
+	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// syncRun records one sync invocation in core.sync_runs / core.sync_run_branches.
+//
+// History is best-effort: if a write fails we log it and carry on, so a broken
+// history table never fails an otherwise good sync. All methods are nil-safe.
+type syncRun struct {
+	r       *Runner
+	entity  repos.WatermarkEntity
+	repo    *repos.SyncRunsRepo
+	wr      *repos.WatermarksRepo
+	row     *models.SyncRun
+	open    []*runBranch
+	skipped int
+}
+
+// runBranch tracks one branch inside a syncRun.
+type runBranch struct {
+	run  *syncRun
+	row  *models.SyncRunBranch
+	lock *db.AdvisoryLock // held from lockBranch until finish
+	done bool
+}
+
+// startRun opens a history row for entity.
+// Pair with `defer run.finish(&err)` on a named error return.
+func (r *Runner) startRun(entity repos.WatermarkEntity) *syncRun {
+	run := &syncRun{
+		r:      r,
+		entity: entity,
+		repo:   repos.NewSyncRunsRepo(r.DB, r.Logger),
+		wr:     r.watermarks(),
+	}
+
+	row, err := run.repo.Start(string(entity))
+	if err != nil {
+		r.Logger.Printf("⚠️ sync history: start %s: %v", entity, err)
+		return run
+	}
+	run.row = row
+	run.wr = run.wr.ForRun(&row.ID)
+	return run
+}
+
+// id returns the run's history ID, nil if its row couldn't be written.
+func (s *syncRun) id() *int64 {
+	if s == nil || s.row == nil {
+		return nil
+	}
+	return &s.row.ID
+}
+
+// watermarks returns the repo syncs should move watermarks through: moves
+// land in core.watermark_history and jumps over WATERMARK_MAX_JUMP are
+// flagged. Prefer syncRun.watermarks inside a run.
+func (r *Runner) watermarks() *repos.WatermarksRepo {
+	return repos.NewWatermarksRepo(r.DB, r.Logger).
+		WithMaxJump(getDurationEnv("WATERMARK_MAX_JUMP", repos.DefaultWatermarkMaxJump))
+}
+
+// watermarks is Runner.watermarks with moves attributed to this run.
+func (s *syncRun) watermarks() *repos.WatermarksRepo {
+	return s.wr
+}
+
+// finish closes any branches left open (early return), releasing their
+// locks, and stamps the run. A run where every branch was skipped is itself
+// recorded as skipped.
+func (s *syncRun) finish(errp *error) {
+	if s == nil {
+		return
+	}
+
+	var runErr error
+	if errp != nil {
+		runErr = *errp
+	}
+
+	for _, b := range s.open {
+		if !b.done {
+			b.finish(runErr)
+		}
+	}
+
+	if s.row == nil {
+		return
+	}
+	if runErr == nil && s.skipped > 0 && s.skipped == len(s.open) {
+		runErr = db.ErrLockHeld
+	}
+
+	if err := s.repo.Finish(s.row, runErr); err != nil {
+		s.r.Logger.Printf("⚠️ sync history: finish %s run=%d: %v", s.entity, s.row.ID, err)
+	}
+}
+
+// branch opens a branch row, capturing the current watermark as "before".
+func (s *syncRun) branch(branchID string) *runBranch {
+	b := &runBranch{run: s}
+	if s == nil {
+		return b
+	}
+	s.open = append(s.open, b)
+	if s.row == nil {
+		return b
+	}
+
+	before, err := s.wr.GetLastUpdated(s.entity, branchID)
+	if err != nil {
+		s.r.Logger.Printf("⚠️ sync history: read %s/%s watermark: %v", s.entity, branchID, err)
+	}
+
+	row, err := s.repo.StartBranch(s.row.ID, branchID, before)
+	if err != nil {
+		s.r.Logger.Printf("⚠️ sync history: start branch %s/%s: %v", s.entity, branchID, err)
+		return b
+	}
+	b.row = row
+	return b
+}
+
+// window records the date/time window the branch actually queried.
+func (b *runBranch) window(from, to time.Time) {
+	if b == nil || b.row == nil {
+		return
+	}
+	f, t := from.UTC(), to.UTC()
+	b.row.WindowFrom = &f
+	b.row.WindowTo = &t
+}
+
+func (b *runBranch) fetched(n int) {
+	if b == nil || b.row == nil {
+		return
+	}
+	b.row.RowsFetched += int64(n)
+	b.run.row.RowsFetched += int64(n)
+}
+
+func (b *runBranch) upserted(n int) {
+	if b == nil || b.row == nil {
+		return
+	}
+	b.row.RowsUpserted += int64(n)
+	b.run.row.RowsUpserted += int64(n)
+}
+
+// finish releases the branch lock and stamps the branch, reading the
+// watermark again as "after".
+func (b *runBranch) finish(err error) {
+	if b == nil || b.done {
+		return
+	}
+	b.done = true
+
+	s := b.run
+	if b.lock != nil {
+		if uerr := b.lock.Unlock(); uerr != nil {
+			s.r.Logger.Printf("⚠️ %s: %v", s.entity, uerr)
+		}
+		b.lock = nil
+	}
+	if b.row == nil {
+		return
+	}
+
+	after, werr := s.wr.GetLastUpdated(s.entity, b.row.BranchID)
+	if werr != nil {
+		s.r.Logger.Printf("⚠️ sync history: read %s/%s watermark: %v", s.entity, b.row.BranchID, werr)
+	}
+	b.row.WatermarkAfter = after
+
+	if ferr := s.repo.FinishBranch(b.row, err); ferr != nil {
+		s.r.Logger.Printf("⚠️ sync history: finish branch %s/%s: %v", s.entity, b.row.BranchID, ferr)
+	}
+}
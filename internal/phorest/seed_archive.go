@@ -0,0 +1,120 @@
+package phorest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/archive"
+)
+
+// Archive kinds: the directories (key prefixes) of the seed archive the
+// bootstrap reads.
+const (
+	archiveTransactions = "transactions"
+	archiveClients      = "clients"
+	archiveClientsAPI   = "clients_api"
+	archiveReviews      = "reviews"
+)
+
+// seedArchive is where synced CSVs are kept for future bootstraps: the
+// storage in Cfg.Archive (a local dir, default "data", or an S3-compatible
+// bucket), plus the tunables
+//
+//	ARCHIVE_CODEC           gzip (default) or zstd
+//	ARCHIVE_RETENTION_DAYS  prune dated entries older than this; unset = keep all
+func (r *Runner) seedArchive() (*archive.Archive, error) {
+	store, err := r.archiveStorage()
+	if err != nil {
+		return nil, err
+	}
+	codec, err := archive.ParseCodec(getStringEnv("ARCHIVE_CODEC", ""))
+	if err != nil {
+		r.Logger.Printf("⚠️ %v; using gzip", err)
+		codec = archive.Gzip
+	}
+	retention := time.Duration(getIntEnv("ARCHIVE_RETENTION_DAYS", 0)) * 24 * time.Hour
+	return archive.New(store, codec, retention, r.Logger), nil
+}
+
+func (r *Runner) archiveStorage() (archive.Storage, error) {
+	c := r.Cfg.Archive
+	if !c.UsesS3() {
+		dir := c.Dir
+		if dir == "" {
+			dir = "data"
+		}
+		return archive.NewLocalStorage(dir), nil
+	}
+	s3, err := archive.NewS3Storage(archive.S3Config{
+		Endpoint:  c.S3Endpoint,
+		Bucket:    c.S3Bucket,
+		Prefix:    c.S3Prefix,
+		Region:    c.S3Region,
+		AccessKey: c.S3AccessKey,
+		SecretKey: c.S3SecretKey,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("archive storage: %w", err)
+	}
+	return s3, nil
+}
+
+// archiveCSVToSeed stores a CSV in the seed archive under kind so it
+// becomes part of the “bootstrap” dataset. Contents already archived are
+// not stored twice.
+func (r *Runner) archiveCSVToSeed(ctx context.Context, srcPath, kind string) {
+	// Don’t hard fail the sync if this fails – just log it.
+	arc, err := r.seedArchive()
+	if err == nil {
+		_, err = arc.Put(ctx, kind, srcPath)
+	}
+	if err != nil {
+		r.Logger.Printf("⚠️  archiveCSVToSeed: %v", err)
+	}
+}
+
+// moveToSeedArchive archives an export we wrote ourselves, then deletes the
+// local copy, and returns where it went.
+func (r *Runner) moveToSeedArchive(ctx context.Context, kind, tmpPath string) (string, error) {
+	arc, err := r.seedArchive()
+	if err != nil {
+		return "", err
+	}
+	key, err := arc.Put(ctx, kind, tmpPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(tmpPath); err != nil {
+		r.Logger.Printf("⚠️ remove %s: %v", tmpPath, err)
+	}
+	return arc.Store.Location(key), nil
+}
+
+// eachArchivedCSV calls fn with a local copy of every CSV of kind in arc,
+// oldest first (see archive.Archive.List). fn's error stops the walk.
+func (r *Runner) eachArchivedCSV(ctx context.Context, arc *archive.Archive, kind string, fn func(path string) error) error {
+	keys, err := arc.List(ctx, kind)
+	if err != nil {
+		return fmt.Errorf("list %s: %w", arc.Location(kind), err)
+	}
+	if len(keys) == 0 {
+		r.Logger.Printf("⚠️  No CSV files found in %s", arc.Location(kind))
+		return nil
+	}
+	r.Logger.Printf("📂 Found %d CSV files in %s", len(keys), arc.Location(kind))
+
+	for _, key := range keys {
+		path, done, err := arc.Fetch(ctx, key)
+		if err != nil {
+			return err
+		}
+		err = fn(path)
+		done()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"time"
 
@@ -47,33 +46,22 @@ type StaffClient struct {
 	Logger   *log.Logger
 }
 
-func NewStaffClient(user, pass, business string, lg *log.Logger) *StaffClient {
+func NewStaffClient(baseURL, user, pass, business string, lg *log.Logger) *StaffClient {
 	return &StaffClient{
-		BaseURL:  "https://api-gateway-eu.phorest.com/third-party-api-server/api",
+		BaseURL:  apiRoot(baseURL),
 		User:     user,
 		Pass:     pass,
 		Business: business,
 		Logger:   lg,
-		HTTP: &http.Client{
-			Timeout: 20 * time.Second,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
-		},
+		HTTP:     SharedHTTPClient(),
 	}
 }
 
-func (c *StaffClient) FetchStaff(branchID string) ([]models.Staff, error) {
+func (c *StaffClient) FetchStaff(ctx context.Context, branchID string) ([]models.Staff, error) {
 	url := fmt.Sprintf("%s/business/%s/branch/%s/staff?fetch_archived=true&size=%d",
 		c.BaseURL, c.Business, branchID, 200)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute) // room for retries
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
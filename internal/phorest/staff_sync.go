@@ -1,14 +1,17 @@
 package phorest
 
 import (
+	"context"
 	"time"
 
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
 // SyncStaffFromAPI fetches staff for each configured branch and upserts them.
-func (r *Runner) SyncStaffFromAPI() error {
+// Cancelling ctx aborts the current request and skips the remaining branches.
+func (r *Runner) SyncStaffFromAPI(ctx context.Context) error {
 	c := NewStaffClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
@@ -16,9 +19,12 @@ func (r *Runner) SyncStaffFromAPI() error {
 	)
 
 	repo := repos.NewStaffRepo(r.DB, r.Logger)
-	wr := repos.NewWatermarksRepo(r.DB, r.Logger)
+	wr := r.watermarks()
 
-	for _, b := range r.Cfg.Branches {
+	for _, b := range r.branches() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if b.BranchID == "" {
 			r.Logger.Printf("⚠️  Skipping branch with empty BranchID (name=%q)", b.Name)
 			continue
@@ -26,7 +32,7 @@ func (r *Runner) SyncStaffFromAPI() error {
 
 		r.Logger.Printf("Fetching staff for %s (%s)", b.Name, b.BranchID)
 
-		rows, err := c.FetchStaff(b.BranchID)
+		rows, err := c.FetchStaff(ctx, b.BranchID)
 		if err != nil {
 			r.Logger.Printf("❌ staff fetch failed for %s (%s): %v", b.Name, b.BranchID, err)
 			continue
@@ -43,7 +49,7 @@ func (r *Runner) SyncStaffFromAPI() error {
 
 		// 🔹 record / advance watermark for this branch
 		now := time.Now().UTC()
-		if err := wr.UpsertLastUpdated("staff_api", b.BranchID, now); err != nil {
+		if err := wr.UpsertLastUpdated(repos.WatermarkStaffAPI, b.BranchID, now); err != nil {
 			r.Logger.Printf("⚠️ failed to update staff_api watermark for %s (%s): %v", b.Name, b.BranchID, err)
 			// you could `continue` or `return err` here depending on how strict you want to be
 		}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"time"
@@ -47,24 +46,13 @@ type StaffWorkTimetableClient struct {
 	HTTP     *http.Client
 }
 
-func NewStaffWorkTimetableClient(user, pass, business string) *StaffWorkTimetableClient {
+func NewStaffWorkTimetableClient(baseURL, user, pass, business string) *StaffWorkTimetableClient {
 	return &StaffWorkTimetableClient{
-		BaseURL:  "https://api-gateway-eu.phorest.com/third-party-api-server/api",
+		BaseURL:  apiRoot(baseURL),
 		User:     user,
 		Pass:     pass,
 		Business: business,
-		HTTP: &http.Client{
-			Timeout: 25 * time.Second,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   10 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				MaxIdleConns:        100,
-				IdleConnTimeout:     90 * time.Second,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
-		},
+		HTTP:     SharedHTTPClient(),
 	}
 }
 
@@ -125,7 +113,7 @@ func (c *StaffWorkTimetableClient) FetchWorkTimetablePage(
 			if err != nil {
 				continue
 			}
-			
+
 			out = append(out, models.StaffWorkTimetableSlot{
 				BranchID: branchID,
 				StaffID:  tt.StaffID,
@@ -28,13 +28,16 @@ const (
 	EnvWorktimetableActivityType = "WORKTIMETABLE_ACTIVITY_TYPE" // optional
 )
 
-func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) error {
+func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) (err error) {
 	lg := r.Logger
-	db := r.DB
 
 	lg.Printf("▶️ Starting STAFF_WORKTIMETABLE sync...")
 
+	run := r.startRun(repos.WatermarkWorktimetableRolling)
+	defer run.finish(&err)
+
 	client := NewStaffWorkTimetableClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
@@ -43,22 +46,13 @@ func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) error
 	const pageSize = 100
 
 	// --- Rolling window config ---
-	// Prefer WORKTIMETABLE_PAST_DAYS (what you set), but support legacy WORKTIMETABLE_HISTORY_DAYS too.
-	historyDays := getIntEnv(EnvWorktimetablePastDays, 0)
-	if historyDays <= 0 {
-		historyDays = getIntEnv(EnvWorktimetableHistoryDays, 365)
-	}
-	futureDays := getIntEnv(EnvWorktimetableFutureDays, 120)
+	defaultStart, defaultEnd := worktimetableRollingWindow(time.Now().UTC())
 
-	now := time.Now().UTC()
-	defaultStart := dateOnly(now.AddDate(0, 0, -historyDays))
-	defaultEnd := dateOnly(now.AddDate(0, 0, futureDays))
-
-	fromOverride, err := getDateEnv(EnvWorktimetableFromDate)
+	fromOverride, err := dateOverride(r.Opts.FromDate, EnvWorktimetableFromDate)
 	if err != nil {
 		return err
 	}
-	toOverride, err := getDateEnv(EnvWorktimetableToDate)
+	toOverride, err := dateOverride(r.Opts.ToDate, EnvWorktimetableToDate)
 	if err != nil {
 		return err
 	}
@@ -110,10 +104,18 @@ func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) error
 	}
 
 	// Watermarks repo (rolling + backfill-done markers)
-	wmRepo := repos.NewWatermarksRepo(db, lg)
+	wmRepo := run.watermarks()
 
-	for _, br := range r.Cfg.Branches {
+	for _, br := range r.branches() {
 		branchID := br.BranchID
+		rb, ok, err := run.lockBranch(ctx, branchID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		rb.window(rollingStart, rollingEnd)
 
 		// 1) BACKFILL (one-off) — ONLY when explicitly enabled AND not already done
 		doneAt, err := wmRepo.GetWorktimetableBackfillDone(branchID)
@@ -147,7 +149,7 @@ func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) error
 					backfillEnd.Format("2006-01-02"),
 				)
 
-				if err := r.refreshWorktimetableRange(ctx, client, branchID, backfillFrom, backfillEnd, activityType, pageSize); err != nil {
+				if err := r.refreshWorktimetableRange(ctx, client, rb, branchID, backfillFrom, backfillEnd, activityType, pageSize); err != nil {
 					return err
 				}
 			}
@@ -166,12 +168,14 @@ func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) error
 			rollingEnd.Format("2006-01-02"),
 		)
 
-		if err := r.refreshWorktimetableRange(ctx, client, branchID, rollingStart, rollingEnd, activityType, pageSize); err != nil {
+		if err := r.refreshWorktimetableRange(ctx, client, rb, branchID, rollingStart, rollingEnd, activityType, pageSize); err != nil {
 			return err
 		}
 
 		// Optional: store a rolling “ran at” marker (separate from backfill-done)
 		_ = wmRepo.UpsertLastUpdated(repos.WatermarkWorktimetableRolling, branchID, time.Now().UTC())
+
+		rb.finish(nil)
 	}
 
 	return nil
@@ -182,6 +186,7 @@ func (r *Runner) RunIncrementalStaffWorkTimetableSync(ctx context.Context) error
 func (r *Runner) refreshWorktimetableRange(
 	ctx context.Context,
 	client *StaffWorkTimetableClient,
+	rb *runBranch,
 	branchID string,
 	start time.Time,
 	end time.Time,
@@ -257,6 +262,7 @@ func (r *Runner) refreshWorktimetableRange(
 			}
 
 			fetched = append(fetched, rows...)
+			rb.fetched(len(rows))
 
 			page++
 			if totalPages > 0 && page >= totalPages {
@@ -305,6 +311,7 @@ func (r *Runner) refreshWorktimetableRange(
 		}
 
 		totalTouched += len(fetched)
+		rb.upserted(len(fetched))
 		lg.Printf(
 			"✅ worktimetable/%s: refreshed %s..%s (%d slots)",
 			branchID,
@@ -9,13 +9,12 @@ import (
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/araquach/phorest-datahub/internal/services"
 )
 
 type StockAdjuster struct {
-	BaseURL    string // e.g. https://api-gateway-eu.phorest.com/third-party-api-server
+	BaseURL    string // API root without /api, usually config.Config.PhorestBaseURL
 	BusinessID string
 	Username   string
 	Password   string
@@ -29,36 +28,38 @@ func NewStockAdjuster(baseURL, businessID, username, password string) StockAdjus
 		BusinessID: businessID,
 		Username:   username,
 		Password:   password,
-		HTTP: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		HTTP:       SharedHTTPClient(),
 	}
 }
 
+// AdjustStock posts req for branchID. Errors before the request goes out,
+// and a response Phorest itself turned down, wrap
+// services.ErrStockNotAdjusted. Transport errors and gateway timeouts
+// (502/504) don't: the adjustment may have been applied.
 func (a StockAdjuster) AdjustStock(ctx context.Context, branchID string, req services.StockAdjustmentRequest) error {
 	if branchID == "" {
-		return fmt.Errorf("branchID is required")
+		return fmt.Errorf("%w: branchID is required", services.ErrStockNotAdjusted)
 	}
 	if a.BusinessID == "" {
-		return fmt.Errorf("businessID is required")
+		return fmt.Errorf("%w: businessID is required", services.ErrStockNotAdjusted)
 	}
 	if a.Username == "" || a.Password == "" {
-		return fmt.Errorf("phorest username/password required")
+		return fmt.Errorf("%w: phorest username/password required", services.ErrStockNotAdjusted)
 	}
 	if a.HTTP == nil {
-		return fmt.Errorf("http client is nil")
+		return fmt.Errorf("%w: http client is nil", services.ErrStockNotAdjusted)
 	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
+		return fmt.Errorf("%w: marshal request: %v", services.ErrStockNotAdjusted, err)
 	}
 
 	url := fmt.Sprintf("%s/api/business/%s/branch/%s/stock/adjustment", a.BaseURL, a.BusinessID, branchID)
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("new request: %w", err)
+		return fmt.Errorf("%w: new request: %v", services.ErrStockNotAdjusted, err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
@@ -74,7 +75,11 @@ func (a StockAdjuster) AdjustStock(ctx context.Context, branchID string, req ser
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("phorest adjust stock failed: status=%s body=%s", resp.Status, string(b))
+		err := fmt.Errorf("phorest adjust stock failed: status=%s body=%s", resp.Status, string(b))
+		if resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusGatewayTimeout {
+			return err // a proxy gave up; Phorest may still have applied it
+		}
+		return fmt.Errorf("%w: %v", services.ErrStockNotAdjusted, err)
 	}
 
 	return nil
@@ -0,0 +1,59 @@
+package phorest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/services"
+)
+
+func TestAdjustStockErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		delay       time.Duration
+		wantErr     bool
+		notAdjusted bool // safe to post again
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "turned down", status: http.StatusBadRequest, wantErr: true, notAdjusted: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true, notAdjusted: true},
+		{name: "gateway timeout", status: http.StatusGatewayTimeout, wantErr: true},
+		{name: "bad gateway", status: http.StatusBadGateway, wantErr: true},
+		{name: "client timeout", status: http.StatusOK, delay: 300 * time.Millisecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			a := NewStockAdjuster(srv.URL, "biz", "user", "pass")
+			a.HTTP = &http.Client{Timeout: 100 * time.Millisecond}
+
+			err := a.AdjustStock(context.Background(), "pk", services.StockAdjustmentRequest{
+				Stocks: []services.StockAdjustmentItem{{Barcode: "B1", Quantity: 1, OperationType: "DEDUCT"}},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AdjustStock() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, services.ErrStockNotAdjusted); got != tt.notAdjusted {
+				t.Fatalf("errors.Is(%v, ErrStockNotAdjusted) = %v, want %v", err, got, tt.notAdjusted)
+			}
+		})
+	}
+
+	if err := (StockAdjuster{}).AdjustStock(context.Background(), "", services.StockAdjustmentRequest{}); !errors.Is(err, services.ErrStockNotAdjusted) {
+		t.Errorf("AdjustStock() before sending = %v, want ErrStockNotAdjusted", err)
+	}
+}
@@ -0,0 +1,72 @@
+package phorest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/araquach/phorest-datahub/internal/config"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// ForBusiness returns a Runner for business b: same DB, logger and options,
+// config and export client switched to b's credentials and branches.
+func (r *Runner) ForBusiness(b config.BusinessConfig) *Runner {
+	cfg := r.Cfg.ForBusiness(b)
+	br := NewRunner(r.DB, cfg, r.Logger)
+	br.Opts = r.Opts
+	return br
+}
+
+// businesses returns the configured businesses, narrowed by Opts.Businesses.
+func (r *Runner) businesses() []config.BusinessConfig {
+	if len(r.Opts.Businesses) == 0 {
+		return r.Cfg.Businesses
+	}
+
+	out := make([]config.BusinessConfig, 0, len(r.Opts.Businesses))
+	for _, b := range r.Cfg.Businesses {
+		for _, want := range r.Opts.Businesses {
+			if strings.EqualFold(want, b.BusinessID) || strings.EqualFold(want, b.Name) {
+				out = append(out, b)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// eachBusiness runs fn once per business with a Runner scoped to it. A
+// failing business doesn't stop the others; their errors are joined.
+// A Runner built from a bare Config (no Businesses) just runs fn(r).
+func (r *Runner) eachBusiness(fn func(*Runner) error) error {
+	if len(r.Cfg.Businesses) == 0 {
+		return fn(r)
+	}
+
+	bs := r.businesses()
+	if len(bs) == 0 {
+		return fmt.Errorf("no configured business matches %s", strings.Join(r.Opts.Businesses, ", "))
+	}
+
+	var errs []error
+	for _, b := range bs {
+		if len(r.Cfg.Businesses) > 1 {
+			r.Logger.Printf("🏷  Business %s (%s)", b.Name, b.BusinessID)
+		}
+		if err := fn(r.ForBusiness(b)); err != nil {
+			if len(r.Cfg.Businesses) > 1 {
+				err = fmt.Errorf("business %s: %w", b.Name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// businessWideKey is the branch_id used for business-wide watermarks, locks
+// and run history (clients, branches). The first business keeps the
+// historical "ALL"; any other gets "ALL:<businessID>" so they don't collide.
+func (r *Runner) businessWideKey() string {
+	return repos.BusinessWideKey(r.Cfg.PhorestBusiness, r.Cfg.IsPrimaryBusiness())
+}
@@ -0,0 +1,114 @@
+package phorest
+
+import (
+	"context"
+	"time"
+)
+
+// SyncJob describes one Runner entry point that can be triggered on its own
+// (CLI or the serve daemon). Name is the CLI-facing identifier.
+type SyncJob struct {
+	Name    string
+	Label   string
+	Timeout time.Duration
+
+	// DefaultSchedule is used by `datahub serve` unless SCHEDULE_<NAME> overrides it.
+	DefaultSchedule string
+
+	// Run executes the sync for every configured business (see perBusiness).
+	Run func(r *Runner, ctx context.Context) error
+}
+
+// perBusiness wraps a single-business Runner method so it runs once per
+// configured business.
+func perBusiness(run func(*Runner, context.Context) error) func(*Runner, context.Context) error {
+	return func(r *Runner, ctx context.Context) error {
+		return r.eachBusiness(func(br *Runner) error { return run(br, ctx) })
+	}
+}
+
+// SyncJobs returns every sync in the order a full run should execute them.
+func SyncJobs() []SyncJob {
+	return []SyncJob{
+		{
+			Name:            "staff",
+			Label:           "STAFF_API",
+			Timeout:         5 * time.Minute,
+			DefaultSchedule: "@every 12h",
+			Run:             perBusiness((*Runner).SyncStaffFromAPI),
+		},
+		{
+			Name:            "branches",
+			Label:           "BRANCHES_API",
+			Timeout:         2 * time.Minute,
+			DefaultSchedule: "@daily",
+			Run:             perBusiness((*Runner).SyncBranchesFromAPI),
+		},
+		{
+			Name:            "clients-csv",
+			Label:           "CLIENT_CSV",
+			Timeout:         10 * time.Minute,
+			DefaultSchedule: "@every 6h",
+			Run:             perBusiness((*Runner).RunIncrementalClientsSync),
+		},
+		{
+			Name:            "clients-api",
+			Label:           "CLIENTS_API",
+			Timeout:         10 * time.Minute,
+			DefaultSchedule: "@every 1h",
+			Run:             perBusiness((*Runner).RunIncrementalClientsAPISync),
+		},
+		{
+			Name:            "transactions",
+			Label:           "TRANSACTIONS_CSV",
+			Timeout:         10 * time.Minute,
+			DefaultSchedule: "@every 1h",
+			Run:             perBusiness((*Runner).RunIncrementalTransactionsSync),
+		},
+		{
+			Name:            "appointments",
+			Label:           "APPOINTMENTS_API",
+			Timeout:         15 * time.Minute,
+			DefaultSchedule: "@every 15m",
+			Run:             perBusiness((*Runner).RunIncrementalAppointmentsAPISync),
+		},
+		{
+			Name:            "reviews",
+			Label:           "REVIEWS",
+			Timeout:         10 * time.Minute,
+			DefaultSchedule: "@every 6h",
+			Run:             perBusiness((*Runner).RunIncrementalReviewsSync),
+		},
+		{
+			Name:            "worktimetable",
+			Label:           "WORKTIMETABLE",
+			Timeout:         15 * time.Minute,
+			DefaultSchedule: "@every 6h",
+			Run:             perBusiness((*Runner).RunIncrementalStaffWorkTimetableSync),
+		},
+		{
+			Name:            "products",
+			Label:           "PRODUCTS",
+			Timeout:         10 * time.Minute,
+			DefaultSchedule: "@every 1h",
+			Run:             perBusiness((*Runner).SyncProductsFromAPI),
+		},
+		{
+			Name:            "breaks",
+			Label:           "BREAKS_API",
+			Timeout:         15 * time.Minute,
+			DefaultSchedule: "@every 6h",
+			Run:             perBusiness((*Runner).RunIncrementalBreaksAPISync),
+		},
+	}
+}
+
+// FindSyncJob looks a job up by its CLI name.
+func FindSyncJob(name string) (SyncJob, bool) {
+	for _, j := range SyncJobs() {
+		if j.Name == name {
+			return j, true
+		}
+	}
+	return SyncJob{}, false
+}
@@ -0,0 +1,43 @@
+package phorest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/araquach/phorest-datahub/internal/db"
+)
+
+// lockBranch takes the Postgres advisory lock for (entity, branch) and opens
+// the branch's history row. It stops two processes — overlapping cron runs,
+// a manual `datahub sync` next to `datahub serve` — from creating duplicate
+// export jobs and both pushing the same watermark.
+//
+// ok=false means another process already holds the lock (after waiting up to
+// Opts.LockWait): the branch has been recorded as skipped and the caller
+// should move on. Otherwise the lock is held until rb.finish / run.finish.
+func (s *syncRun) lockBranch(ctx context.Context, branchID string) (rb *runBranch, ok bool, err error) {
+	r := s.r
+
+	sqlDB, err := r.DB.DB()
+	if err != nil {
+		return nil, false, fmt.Errorf("get raw sql DB: %w", err)
+	}
+
+	name := "sync:" + string(s.entity) + ":" + branchID
+	lock, err := db.TryAdvisoryLock(ctx, sqlDB, name, r.Opts.LockWait)
+	if errors.Is(err, db.ErrLockHeld) {
+		r.Logger.Printf("⏭  %s/%s: already running in another process, skipping", s.entity, branchID)
+		rb = s.branch(branchID)
+		rb.finish(err)
+		s.skipped++
+		return rb, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	rb = s.branch(branchID)
+	rb.lock = lock
+	return rb, true, nil
+}
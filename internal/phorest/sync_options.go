@@ -0,0 +1,59 @@
+package phorest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/config"
+)
+
+// SyncOptions carries per-invocation overrides (usually from CLI flags).
+// Zero values mean "fall back to the env var / built-in default".
+type SyncOptions struct {
+	// Businesses restricts a sync to these business IDs or names
+	// (case-insensitive). Empty = every configured business.
+	Businesses []string
+
+	// Branches restricts a sync to these branch IDs or names (case-insensitive).
+	// Empty = every configured branch.
+	Branches []string
+
+	// FromDate / ToDate override the *_FROM_DATE / *_TO_DATE env vars of each sync.
+	FromDate *time.Time
+	ToDate   *time.Time
+
+	// IgnoreWatermark forces a backfill-style run where the sync supports it
+	// (e.g. APPOINTMENTS_IGNORE_WATERMARK).
+	IgnoreWatermark bool
+
+	// LockWait is how long a sync waits for another process holding the same
+	// (entity, branch) advisory lock before skipping that branch. 0 = don't wait.
+	LockWait time.Duration
+}
+
+// branches returns the configured branches, narrowed by Opts.Branches if set.
+func (r *Runner) branches() []config.BranchConfig {
+	if len(r.Opts.Branches) == 0 {
+		return r.Cfg.Branches
+	}
+
+	out := make([]config.BranchConfig, 0, len(r.Opts.Branches))
+	for _, b := range r.Cfg.Branches {
+		for _, want := range r.Opts.Branches {
+			if strings.EqualFold(want, b.BranchID) || strings.EqualFold(want, b.Name) {
+				out = append(out, b)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// dateOverride prefers the CLI override, then the env var (YYYY-MM-DD).
+func dateOverride(opt *time.Time, envKey string) (*time.Time, error) {
+	if opt != nil {
+		t := dateOnly(opt.UTC())
+		return &t, nil
+	}
+	return getDateEnv(envKey)
+}
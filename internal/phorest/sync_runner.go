@@ -2,15 +2,15 @@ package phorest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/araquach/phorest-datahub/internal/models"
-	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/araquach/phorest-datahub/internal/archive"
 	"github.com/araquach/phorest-datahub/internal/config"
 	"github.com/araquach/phorest-datahub/internal/repos"
 	"gorm.io/gorm"
@@ -21,11 +21,15 @@ type Runner struct {
 	Cfg    *config.Config
 	Logger *log.Logger
 	Export *ExportClient
+
+	// Opts holds per-run overrides set by the CLI; zero value = env defaults.
+	Opts SyncOptions
 }
 
 // Accept cfg and store it so r.Cfg is valid everywhere
 func NewRunner(db *gorm.DB, cfg *config.Config, lg *log.Logger) *Runner {
 	export := NewExportClient(
+		cfg.PhorestBaseURL,
 		cfg.PhorestUsername,
 		cfg.PhorestPassword,
 		cfg.PhorestBusiness,
@@ -39,102 +43,157 @@ func NewRunner(db *gorm.DB, cfg *config.Config, lg *log.Logger) *Runner {
 	}
 }
 
-// ImportAllTransactionsCSVs loops through all .csv files in a directory and imports them.
-func (r *Runner) ImportAllTransactionsCSVs(dir string) error {
+// ImportAllTransactionsCSVs imports every transactions CSV in the seed
+// archive (plain, .csv.gz or .csv.zst, in the dated layout or flat). A file
+// that fails is logged and skipped.
+func (r *Runner) ImportAllTransactionsCSVs(ctx context.Context, arc *archive.Archive) error {
 	lg := r.Logger
-	lg.Printf("🔍 Scanning directory: %s", dir)
-
-	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
-	if err != nil {
-		return fmt.Errorf("read directory: %w", err)
-	}
-	if len(paths) == 0 {
-		lg.Printf("⚠️  No CSV files found in %s", dir)
-		return nil
-	}
+	lg.Printf("🔍 Scanning %s", arc.Location(archiveTransactions))
 
-	lg.Printf("📂 Found %d CSV files", len(paths))
-	for _, path := range paths {
-		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	err := r.eachArchivedCSV(ctx, arc, archiveTransactions, func(path string) error {
+		name := strings.TrimSuffix(archive.OriginalName(path), ".csv")
 		lg.Printf("──────────────────────────────────────────────")
 		lg.Printf("🏁 Starting import for file: %s", name)
 
-		if err := r.importSingleTransactionsCSV(path); err != nil {
+		_, err := r.importSingleTransactionsCSV(ctx, path, nil)
+		switch {
+		case errors.Is(err, errAlreadyImported):
+			lg.Printf("⏭  %v", err)
+		case err != nil:
 			lg.Printf("❌ Failed import for %s: %v", name, err)
-			continue
+		default:
+			lg.Printf("✅ Completed import for %s", name)
 		}
-		lg.Printf("✅ Completed import for %s", name)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	lg.Printf("🎉 All CSV imports complete.")
 	return nil
 }
 
-func (r *Runner) importSingleTransactionsCSV(csvPath string) error {
+// transactionsImport is what importSingleTransactionsCSV upserted.
+type transactionsImport struct {
+	Transactions int // headers merged, summed over chunks
+	Items        int
+
+	// MaxUpdated is the newest updated_at_phorest among the items, nil if
+	// none had one.
+	MaxUpdated *time.Time
+}
+
+// importSingleTransactionsCSV streams one file into the DB in chunks of
+// TRANSACTIONS_IMPORT_CHUNK items (COPY into staging, then merge) and reports
+// what it upserted. The file, its rejected cells and its core.import_ledger
+// entry (against runID, nil outside a sync) are written in one transaction;
+// a file already in the ledger gives errAlreadyImported.
+func (r *Runner) importSingleTransactionsCSV(ctx context.Context, csvPath string, runID *int64) (*transactionsImport, error) {
 	lg := r.Logger
+	name := filepath.Base(csvPath)
 
-	batch, err := ParseTransactionsCSV(csvPath, lg)
+	entry, err := r.checkLedger(transactionsCSVSchema.Name, csvPath, runID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	lg.Printf("Importing CSV %s: %d transactions, %d items", csvPath, len(batch.Transactions), len(batch.Items))
+	chunkSize := getIntEnv("TRANSACTIONS_IMPORT_CHUNK", 20000)
+	started := time.Now()
 
-	tx := r.DB.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-	defer func() {
-		if p := recover(); p != nil {
-			_ = tx.Rollback()
-			panic(p)
+	lg.Printf("Importing CSV %s in chunks of %d items", csvPath, chunkSize)
+
+	imp := &transactionsImport{}
+	rec := &repos.ImportRecord{Entry: entry}
+	err = repos.NewTransactionsRepo(r.DB, lg).CopyImport(ctx, rec, func(load repos.TransactionsLoadFunc) error {
+		err := StreamTransactionsCSV(csvPath, chunkSize, lg, func(batch *ParsedBatch) error {
+			if err := load(batch.Transactions, batch.Items); err != nil {
+				return err
+			}
+			rec.Rejects = append(rec.Rejects, batch.Rejects...)
+			imp.Transactions += len(batch.Transactions)
+			imp.Items += len(batch.Items)
+			for i := range batch.Items {
+				if ts := batch.Items[i].UpdatedAtPhorest; ts != nil {
+					if imp.MaxUpdated == nil || ts.After(*imp.MaxUpdated) {
+						imp.MaxUpdated = ts
+					}
+				}
+			}
+			return nil
+		}, func(rows int, done float64) {
+			lg.Printf("📈 %s: %d rows (%.0f%%) in %s", name, rows, done*100, time.Since(started).Round(time.Second))
+		})
+		if err != nil {
+			return err
 		}
-	}()
+		r.logRejects(csvPath, len(rec.Rejects))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	tr := repos.NewTransactionsRepo(tx, lg)
-	ir := repos.NewItemsRepo(tx, lg)
+	lg.Printf("✅ CSV %s committed.", name)
+	return imp, nil
+}
 
-	if err := tr.UpsertBatch(batch.Transactions, 500); err != nil {
-		_ = tx.Rollback()
-		return err
+// recordRejects stores the cells of csvPath that failed its schema, through
+// db (the import's transaction).
+func (r *Runner) recordRejects(db *gorm.DB, csvPath string, rejects []models.ImportReject) error {
+	if len(rejects) == 0 {
+		return nil
 	}
-	if err := ir.UpsertBatch(batch.Items, 500); err != nil {
-		_ = tx.Rollback()
-		return err
+	r.logRejects(csvPath, len(rejects))
+	if err := repos.NewImportRejectsRepo(db, r.Logger).Insert(rejects); err != nil {
+		return fmt.Errorf("record import rejects for %s: %w", csvPath, err)
 	}
-
-	if err := tx.Commit().Error; err != nil {
-		return err
-	}
-	lg.Printf("✅ CSV %s committed.", filepath.Base(csvPath))
 	return nil
 }
 
-// ImportAllClientCSVs scans a dir and imports every .csv as clients
-func (r *Runner) ImportAllClientCSVs(dir string) error {
-	r.Logger.Printf("🔍 Scanning clients dir: %s", dir)
-	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
-	if err != nil {
-		return fmt.Errorf("read directory: %w", err)
+func (r *Runner) logRejects(csvPath string, n int) {
+	if n > 0 {
+		r.Logger.Printf("⚠️ %s: %d invalid cell(s); rows kept out and recorded in raw.import_rejects",
+			filepath.Base(csvPath), n)
 	}
-	if len(paths) == 0 {
-		r.Logger.Printf("⚠️  No client CSV files found in %s", dir)
-		return nil
-	}
-	for _, p := range paths {
-		if err := r.importSingleClientsCSV(p); err != nil {
-			r.Logger.Printf("❌ Client import failed: %s: %v", p, err)
-			continue
+}
+
+// ImportAllClientCSVs imports every clients CSV in the seed archive, like
+// ImportAllTransactionsCSVs.
+func (r *Runner) ImportAllClientCSVs(ctx context.Context, arc *archive.Archive) error {
+	r.Logger.Printf("🔍 Scanning clients: %s", arc.Location(archiveClients))
+
+	err := r.eachArchivedCSV(ctx, arc, archiveClients, func(p string) error {
+		_, err := r.importSingleClientsCSV(p, r.watermarks(), nil)
+		switch {
+		case errors.Is(err, errAlreadyImported):
+			r.Logger.Printf("⏭  %v", err)
+		case err != nil:
+			r.Logger.Printf("❌ Client import failed: %s: %v", archive.OriginalName(p), err)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 	r.Logger.Printf("✅ All client CSV imports complete.")
 	return nil
 }
 
-func (r *Runner) importSingleClientsCSV(csvPath string) error {
+// importSingleClientsCSV imports one file and reports how many clients it
+// upserted, advancing the clients_csv watermark through wr. Like
+// importSingleTransactionsCSV it goes through core.import_ledger, and the
+// clients, watermark, rejects and ledger entry commit together.
+func (r *Runner) importSingleClientsCSV(csvPath string, wr *repos.WatermarksRepo, runID *int64) (int, error) {
 	lg := r.Logger
+
+	entry, err := r.checkLedger(clientsCSVSchema.Name, csvPath, runID)
+	if err != nil {
+		return 0, err
+	}
+
 	batch, err := ParseClientsCSV(csvPath, lg)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	lg.Printf("Importing Clients CSV %s: %d clients", csvPath, len(batch.Clients))
 
@@ -152,7 +211,7 @@ func (r *Runner) importSingleClientsCSV(csvPath string) error {
 
 	tx := r.DB.Begin()
 	if tx.Error != nil {
-		return tx.Error
+		return 0, tx.Error
 	}
 	defer func() {
 		if p := recover(); p != nil {
@@ -164,73 +223,41 @@ func (r *Runner) importSingleClientsCSV(csvPath string) error {
 	cr := repos.NewClientsRepo(tx, lg)
 	if err := cr.UpsertBatch(batch.Clients, 1000); err != nil {
 		_ = tx.Rollback()
-		return err
+		return 0, err
 	}
 
 	if maxTS != nil {
-		wr := repos.NewWatermarksRepo(tx, lg)
-		// NOTE: branch = "ALL" for global clients CSV
-		if err := wr.UpsertLastUpdated("clients_csv", "ALL", *maxTS); err != nil {
+		wr := wr.WithTx(tx)
+		// NOTE: branch = "ALL" (per business, see businessWideKey) for global clients CSV
+		if err := wr.UpsertLastUpdated(repos.WatermarkClientsCSV, r.businessWideKey(), *maxTS); err != nil {
 			_ = tx.Rollback()
-			return fmt.Errorf("update clients_csv watermark: %w", err)
+			return 0, fmt.Errorf("update clients_csv watermark: %w", err)
 		}
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return err
-	}
-	lg.Printf("✅ Clients CSV %s committed.", csvPath)
-	return nil
-}
-
-// archiveCSVToSeed copies a CSV from srcPath into destDir
-// so it becomes part of the “bootstrap” dataset.
-func (r *Runner) archiveCSVToSeed(srcPath, destDir string) {
-	lg := r.Logger
-
-	if err := os.MkdirAll(destDir, 0o755); err != nil {
-		lg.Printf("⚠️  archiveCSVToSeed: unable to create dir %s: %v", destDir, err)
-		return
-	}
-
-	dstPath := filepath.Join(destDir, filepath.Base(srcPath))
-
-	// Don’t hard fail the sync if this fails – just log it.
-	if err := copyFile(srcPath, dstPath); err != nil {
-		lg.Printf("⚠️  archiveCSVToSeed: copy %s → %s failed: %v", srcPath, dstPath, err)
-		return
+	if err := r.recordRejects(tx, csvPath, batch.Rejects); err != nil {
+		_ = tx.Rollback()
+		return 0, err
 	}
-
-	lg.Printf("📦 Archived %s → %s (for future bootstrap)", srcPath, dstPath)
-}
-
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
+	if err := r.recordImport(tx, entry, len(batch.Clients), len(batch.Rejects)); err != nil {
+		_ = tx.Rollback()
+		return 0, err
 	}
-	defer in.Close()
 
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = out.Close()
-	}()
-
-	if _, err := io.Copy(out, in); err != nil {
-		return err
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
 	}
-
-	// Make sure it’s flushed
-	return out.Sync()
+	lg.Printf("✅ Clients CSV %s committed.", csvPath)
+	return len(batch.Clients), nil
 }
 
+// BootstrapFromCSVsIfNeeded rebuilds transactions and clients from the seed
+// archive (local or object storage, see seedArchive) on a DB with no
+// watermarks yet, then seeds every watermark from the result.
 func (r *Runner) BootstrapFromCSVsIfNeeded() error {
 	lg := r.Logger
 
-	entities := []string{"clients_csv", "transactions_csv"}
+	entities := []repos.WatermarkEntity{repos.WatermarkClientsCSV, repos.WatermarkTransactionsCSV}
 
 	var watermarkCount int64
 	if err := r.DB.Table("sync_watermarks").Count(&watermarkCount).Error; err != nil {
@@ -256,15 +283,20 @@ func (r *Runner) BootstrapFromCSVsIfNeeded() error {
 
 	lg.Println("📥 Running one-off CSV bootstrap (transactions + clients)...")
 
-	if err := r.ImportAllTransactionsCSVs("data/transactions"); err != nil {
+	arc, err := r.seedArchive()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := r.ImportAllTransactionsCSVs(ctx, arc); err != nil {
 		return fmt.Errorf("bootstrap transactions CSVs: %w", err)
 	}
 
-	if err := r.ImportAllClientCSVs("data/clients"); err != nil {
+	if err := r.ImportAllClientCSVs(ctx, arc); err != nil {
 		return fmt.Errorf("bootstrap clients CSVs: %w", err)
 	}
 
-	// Seeds: transactions_csv, clients_csv
+	// Seeds every entity from what was just imported (see watermarkSeeds)
 	if err := r.BootstrapWatermarks(); err != nil {
 		return fmt.Errorf("bootstrap watermarks: %w", err)
 	}
@@ -277,6 +309,7 @@ func (r *Runner) SyncStaffWorkTimetables(from, to time.Time) error {
 	lg := r.Logger
 
 	client := NewStaffWorkTimetableClient(
+		r.Cfg.PhorestBaseURL,
 		r.Cfg.PhorestUsername,
 		r.Cfg.PhorestPassword,
 		r.Cfg.PhorestBusiness,
@@ -287,7 +320,7 @@ func (r *Runner) SyncStaffWorkTimetables(from, to time.Time) error {
 
 	ctx := context.Background()
 
-	for _, br := range r.Cfg.Branches {
+	for _, br := range r.branches() {
 		branchID := br.BranchID
 
 		windowStart := dateOnly(from.UTC())
@@ -11,25 +11,161 @@ import (
 	"strings"
 	"time"
 
+	"github.com/araquach/phorest-datahub/internal/archive"
 	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
+// transactionsCSVSchema declares the TRANSACTIONS_CSV columns we read.
+var transactionsCSVSchema = csvSchema{
+	Name: string(repos.WatermarkTransactionsCSV),
+	Columns: []csvColumn{
+		{Name: "transaction_item_id", Type: colText},
+		{Name: "transaction_id", Type: colText, Required: true},
+		{Name: "branch_id", Type: colText},
+		{Name: "branch_name", Type: colText},
+		{Name: "client_id", Type: colText},
+		{Name: "client_first_name", Type: colText},
+		{Name: "client_last_name", Type: colText},
+		{Name: "client_source", Type: colText},
+		{Name: "purchased_date", Type: colDate},
+		{Name: "purchase_time", Type: colClock},
+		{Name: "item_type", Type: colText},
+		{Name: "description", Type: colText},
+		{Name: "quantity", Type: colNumber},
+		{Name: "purchase_voucher_discount_percentage", Type: colNumber},
+		{Name: "purchase_online_deposit", Type: colNumber},
+		{Name: "purchase_online_discount_amount", Type: colNumber},
+		{Name: "service_id", Type: colText},
+		{Name: "service_name", Type: colText},
+		{Name: "service_category_id", Type: colText},
+		{Name: "service_category_name", Type: colText},
+		{Name: "package_id", Type: colText},
+		{Name: "package_name", Type: colText},
+		{Name: "special_offer_id", Type: colText},
+		{Name: "special_offer_name", Type: colText},
+		{Name: "product_id", Type: colText},
+		{Name: "product_name", Type: colText},
+		{Name: "product_brand_id", Type: colText},
+		{Name: "product_brand_name", Type: colText},
+		{Name: "product_category_id", Type: colText},
+		{Name: "product_category_name", Type: colText},
+		{Name: "product_barcode", Type: colText},
+		{Name: "product_code", Type: colText},
+		{Name: "course_id", Type: colText},
+		{Name: "course_name", Type: colText},
+		{Name: "client_course_name", Type: colText},
+		{Name: "voucher_serial", Type: colText},
+		{Name: "service_reward_id", Type: colText},
+		{Name: "service_reward_name", Type: colText},
+		{Name: "product_reward_id", Type: colText},
+		{Name: "product_reward_name", Type: colText},
+		{Name: "unit_price", Type: colNumber},
+		{Name: "original_price", Type: colNumber},
+		{Name: "discount_type", Type: colNumber},
+		{Name: "discount_value", Type: colNumber},
+		{Name: "item_online_deposit", Type: colNumber},
+		{Name: "item_online_discount", Type: colNumber},
+		{Name: "loyalty_points_awarded", Type: colNumber},
+		{Name: "tax_rate", Type: colNumber},
+		{Name: "total_amount", Type: colNumber},
+		{Name: "total_amount_pre_vouch_disc", Type: colNumber},
+		{Name: "net_total_amount", Type: colNumber},
+		{Name: "gross_total_amount", Type: colNumber},
+		{Name: "net_price", Type: colNumber},
+		{Name: "gross_price", Type: colNumber},
+		{Name: "discount_amount", Type: colNumber},
+		{Name: "tax_amount", Type: colNumber},
+		{Name: "staff_tips", Type: colNumber},
+		{Name: "product_cost_price", Type: colNumber},
+		{Name: "service_cost", Type: colNumber},
+		{Name: "service_cost_type", Type: colText},
+		{Name: "gross_total_with_discount", Type: colNumber},
+		{Name: "gross_total_with_discount_minus_tax", Type: colNumber},
+		{Name: "simple_discount_amount", Type: colNumber},
+		{Name: "membership_benefit_used", Type: colInteger},
+		{Name: "membership_discount_amount", Type: colNumber},
+		{Name: "deal", Type: colNumber},
+		{Name: "session_net_amount", Type: colNumber},
+		{Name: "session_gross_amount", Type: colNumber},
+		{Name: "phorest_tips", Type: colNumber},
+		{Name: "payment_type", Type: colText},
+		{Name: "payment_type_ids", Type: colText},
+		{Name: "payment_type_amounts", Type: colNumber},
+		{Name: "payment_type_codes", Type: colText},
+		{Name: "payment_type_names", Type: colText},
+		{Name: "payment_type_voucher_serials", Type: colText},
+		{Name: "payment_type_prepaid_tax_amounts", Type: colText},
+		{Name: "outstanding_balance_pmt", Type: colInteger},
+		{Name: "open_sale", Type: colBoolean},
+		{Name: "open_sale_type", Type: colText},
+		{Name: "purchase_type", Type: colText},
+		{Name: "online_booking", Type: colInteger},
+		{Name: "void", Type: colInteger},
+		{Name: "voided_transaction_id", Type: colText},
+		{Name: "void_reason", Type: colText},
+		{Name: "department_id", Type: colText},
+		{Name: "department_name", Type: colText},
+		{Name: "staff_id", Type: colText},
+		{Name: "staff_first_name", Type: colText},
+		{Name: "staff_last_name", Type: colText},
+		{Name: "staff_category_id", Type: colText},
+		{Name: "staff_category_name", Type: colText},
+		{Name: "is_requested_staff", Type: colInteger},
+		{Name: "primary_staff_id", Type: colText},
+		{Name: "preferred_staff_id", Type: colText},
+		{Name: "preferred_staff_name", Type: colText},
+		{Name: "appointment_id", Type: colText},
+		{Name: "appointment_date", Type: colDate},
+		{Name: "appointment_created", Type: colTimestamp},
+		{Name: "appointment_rating", Type: colInteger},
+		{Name: "client_birthday", Type: colDate},
+		{Name: "client_gender", Type: colText},
+		{Name: "client_email", Type: colText},
+		{Name: "client_first_visit", Type: colDate},
+		{Name: "appt_client_id", Type: colText},
+		{Name: "appt_client_first_name", Type: colText},
+		{Name: "appt_client_last_name", Type: colText},
+		{Name: "appt_client_birthday", Type: colDate},
+		{Name: "appt_client_gender", Type: colText},
+		{Name: "appt_client_email", Type: colText},
+		{Name: "appt_client_first_visit", Type: colDate},
+		{Name: "internet_category_ids", Type: colText},
+		{Name: "internet_category_names", Type: colText},
+		{Name: "branch_product_id", Type: colText},
+		{Name: "fixed_discount_id", Type: colText},
+		{Name: "fixed_discount_name", Type: colText},
+		{Name: "client_course_id", Type: colText},
+		{Name: "creating_user", Type: colText},
+		{Name: "tax_rate_name", Type: colText},
+		{Name: "sale_fee_id", Type: colText},
+		{Name: "purchase_updated_at", Type: colTimestamp},
+	},
+}
+
+// ParsedBatch is one chunk of a transactions CSV split into headers and items.
 type ParsedBatch struct {
 	Transactions []models.Transaction
 	Items        []models.TransactionItem
+	Rejects      []models.ImportReject // cells that failed transactionsCSVSchema
 }
 
-// ParseTransactionsCSV reads a Phorest transactions CSV and returns split header/items.
-// It’s header-driven (no hard-coded positions), tolerant to extra/missing columns,
-// and builds one Transaction per unique transaction_id (choosing newest by updated_at_phorest).
-func ParseTransactionsCSV(path string, lg *log.Logger) (*ParsedBatch, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("open csv: %w", err)
-	}
-	defer f.Close()
+// TransactionsCSVReader reads a Phorest transactions CSV one line at a time.
+// It’s header-driven (no hard-coded positions) and tolerant to extra/missing
+// columns, but rows with a cell that fails transactionsCSVSchema are
+// rejected rather than read as zero / NULL.
+type TransactionsCSVReader struct {
+	r       *csv.Reader
+	idx     map[string]int
+	v       *csvValidator
+	row     int
+	rejects []models.ImportReject
+}
 
-	r := csv.NewReader(f)
+// NewTransactionsCSVReader reads the header line from rd and checks it
+// against transactionsCSVSchema; file names rd in rejects and logs.
+func NewTransactionsCSVReader(rd io.Reader, file string, lg *log.Logger) (*TransactionsCSVReader, error) {
+	r := csv.NewReader(rd)
 	r.ReuseRecord = true
 
 	// Header map
@@ -41,294 +177,408 @@ func ParseTransactionsCSV(path string, lg *log.Logger) (*ParsedBatch, error) {
 	for i, h := range header {
 		idx[strings.TrimSpace(strings.ToLower(h))] = i
 	}
+	v, err := transactionsCSVSchema.validator(file, header, lg)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionsCSVReader{r: r, idx: idx, v: v}, nil
+}
 
-	// Helpers to read fields safely
-	get := func(rec []string, name string) string {
-		i, ok := idx[name]
-		if !ok || i >= len(rec) {
-			return ""
+// Next returns the next valid line as an item; invalid rows are set aside
+// (see TakeRejects). It returns io.EOF at the end of the file.
+func (t *TransactionsCSVReader) Next() (*models.TransactionItem, error) {
+	for {
+		rec, err := t.r.Read()
+		if err == io.EOF {
+			return nil, io.EOF
 		}
-		return rec[i]
-	}
-	parseFloat := func(s string) float64 {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return 0
+		if err != nil {
+			return nil, fmt.Errorf("read row %d: %w", t.row, err)
 		}
-		v, _ := strconv.ParseFloat(s, 64)
-		return v
-	}
-	parseInt64 := func(s string) int64 {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return 0
+		t.row++
+		line, _ := t.r.FieldPos(0)
+
+		for i := range rec {
+			rec[i] = cleanUTF8(rec[i])
 		}
-		v, _ := strconv.ParseInt(s, 10, 64)
-		return v
-	}
-	parseInt := func(s string) int {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return 0
+
+		if rj := t.v.validate(rec, line); len(rj) > 0 {
+			t.rejects = append(t.rejects, rj...)
+			continue
 		}
-		v, _ := strconv.Atoi(s)
-		return v
+		item := t.item(rec)
+		return &item, nil
+	}
+}
+
+// TakeRejects returns the cells rejected since the last call.
+func (t *TransactionsCSVReader) TakeRejects() []models.ImportReject {
+	rj := t.rejects
+	t.rejects = nil
+	return rj
+}
+
+// Rows is how many data lines have been read so far.
+func (t *TransactionsCSVReader) Rows() int { return t.row }
+
+// get reads a field safely
+func (t *TransactionsCSVReader) get(rec []string, name string) string {
+	i, ok := t.idx[name]
+	if !ok || i >= len(rec) {
+		return ""
 	}
-	parseBool := func(s string) bool {
-		// CSV often uses 0/1 or true/false
-		s = strings.TrimSpace(strings.ToLower(s))
-		return s == "1" || s == "true" || s == "t" || s == "yes" || s == "y"
+	return rec[i]
+}
+
+func (t *TransactionsCSVReader) item(rec []string) models.TransactionItem {
+	// Map line → TransactionItem (1:1)
+	return models.TransactionItem{
+		TransactionItemID: t.get(rec, "transaction_item_id"),
+		TransactionID:     t.get(rec, "transaction_id"),
+
+		BranchID:        t.get(rec, "branch_id"),
+		BranchName:      t.get(rec, "branch_name"),
+		ClientID:        t.get(rec, "client_id"),
+		ClientFirstName: t.get(rec, "client_first_name"),
+		ClientLastName:  t.get(rec, "client_last_name"),
+		ClientSource:    t.get(rec, "client_source"),
+		PurchasedDate:   csvDate(t.get(rec, "purchased_date")),
+		PurchaseTime:    csvClock(t.get(rec, "purchase_time")),
+
+		ItemType:    t.get(rec, "item_type"),
+		Description: t.get(rec, "description"),
+		Quantity:    csvFloat(t.get(rec, "quantity")),
+
+		PurchaseVoucherDiscountPercentage: csvFloat(t.get(rec, "purchase_voucher_discount_percentage")),
+		PurchaseOnlineDeposit:             csvFloat(t.get(rec, "purchase_online_deposit")),
+		PurchaseOnlineDiscountAmount:      csvFloat(t.get(rec, "purchase_online_discount_amount")),
+
+		ServiceID:           t.get(rec, "service_id"),
+		ServiceName:         t.get(rec, "service_name"),
+		ServiceCategoryID:   t.get(rec, "service_category_id"),
+		ServiceCategoryName: t.get(rec, "service_category_name"),
+
+		PackageID:        t.get(rec, "package_id"),
+		PackageName:      t.get(rec, "package_name"),
+		SpecialOfferID:   t.get(rec, "special_offer_id"),
+		SpecialOfferName: t.get(rec, "special_offer_name"),
+
+		ProductID:           t.get(rec, "product_id"),
+		ProductName:         t.get(rec, "product_name"),
+		ProductBrandID:      t.get(rec, "product_brand_id"),
+		ProductBrandName:    t.get(rec, "product_brand_name"),
+		ProductCategoryID:   t.get(rec, "product_category_id"),
+		ProductCategoryName: t.get(rec, "product_category_name"),
+		ProductBarcode:      t.get(rec, "product_barcode"),
+		ProductCode:         t.get(rec, "product_code"),
+
+		CourseID:          t.get(rec, "course_id"),
+		CourseName:        t.get(rec, "course_name"),
+		ClientCourseName:  t.get(rec, "client_course_name"),
+		VoucherSerial:     t.get(rec, "voucher_serial"),
+		ServiceRewardID:   t.get(rec, "service_reward_id"),
+		ServiceRewardName: t.get(rec, "service_reward_name"),
+		ProductRewardID:   t.get(rec, "product_reward_id"),
+		ProductRewardName: t.get(rec, "product_reward_name"),
+
+		UnitPrice:                      csvFloat(t.get(rec, "unit_price")),
+		OriginalPrice:                  csvFloat(t.get(rec, "original_price")),
+		DiscountType:                   csvFloat(t.get(rec, "discount_type")),
+		DiscountValue:                  csvFloat(t.get(rec, "discount_value")),
+		ItemOnlineDeposit:              csvFloat(t.get(rec, "item_online_deposit")),
+		ItemOnlineDiscount:             csvFloat(t.get(rec, "item_online_discount")),
+		LoyaltyPointsAwarded:           csvFloat(t.get(rec, "loyalty_points_awarded")),
+		TaxRate:                        csvFloat(t.get(rec, "tax_rate")),
+		TotalAmount:                    csvFloat(t.get(rec, "total_amount")),
+		TotalAmountPreVouchDisc:        csvFloat(t.get(rec, "total_amount_pre_vouch_disc")),
+		NetTotalAmount:                 csvFloat(t.get(rec, "net_total_amount")),
+		GrossTotalAmount:               csvFloat(t.get(rec, "gross_total_amount")),
+		NetPrice:                       csvFloat(t.get(rec, "net_price")),
+		GrossPrice:                     csvFloat(t.get(rec, "gross_price")),
+		DiscountAmount:                 csvFloat(t.get(rec, "discount_amount")),
+		TaxAmount:                      csvFloat(t.get(rec, "tax_amount")),
+		StaffTips:                      csvFloat(t.get(rec, "staff_tips")),
+		ProductCostPrice:               csvFloat(t.get(rec, "product_cost_price")),
+		ServiceCost:                    csvFloat(t.get(rec, "service_cost")),
+		ServiceCostType:                t.get(rec, "service_cost_type"),
+		GrossTotalWithDiscount:         csvFloat(t.get(rec, "gross_total_with_discount")),
+		GrossTotalWithDiscountMinusTax: csvFloat(t.get(rec, "gross_total_with_discount_minus_tax")),
+		SimpleDiscountAmount:           csvFloat(t.get(rec, "simple_discount_amount")),
+		MembershipBenefitUsed:          csvInt(t.get(rec, "membership_benefit_used")),
+		MembershipDiscountAmount:       csvFloat(t.get(rec, "membership_discount_amount")),
+		Deal:                           csvFloat(t.get(rec, "deal")),
+		SessionNetAmount:               csvFloat(t.get(rec, "session_net_amount")),
+		SessionGrossAmount:             csvFloat(t.get(rec, "session_gross_amount")),
+		PhorestTips:                    csvFloat(t.get(rec, "phorest_tips")),
+
+		PaymentType:                  t.get(rec, "payment_type"),
+		PaymentTypeIDs:               t.get(rec, "payment_type_ids"),
+		PaymentTypeAmounts:           csvFloat(t.get(rec, "payment_type_amounts")),
+		PaymentTypeCodes:             t.get(rec, "payment_type_codes"),
+		PaymentTypeNames:             t.get(rec, "payment_type_names"),
+		PaymentTypeVoucherSerials:    t.get(rec, "payment_type_voucher_serials"),
+		PaymentTypePrepaidTaxAmounts: t.get(rec, "payment_type_prepaid_tax_amounts"),
+
+		OutstandingBalancePMT: csvInt64(t.get(rec, "outstanding_balance_pmt")),
+		OpenSale:              csvBool(t.get(rec, "open_sale")),
+		OpenSaleType:          t.get(rec, "open_sale_type"),
+		PurchaseType:          t.get(rec, "purchase_type"),
+		OnlineBooking:         csvInt64(t.get(rec, "online_booking")),
+		Void:                  csvInt64(t.get(rec, "void")),
+		VoidedTransactionID:   t.get(rec, "voided_transaction_id"),
+		VoidReason:            t.get(rec, "void_reason"),
+
+		DepartmentID:   t.get(rec, "department_id"),
+		DepartmentName: t.get(rec, "department_name"),
+
+		StaffID:            t.get(rec, "staff_id"),
+		StaffFirstName:     t.get(rec, "staff_first_name"),
+		StaffLastName:      t.get(rec, "staff_last_name"),
+		StaffCategoryID:    t.get(rec, "staff_category_id"),
+		StaffCategoryName:  t.get(rec, "staff_category_name"),
+		IsRequestedStaff:   csvInt(t.get(rec, "is_requested_staff")),
+		PrimaryStaffID:     t.get(rec, "primary_staff_id"),
+		PreferredStaffID:   t.get(rec, "preferred_staff_id"),
+		PreferredStaffName: t.get(rec, "preferred_staff_name"),
+
+		AppointmentID:      t.get(rec, "appointment_id"),
+		AppointmentDate:    csvDate(t.get(rec, "appointment_date")),
+		AppointmentCreated: csvTS(t.get(rec, "appointment_created")),
+		AppointmentRating:  csvInt64(t.get(rec, "appointment_rating")),
+
+		ClientBirthday:   csvDate(t.get(rec, "client_birthday")),
+		ClientGender:     t.get(rec, "client_gender"),
+		ClientEmail:      t.get(rec, "client_email"),
+		ClientFirstVisit: csvDate(t.get(rec, "client_first_visit")),
+
+		ApptClientID:         t.get(rec, "appt_client_id"),
+		ApptClientFirstName:  t.get(rec, "appt_client_first_name"),
+		ApptClientLastName:   t.get(rec, "appt_client_last_name"),
+		ApptClientBirthday:   csvDate(t.get(rec, "appt_client_birthday")),
+		ApptClientGender:     t.get(rec, "appt_client_gender"),
+		ApptClientEmail:      t.get(rec, "appt_client_email"),
+		ApptClientFirstVisit: csvDate(t.get(rec, "appt_client_first_visit")),
+
+		InternetCategoryIDs:   t.get(rec, "internet_category_ids"),
+		InternetCategoryNames: t.get(rec, "internet_category_names"),
+		BranchProductID:       t.get(rec, "branch_product_id"),
+		FixedDiscountID:       t.get(rec, "fixed_discount_id"),
+		FixedDiscountName:     t.get(rec, "fixed_discount_name"),
+		ClientCourseID:        t.get(rec, "client_course_id"),
+		CreatingUser:          t.get(rec, "creating_user"),
+		TaxRateName:           t.get(rec, "tax_rate_name"),
+		SaleFeeID:             t.get(rec, "sale_fee_id"),
+
+		UpdatedAtPhorest: csvTS(t.get(rec, "purchase_updated_at")),
 	}
-	parseDate := func(s string) *time.Time {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return nil
-		}
-		// Common formats in your samples
-		layouts := []string{"2006-01-02"}
-		for _, layout := range layouts {
-			if t, err := time.Parse(layout, s); err == nil {
-				return &t
-			}
-		}
-		return nil
+}
+
+// StreamTransactionsCSV reads path in chunks of up to chunkSize items
+// (<= 0 = the whole file in one chunk) and calls fn with each. Within a chunk
+// there is one Transaction per transaction_id, the newest by
+// updated_at_phorest; callers merging chunks must keep that rule across them.
+// Rows failing the schema come back in the chunk's Rejects, not its Items.
+// progress (may be nil) is called after each chunk with the share of the
+// file read so far (0..1). Compressed archives (.csv.gz, .csv.zst) are read
+// transparently.
+func StreamTransactionsCSV(path string, chunkSize int, lg *log.Logger, fn func(*ParsedBatch) error, progress func(rows int, done float64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open csv: %w", err)
+	}
+	defer f.Close()
+
+	// Count bytes of the file itself, so progress is right for compressed
+	// archives too.
+	var size int64
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+	cr := &countingReader{r: f}
+
+	rd, err := archive.NewReader(cr, path)
+	if err != nil {
+		return fmt.Errorf("open csv: %w", err)
+	}
+	defer rd.Close()
+
+	tr, err := NewTransactionsCSVReader(rd, path, lg)
+	if err != nil {
+		return err
 	}
-	parseClock := func(s string) *time.Time {
-		s = strings.TrimSpace(s)
-		if s == "" {
+
+	var (
+		items  = make([]models.TransactionItem, 0, 2048)
+		txByID = map[string]models.Transaction{}
+		txns   int
+		total  int
+	)
+
+	flush := func() error {
+		rejects := tr.TakeRejects()
+		if len(items) == 0 && len(rejects) == 0 {
 			return nil
 		}
-		for _, layout := range []string{"15:04:05.000", "15:04:05", "15:04"} {
-			if t, err := time.Parse(layout, s); err == nil {
-				return &t
-			}
+		batch := &ParsedBatch{
+			Transactions: make([]models.Transaction, 0, len(txByID)),
+			Items:        items,
+			Rejects:      rejects,
 		}
-		return nil
-	}
-	parseTS := func(s string) *time.Time {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return nil
+		for _, v := range txByID {
+			batch.Transactions = append(batch.Transactions, v)
+		}
+		if err := fn(batch); err != nil {
+			return err
 		}
-		// observed formats (no TZ, or ISO with T)
-		for _, layout := range []string{
-			time.RFC3339Nano,
-			time.RFC3339,
-			"2006-01-02 15:04:05",
-			"2006-01-02T15:04:05.000",
-			"2006-01-02T15:04:05",
-		} {
-			if t, err := time.Parse(layout, s); err == nil {
-				return &t
+		txns += len(batch.Transactions)
+		total += len(items)
+		if progress != nil {
+			done := 1.0
+			if size > 0 {
+				done = min(float64(cr.n)/float64(size), 1)
 			}
+			progress(tr.Rows(), done)
 		}
+		items = make([]models.TransactionItem, 0, cap(items))
+		txByID = map[string]models.Transaction{}
 		return nil
 	}
 
-	items := make([]models.TransactionItem, 0, 2048)
-	// Use a map to keep one header per transaction_id, selecting the newest updated_at_phorest
-	txByID := map[string]models.Transaction{}
-
-	row := 0
 	for {
-		rec, err := r.Read()
+		item, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("read row %d: %w", row, err)
+			return err
 		}
-		row++
+		items = append(items, *item)
+		keepNewestTransaction(txByID, item)
 
-		for i := range rec {
-			rec[i] = cleanUTF8(rec[i])
+		if chunkSize > 0 && len(items) >= chunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
 		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
 
-		transactionID := get(rec, "transaction_id")
-		if transactionID == "" {
-			// Skip malformed rows
-			continue
-		}
+	lg.Printf("Parsed CSV: %d transactions, %d items", txns, total)
+	return nil
+}
 
-		// Map line → TransactionItem (1:1)
-		item := models.TransactionItem{
-			TransactionItemID: get(rec, "transaction_item_id"),
-			TransactionID:     transactionID,
-
-			BranchID:        get(rec, "branch_id"),
-			BranchName:      get(rec, "branch_name"),
-			ClientID:        get(rec, "client_id"),
-			ClientFirstName: get(rec, "client_first_name"),
-			ClientLastName:  get(rec, "client_last_name"),
-			ClientSource:    get(rec, "client_source"),
-			PurchasedDate:   parseDate(get(rec, "purchased_date")),
-			PurchaseTime:    parseClock(get(rec, "purchase_time")),
-
-			ItemType:    get(rec, "item_type"),
-			Description: get(rec, "description"),
-			Quantity:    parseFloat(get(rec, "quantity")),
-
-			PurchaseVoucherDiscountPercentage: parseFloat(get(rec, "purchase_voucher_discount_percentage")),
-			PurchaseOnlineDeposit:             parseFloat(get(rec, "purchase_online_deposit")),
-			PurchaseOnlineDiscountAmount:      parseFloat(get(rec, "purchase_online_discount_amount")),
-
-			ServiceID:           get(rec, "service_id"),
-			ServiceName:         get(rec, "service_name"),
-			ServiceCategoryID:   get(rec, "service_category_id"),
-			ServiceCategoryName: get(rec, "service_category_name"),
-
-			PackageID:        get(rec, "package_id"),
-			PackageName:      get(rec, "package_name"),
-			SpecialOfferID:   get(rec, "special_offer_id"),
-			SpecialOfferName: get(rec, "special_offer_name"),
-
-			ProductID:           get(rec, "product_id"),
-			ProductName:         get(rec, "product_name"),
-			ProductBrandID:      get(rec, "product_brand_id"),
-			ProductBrandName:    get(rec, "product_brand_name"),
-			ProductCategoryID:   get(rec, "product_category_id"),
-			ProductCategoryName: get(rec, "product_category_name"),
-			ProductBarcode:      get(rec, "product_barcode"),
-			ProductCode:         get(rec, "product_code"),
-
-			CourseID:          get(rec, "course_id"),
-			CourseName:        get(rec, "course_name"),
-			ClientCourseName:  get(rec, "client_course_name"),
-			VoucherSerial:     get(rec, "voucher_serial"),
-			ServiceRewardID:   get(rec, "service_reward_id"),
-			ServiceRewardName: get(rec, "service_reward_name"),
-			ProductRewardID:   get(rec, "product_reward_id"),
-			ProductRewardName: get(rec, "product_reward_name"),
-
-			UnitPrice:                      parseFloat(get(rec, "unit_price")),
-			OriginalPrice:                  parseFloat(get(rec, "original_price")),
-			DiscountType:                   parseFloat(get(rec, "discount_type")),
-			DiscountValue:                  parseFloat(get(rec, "discount_value")),
-			ItemOnlineDeposit:              parseFloat(get(rec, "item_online_deposit")),
-			ItemOnlineDiscount:             parseFloat(get(rec, "item_online_discount")),
-			LoyaltyPointsAwarded:           parseFloat(get(rec, "loyalty_points_awarded")),
-			TaxRate:                        parseFloat(get(rec, "tax_rate")),
-			TotalAmount:                    parseFloat(get(rec, "total_amount")),
-			TotalAmountPreVouchDisc:        parseFloat(get(rec, "total_amount_pre_vouch_disc")),
-			NetTotalAmount:                 parseFloat(get(rec, "net_total_amount")),
-			GrossTotalAmount:               parseFloat(get(rec, "gross_total_amount")),
-			NetPrice:                       parseFloat(get(rec, "net_price")),
-			GrossPrice:                     parseFloat(get(rec, "gross_price")),
-			DiscountAmount:                 parseFloat(get(rec, "discount_amount")),
-			TaxAmount:                      parseFloat(get(rec, "tax_amount")),
-			StaffTips:                      parseFloat(get(rec, "staff_tips")),
-			ProductCostPrice:               parseFloat(get(rec, "product_cost_price")),
-			ServiceCost:                    parseFloat(get(rec, "service_cost")),
-			ServiceCostType:                get(rec, "service_cost_type"),
-			GrossTotalWithDiscount:         parseFloat(get(rec, "gross_total_with_discount")),
-			GrossTotalWithDiscountMinusTax: parseFloat(get(rec, "gross_total_with_discount_minus_tax")),
-			SimpleDiscountAmount:           parseFloat(get(rec, "simple_discount_amount")),
-			MembershipBenefitUsed:          parseInt(get(rec, "membership_benefit_used")),
-			MembershipDiscountAmount:       parseFloat(get(rec, "membership_discount_amount")),
-			Deal:                           parseFloat(get(rec, "deal")),
-			SessionNetAmount:               parseFloat(get(rec, "session_net_amount")),
-			SessionGrossAmount:             parseFloat(get(rec, "session_gross_amount")),
-			PhorestTips:                    parseFloat(get(rec, "phorest_tips")),
-
-			PaymentType:                  get(rec, "payment_type"),
-			PaymentTypeIDs:               get(rec, "payment_type_ids"),
-			PaymentTypeAmounts:           parseFloat(get(rec, "payment_type_amounts")),
-			PaymentTypeCodes:             get(rec, "payment_type_codes"),
-			PaymentTypeNames:             get(rec, "payment_type_names"),
-			PaymentTypeVoucherSerials:    get(rec, "payment_type_voucher_serials"),
-			PaymentTypePrepaidTaxAmounts: get(rec, "payment_type_prepaid_tax_amounts"),
-
-			OutstandingBalancePMT: parseInt64(get(rec, "outstanding_balance_pmt")),
-			OpenSale:              parseBool(get(rec, "open_sale")),
-			OpenSaleType:          get(rec, "open_sale_type"),
-			PurchaseType:          get(rec, "purchase_type"),
-			OnlineBooking:         parseInt64(get(rec, "online_booking")),
-			Void:                  parseInt64(get(rec, "void")),
-			VoidedTransactionID:   get(rec, "voided_transaction_id"),
-			VoidReason:            get(rec, "void_reason"),
-
-			DepartmentID:   get(rec, "department_id"),
-			DepartmentName: get(rec, "department_name"),
-
-			StaffID:            get(rec, "staff_id"),
-			StaffFirstName:     get(rec, "staff_first_name"),
-			StaffLastName:      get(rec, "staff_last_name"),
-			StaffCategoryID:    get(rec, "staff_category_id"),
-			StaffCategoryName:  get(rec, "staff_category_name"),
-			IsRequestedStaff:   parseInt(get(rec, "is_requested_staff")),
-			PrimaryStaffID:     get(rec, "primary_staff_id"),
-			PreferredStaffID:   get(rec, "preferred_staff_id"),
-			PreferredStaffName: get(rec, "preferred_staff_name"),
-
-			AppointmentID:      get(rec, "appointment_id"),
-			AppointmentDate:    parseDate(get(rec, "appointment_date")),
-			AppointmentCreated: parseTS(get(rec, "appointment_created")),
-			AppointmentRating:  parseInt64(get(rec, "appointment_rating")),
-
-			ClientBirthday:   parseDate(get(rec, "client_birthday")),
-			ClientGender:     get(rec, "client_gender"),
-			ClientEmail:      get(rec, "client_email"),
-			ClientFirstVisit: parseDate(get(rec, "client_first_visit")),
-
-			ApptClientID:         get(rec, "appt_client_id"),
-			ApptClientFirstName:  get(rec, "appt_client_first_name"),
-			ApptClientLastName:   get(rec, "appt_client_last_name"),
-			ApptClientBirthday:   parseDate(get(rec, "appt_client_birthday")),
-			ApptClientGender:     get(rec, "appt_client_gender"),
-			ApptClientEmail:      get(rec, "appt_client_email"),
-			ApptClientFirstVisit: parseDate(get(rec, "appt_client_first_visit")),
-
-			InternetCategoryIDs:   get(rec, "internet_category_ids"),
-			InternetCategoryNames: get(rec, "internet_category_names"),
-			BranchProductID:       get(rec, "branch_product_id"),
-			FixedDiscountID:       get(rec, "fixed_discount_id"),
-			FixedDiscountName:     get(rec, "fixed_discount_name"),
-			ClientCourseID:        get(rec, "client_course_id"),
-			CreatingUser:          get(rec, "creating_user"),
-			TaxRateName:           get(rec, "tax_rate_name"),
-			SaleFeeID:             get(rec, "sale_fee_id"),
-
-			UpdatedAtPhorest: parseTS(get(rec, "purchase_updated_at")),
-		}
-		items = append(items, item)
-
-		// Build/refresh header (Transaction) by newest updated_at_phorest per transaction_id
-		headerUpdated := item.UpdatedAtPhorest
-		txNew := models.Transaction{
-			TransactionID:    transactionID,
-			BranchID:         item.BranchID,
-			BranchName:       item.BranchName,
-			ClientID:         item.ClientID,
-			ClientFirstName:  item.ClientFirstName,
-			ClientLastName:   item.ClientLastName,
-			ClientSource:     item.ClientSource,
-			PurchasedDate:    item.PurchasedDate,
-			PurchaseTime:     item.PurchaseTime,
-			UpdatedAtPhorest: headerUpdated,
-		}
-		if existing, ok := txByID[transactionID]; ok {
-			// keep the newest watermark
-			switch {
-			case existing.UpdatedAtPhorest == nil && headerUpdated != nil:
-				txByID[transactionID] = txNew
-			case existing.UpdatedAtPhorest != nil && headerUpdated != nil &&
-				headerUpdated.After(*existing.UpdatedAtPhorest):
-				txByID[transactionID] = txNew
-			default:
-				// keep existing
-			}
-		} else {
-			txByID[transactionID] = txNew
-		}
+// keepNewestTransaction builds/refreshes the header (Transaction) for item,
+// keeping the newest by updated_at_phorest per transaction_id.
+func keepNewestTransaction(txByID map[string]models.Transaction, item *models.TransactionItem) {
+	headerUpdated := item.UpdatedAtPhorest
+	txNew := models.Transaction{
+		TransactionID:    item.TransactionID,
+		BranchID:         item.BranchID,
+		BranchName:       item.BranchName,
+		ClientID:         item.ClientID,
+		ClientFirstName:  item.ClientFirstName,
+		ClientLastName:   item.ClientLastName,
+		ClientSource:     item.ClientSource,
+		PurchasedDate:    item.PurchasedDate,
+		PurchaseTime:     item.PurchaseTime,
+		UpdatedAtPhorest: headerUpdated,
+	}
+	existing, ok := txByID[item.TransactionID]
+	if !ok {
+		txByID[item.TransactionID] = txNew
+		return
+	}
+	// keep the newest watermark
+	switch {
+	case existing.UpdatedAtPhorest == nil && headerUpdated != nil:
+		txByID[item.TransactionID] = txNew
+	case existing.UpdatedAtPhorest != nil && headerUpdated != nil &&
+		headerUpdated.After(*existing.UpdatedAtPhorest):
+		txByID[item.TransactionID] = txNew
+	default:
+		// keep existing
+	}
+}
+
+// countingReader counts the bytes read through it, for progress.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func csvFloat(s string) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func csvInt64(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
+
+func csvInt(s string) int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	v, _ := strconv.Atoi(s)
+	return v
+}
+
+func csvBool(s string) bool {
+	// CSV often uses 0/1 or true/false
+	s = strings.TrimSpace(strings.ToLower(s))
+	return s == "1" || s == "true" || s == "t" || s == "yes" || s == "y"
+}
+
+func csvDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if t, ok := parseLayouts(s, csvDateLayouts); ok {
+		return &t
 	}
+	return nil
+}
 
-	transactions := make([]models.Transaction, 0, len(txByID))
-	for _, v := range txByID {
-		transactions = append(transactions, v)
+func csvClock(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if t, ok := parseLayouts(s, csvClockLayouts); ok {
+		return &t
 	}
+	return nil
+}
 
-	lg.Printf("Parsed CSV: %d transactions, %d items", len(transactions), len(items))
-	return &ParsedBatch{Transactions: transactions, Items: items}, nil
+func csvTS(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	// observed formats (no TZ, or ISO with T)
+	if t, ok := parseLayouts(s, csvTSLayouts); ok {
+		return &t
+	}
+	return nil
 }
 
 func cleanUTF8(s string) string {
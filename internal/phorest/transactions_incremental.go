@@ -2,6 +2,7 @@ package phorest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"time"
@@ -9,113 +10,200 @@ import (
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
-func (r *Runner) RunIncrementalTransactionsSync(ctx context.Context) error {
+// transactionsTSFmt is the timestamp format of Phorest's filterExpression,
+// e.g. updated=<2018-01-31T23:59:59.999Z&updated=>2018-01-01T00:00:00.000Z
+const transactionsTSFmt = "2006-01-02T15:04:05.000Z"
+
+// txWindow is the updated_at window one branch's transactions sync covers.
+type txWindow struct {
+	from, to    time.Time
+	overlap     time.Duration
+	chunkDays   int
+	historyDays int
+}
+
+// transactionsWindow works out the window for a branch whose watermark is
+// last (nil = none), honouring --from/--to. Without a watermark or --from it
+// starts historyDays before now. Without --to the window runs to
+// the next full hour, so a retry within the hour asks for the same export
+// and resumes its job.
+func transactionsWindow(last, fromOpt, toOpt *time.Time, now time.Time) txWindow {
+	w := txWindow{
+		overlap:     getDurationEnv("TRANSACTIONS_OVERLAP", transactionsOverlap),
+		chunkDays:   getIntEnv("TRANSACTIONS_CHUNK_DAYS", transactionsChunkDays),
+		historyDays: getIntEnv("TRANSACTIONS_HISTORY_DAYS", transactionsHistoryDays),
+	}
+
+	switch {
+	case fromOpt != nil:
+		w.from = dateOnly(fromOpt.UTC())
+	case last != nil:
+		w.from = last.UTC().Add(-w.overlap)
+	default:
+		w.from = dateOnly(now.UTC().AddDate(0, 0, -w.historyDays))
+	}
+
+	if toOpt != nil {
+		w.to = dateOnly(toOpt.UTC()).Add(24*time.Hour - time.Millisecond)
+	} else {
+		w.to = now.UTC().Truncate(time.Hour).Add(time.Hour)
+	}
+	return w
+}
+
+func (r *Runner) RunIncrementalTransactionsSync(ctx context.Context) (err error) {
 	lg := r.Logger
-	db := r.DB
 
 	lg.Printf("▶️ Starting incremental TRANSACTIONS_CSV sync...")
 
-	wr := repos.NewWatermarksRepo(db, lg)
+	run := r.startRun(repos.WatermarkTransactionsCSV)
+	defer run.finish(&err)
+
+	wr := run.watermarks()
+
+	// An explicit window may leave a gap behind the watermark, so only a
+	// plain incremental run moves it.
+	backfill := r.Opts.IgnoreWatermark || r.Opts.FromDate != nil || r.Opts.ToDate != nil
+	if backfill {
+		lg.Printf("🟥 --from/--to/ignore-watermark → BACKFILL MODE (watermark not updated)")
+	}
 
 	// We'll iterate each branch separately
-	for _, b := range r.Cfg.Branches {
+	for _, b := range r.branches() {
 		lg.Printf("🏢 Branch %s (%s): starting TRANSACTIONS_CSV sync", b.Name, b.BranchID)
-
-		// 1) Get per-branch watermark
-		last, err := wr.GetLastUpdated("transactions_csv", b.BranchID)
+		rb, ok, err := run.lockBranch(ctx, b.BranchID)
 		if err != nil {
-			return fmt.Errorf("get transactions_csv watermark for %s: %w", b.BranchID, err)
+			return err
 		}
-
-		// 2) Build date window + filterExpression
-		var (
-			startDate  string
-			finishDate string
-			filterExpr string
-		)
-
-		// Date format used by Phorest for startFilter/finishFilter
-		const dateFmt = "2006-01-02"
-
-		if last == nil {
-			// No watermark yet for this branch:
-			// use some sensible "start of history" date
-			startDate = "2000-01-01"
-		} else {
-			// Use the *date* part of the last updated value
-			startDate = last.UTC().Format(dateFmt)
+		if !ok {
+			continue
 		}
 
-		// Up to today
-		now := time.Now().UTC()
-		finishDate = now.Format(dateFmt)
-
-		// Build filterExpression per Phorest docs:
-		// updated=<2018-01-31T23:59:59.999Z&updated=>2018-01-01T00:0:00.000Z
-		fromTS := startDate + "T00:00:00.000Z"
-		toTS := finishDate + "T23:59:59.999Z"
-		filterExpr = fmt.Sprintf("updated=<%s&updated=>%s", toTS, fromTS)
-
-		lg.Printf("ℹ️ %s: using startFilter=%q finishFilter=%q filterExpression=%q",
-			b.BranchID, startDate, finishDate, filterExpr)
-
-		// 3) Create CSV export job
-		job, err := r.Export.CreateCSVExport(
-			ctx,
-			b.BranchID,
-			JobTypeTransactionsCSV, // "TRANSACTIONS_CSV"
-			filterExpr,
-			startDate,
-			finishDate,
-		)
-		if err != nil {
-			return fmt.Errorf("create TRANSACTIONS_CSV export for %s: %w", b.BranchID, err)
-		}
-		lg.Printf("📝 %s: created TRANSACTIONS_CSV job %s (%s)", b.BranchID, job.JobID, job.JobStatus)
-
-		// 4) Poll job
-		waitMax := 5 * time.Minute
-		final, err := r.Export.WaitForCSVJob(
-			r.Cfg.PhorestBusiness,
-			b.BranchID,
-			job.JobID,
-			waitMax,
-		)
-		if err != nil {
-			// Special-case "No records found" so we don't treat it as a hard failure
-			if final != nil && final.FailureReason != nil && *final.FailureReason == "No records found" {
-				lg.Printf("ℹ️ %s: no new transactions in window %s..%s", b.BranchID, startDate, finishDate)
-				continue
+		// 1) Get per-branch watermark
+		var last *time.Time
+		if !r.Opts.IgnoreWatermark {
+			last, err = wr.GetLastUpdated(repos.WatermarkTransactionsCSV, b.BranchID)
+			if err != nil {
+				return fmt.Errorf("get transactions_csv watermark for %s: %w", b.BranchID, err)
 			}
-			return fmt.Errorf("wait for TRANSACTIONS_CSV job %s (%s): %w", job.JobID, b.BranchID, err)
 		}
 
-		if final.TempCSVExternalURL == nil || *final.TempCSVExternalURL == "" {
-			lg.Printf("⚠️ %s: job %s DONE but no csv URL; skipping import", b.BranchID, job.JobID)
-			continue
+		// 2) Work out the window and split it into exports of chunkDays
+		w := transactionsWindow(last, r.Opts.FromDate, r.Opts.ToDate, time.Now())
+		if !w.to.After(w.from) {
+			return fmt.Errorf("transactions_csv/%s: invalid window %s → %s",
+				b.BranchID, w.from.Format(time.RFC3339), w.to.Format(time.RFC3339))
 		}
-		lg.Printf("📥 %s: job %s DONE, URL received", b.BranchID, job.JobID)
-
-		// 5) Download CSV to export dir
-		filename := fmt.Sprintf("transactions_incremental_%s_%s.csv", b.BranchID, now.Format("20060102_150405"))
-		dest := filepath.Join(r.Cfg.ExportDir, filename)
+		rb.window(w.from, w.to)
 
-		if err := r.Export.DownloadCSV(*final.TempCSVExternalURL, dest); err != nil {
-			return fmt.Errorf("%s: download csv: %w", b.BranchID, err)
+		chunks := splitWindow(w.from, w.to, 24*time.Hour*time.Duration(w.chunkDays))
+		if last != nil {
+			lg.Printf("ℹ️ %s: watermark=%s overlap=%s → updated %s → %s in %d chunk(s)",
+				b.BranchID, last.UTC().Format(time.RFC3339), w.overlap,
+				w.from.Format(time.RFC3339), w.to.Format(time.RFC3339), len(chunks))
+		} else {
+			lg.Printf("ℹ️ %s: no watermark used → updated %s → %s in %d chunk(s)",
+				b.BranchID, w.from.Format(time.RFC3339), w.to.Format(time.RFC3339), len(chunks))
+			if r.Opts.FromDate == nil {
+				lg.Printf("⚠️ %s: first run only goes back %d days (TRANSACTIONS_HISTORY_DAYS); for older history import the CSV archive and run `datahub bootstrap watermarks`, or pass --from",
+					b.BranchID, w.historyDays)
+			}
 		}
-		lg.Printf("💾 %s: saved TRANSACTIONS_CSV to %s", b.BranchID, dest)
 
-		// 6) Re-use your existing CSV import logic
-		if err := r.importSingleTransactionsCSV(dest); err != nil {
-			return fmt.Errorf("import incremental transactions csv %s: %w", dest, err)
-		}
+		// 3) Export and import each chunk in order, advancing the watermark
+		// after each one so a failure part-way keeps the chunks already in.
+		for i, ch := range chunks {
+			lg.Printf("📦 %s: chunk %d/%d", b.BranchID, i+1, len(chunks))
 
-		// Archive this CSV into the bootstrap transactions dir
-		r.archiveCSVToSeed(dest, "data/transactions")
+			imp, err := r.syncTransactionsChunk(ctx, run, b.BranchID, ch[0], ch[1])
+			if err != nil {
+				return err
+			}
+			if imp == nil {
+				continue
+			}
+			rb.fetched(imp.Items)
+			rb.upserted(imp.Transactions + imp.Items)
+
+			switch {
+			case backfill:
+			case imp.MaxUpdated == nil:
+				lg.Printf("⚠️ %s: imported rows but no updated_at_phorest seen; watermark unchanged", b.BranchID)
+			default:
+				if err := wr.UpsertLastUpdated(repos.WatermarkTransactionsCSV, b.BranchID, *imp.MaxUpdated); err != nil {
+					return fmt.Errorf("update transactions_csv watermark for %s: %w", b.BranchID, err)
+				}
+			}
+		}
 
+		rb.finish(nil)
 		lg.Printf("✅ TRANSACTIONS_CSV incremental sync finished for %s", b.BranchID)
 	}
 
 	lg.Printf("✅ All branches incremental TRANSACTIONS_CSV sync finished")
 	return nil
 }
+
+// syncTransactionsChunk exports the transactions of branchID updated within
+// [from, to] and imports them. It returns nil when the export was empty or
+// its file had already been imported.
+func (r *Runner) syncTransactionsChunk(ctx context.Context, run *syncRun, branchID string, from, to time.Time) (*transactionsImport, error) {
+	lg := r.Logger
+
+	// Date format used by Phorest for startFilter/finishFilter
+	const dateFmt = "2006-01-02"
+
+	startDate := from.UTC().Format(dateFmt)
+	finishDate := to.UTC().Format(dateFmt)
+
+	// Build filterExpression per Phorest docs
+	filterExpr := fmt.Sprintf("updated=<%s&updated=>%s",
+		to.UTC().Format(transactionsTSFmt), from.UTC().Format(transactionsTSFmt))
+
+	lg.Printf("ℹ️ %s: using startFilter=%q finishFilter=%q filterExpression=%q",
+		branchID, startDate, finishDate, filterExpr)
+
+	// Create (or resume) the export job, wait for it and download
+	filename := fmt.Sprintf("transactions_incremental_%s_%s_%s.csv",
+		branchID, from.UTC().Format("20060102T150405"), to.UTC().Format("20060102T150405"))
+	dest := filepath.Join(r.Cfg.ExportDir, filename)
+
+	job, err := r.fetchCSVExport(ctx, csvExportSpec{
+		Entity:       repos.WatermarkTransactionsCSV,
+		BranchID:     branchID,
+		JobType:      JobTypeTransactionsCSV, // "TRANSACTIONS_CSV"
+		FilterExpr:   filterExpr,
+		StartFilter:  startDate,
+		FinishFilter: finishDate,
+	}, dest)
+	switch {
+	case errors.Is(err, errCSVNoRecords):
+		// Special-case "No records found" so we don't treat it as a hard failure
+		lg.Printf("ℹ️ %s: no new transactions in window %s..%s", branchID, startDate, finishDate)
+		return nil, nil
+	case errors.Is(err, errCSVNoURL):
+		lg.Printf("⚠️ %s: %v; skipping import", branchID, err)
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("TRANSACTIONS_CSV export for %s: %w", branchID, err)
+	}
+	lg.Printf("💾 %s: saved TRANSACTIONS_CSV to %s", branchID, dest)
+
+	// Re-use the existing CSV import logic
+	imp, err := r.importSingleTransactionsCSV(ctx, dest, run.id())
+	switch {
+	case errors.Is(err, errAlreadyImported):
+		lg.Printf("⏭  %s: %v", branchID, err)
+		r.markCSVImported(job)
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("import incremental transactions csv %s: %w", dest, err)
+	}
+
+	r.markCSVImported(job)
+
+	// Archive this CSV into the bootstrap transactions dir
+	r.archiveCSVToSeed(ctx, dest, archiveTransactions)
+
+	return imp, nil
+}
@@ -0,0 +1,153 @@
+package phorest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+const (
+	// appointmentsOverlap is subtracted from the appointments watermark so
+	// rows updated in the same instant as the last one aren't missed.
+	appointmentsOverlap = 2 * time.Minute
+
+	// productsWatermarkStep is added to the products watermark: Phorest's
+	// updatedAfter is inclusive, so this skips the row we already have.
+	productsWatermarkStep = time.Second
+
+	// transactionsHistoryDays is how far back a branch with no
+	// transactions_csv watermark starts its export (TRANSACTIONS_HISTORY_DAYS
+	// overrides it). Older history comes from `datahub bootstrap`, not from
+	// hundreds of exports.
+	transactionsHistoryDays = 90
+
+	// transactionsOverlap is subtracted from the transactions watermark so
+	// late-committed rows near it are exported again (TRANSACTIONS_OVERLAP
+	// overrides it).
+	transactionsOverlap = 10 * time.Minute
+
+	// transactionsChunkDays caps the span of one TRANSACTIONS_CSV export; a
+	// longer window is split (TRANSACTIONS_CHUNK_DAYS overrides it).
+	transactionsChunkDays = 31
+)
+
+// NextWindow describes what the next incremental run would fetch. From/To
+// nil = unbounded on that side.
+type NextWindow struct {
+	From *time.Time
+	To   *time.Time
+	Note string
+}
+
+func (w NextWindow) String() string {
+	if w.From == nil && w.To == nil {
+		return w.Note
+	}
+	bound := func(t *time.Time, open string) string {
+		if t == nil {
+			return open
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s → %s (%s)", bound(w.From, "beginning"), bound(w.To, "now"), w.Note)
+}
+
+// NextWindowFor works out the window the next incremental run of entity would
+// use if its watermark were last (nil = no row), mirroring each sync's own
+// logic without --from/--to overrides. `datahub watermarks --dry-run` prints
+// it before and after a change.
+func NextWindowFor(entity repos.WatermarkEntity, last *time.Time, now time.Time) NextWindow {
+	now = now.UTC()
+
+	switch entity {
+	case repos.WatermarkTransactionsCSV:
+		w := transactionsWindow(last, nil, nil, now)
+		chunks := len(splitWindow(w.from, w.to, 24*time.Hour*time.Duration(w.chunkDays)))
+		note := fmt.Sprintf("no watermark: last %d days only", w.historyDays)
+		if last != nil {
+			note = fmt.Sprintf("updated, %s overlap", w.overlap)
+		}
+		note += fmt.Sprintf("; %d export(s) of up to %d days", chunks, w.chunkDays)
+		return NextWindow{From: &w.from, To: &w.to, Note: note}
+
+	case repos.WatermarkClientsCSV:
+		if last == nil {
+			return NextWindow{Note: "no watermark: full CLIENT_CSV export"}
+		}
+		from := last.UTC()
+		return NextWindow{From: &from, Note: "updated"}
+
+	case repos.WatermarkClientsAPI:
+		if last == nil {
+			return NextWindow{Note: "no watermark: full client sweep"}
+		}
+		from := last.UTC()
+		return NextWindow{From: &from, Note: "updated"}
+
+	case repos.WatermarkAppointmentsAPI:
+		start := dateOnly(now.AddDate(0, 0, -getIntEnv("APPOINTMENTS_HISTORY_DAYS", 365)))
+		end := dateOnly(now.AddDate(0, 0, getIntEnv("APPOINTMENTS_FUTURE_DAYS", 120)))
+		dates := fmt.Sprintf("appointment_date %s → %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		if last == nil {
+			return NextWindow{Note: "no watermark: every appointment in " + dates}
+		}
+		from := last.UTC().Add(-appointmentsOverlap)
+		return NextWindow{From: &from, Note: fmt.Sprintf("updated, %s overlap; %s", appointmentsOverlap, dates)}
+
+	case repos.WatermarkProductsAPI:
+		if last == nil {
+			return NextWindow{Note: "no watermark: full product sync"}
+		}
+		from := last.UTC().Add(productsWatermarkStep)
+		return NextWindow{From: &from, To: &now, Note: "updated"}
+
+	case repos.WatermarkWorktimetableRolling:
+		start, end := worktimetableRollingWindow(now)
+		return NextWindow{From: &start, To: &end, Note: "rolling window; the watermark only records the last run"}
+
+	case repos.WatermarkWorktimetableBackfillDone:
+		if last != nil {
+			return NextWindow{Note: "backfill already done; rolling window only"}
+		}
+		if !getBoolEnv(EnvWorktimetableBackfillEnabled, false) {
+			return NextWindow{Note: fmt.Sprintf("backfill pending but disabled (%s=false)", EnvWorktimetableBackfillEnabled)}
+		}
+		from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		if s := strings.TrimSpace(os.Getenv(EnvWorktimetableBackfillFrom)); s != "" {
+			if t, err := time.Parse("2006-01-02", s); err == nil {
+				from = t.UTC()
+			}
+		}
+		rollingStart, _ := worktimetableRollingWindow(now)
+		to := dayBefore(rollingStart)
+		return NextWindow{From: &from, To: &to, Note: "one-off backfill on the next run"}
+
+	case repos.WatermarkReviewsAPI:
+		return NextWindow{Note: "pages newest first until it meets reviews already stored; the watermark is the newest review_date seen"}
+
+	case repos.WatermarkBreaksAPI:
+		start := dateOnly(now.AddDate(0, 0, -getIntEnv("BREAKS_BACK_DAYS", 60)))
+		end := dateOnly(now.AddDate(0, 0, getIntEnv("BREAKS_FORWARD_DAYS", 180)))
+		return NextWindow{From: &start, To: &end, Note: "rolling window; the watermark only records the last run"}
+
+	case repos.WatermarkStaffAPI, repos.WatermarkBranchesAPI:
+		return NextWindow{Note: "not driven by the watermark: full fetch every run"}
+	}
+
+	return NextWindow{Note: fmt.Sprintf("unknown entity %q", entity)}
+}
+
+// worktimetableRollingWindow is the worktimetable sync's default rolling
+// window. Prefers WORKTIMETABLE_PAST_DAYS, but supports the legacy
+// WORKTIMETABLE_HISTORY_DAYS too.
+func worktimetableRollingWindow(now time.Time) (time.Time, time.Time) {
+	historyDays := getIntEnv(EnvWorktimetablePastDays, 0)
+	if historyDays <= 0 {
+		historyDays = getIntEnv(EnvWorktimetableHistoryDays, 365)
+	}
+	futureDays := getIntEnv(EnvWorktimetableFutureDays, 120)
+	return dateOnly(now.AddDate(0, 0, -historyDays)), dateOnly(now.AddDate(0, 0, futureDays))
+}
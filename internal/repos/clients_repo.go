@@ -51,7 +51,7 @@ func (r *ClientsRepo) UpsertBatch(rows []models.Client, batchSize int) error {
 			return nil
 		}
 		sql := fmt.Sprintf(`
-INSERT INTO clients (
+INSERT INTO archive.clients (
   client_id, version, first_name, last_name,
   mobile, linked_client_mobile, land_line, email,
   created_at_phorest, updated_at_phorest,          -- ✅ fixed here
@@ -0,0 +1,108 @@
+package repos
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+	"gorm.io/gorm"
+)
+
+// resumableStatuses are the states in which a job may still yield a CSV.
+var resumableStatuses = []string{
+	models.CSVExportQueued,
+	models.CSVExportInProgress,
+	models.CSVExportDone,
+}
+
+// CSVExportJobsRepo reads/writes core.csv_export_jobs.
+type CSVExportJobsRepo struct {
+	db *gorm.DB
+	lg *log.Logger
+}
+
+func NewCSVExportJobsRepo(db *gorm.DB, lg *log.Logger) *CSVExportJobsRepo {
+	return &CSVExportJobsRepo{db: db, lg: lg}
+}
+
+// Record inserts a freshly created job.
+func (r *CSVExportJobsRepo) Record(job *models.CSVExportJob) error {
+	now := time.Now().UTC()
+	job.CreatedAt, job.UpdatedAt = now, now
+	return r.db.Create(job).Error
+}
+
+// FindResumable returns the newest job for exactly this (entity, branch,
+// window) that is still QUEUED/IN_PROGRESS/DONE and younger than maxAge, or
+// nil. Older unfinished jobs for the same entity/branch are marked EXPIRED on
+// the way, so they stop looking pending.
+func (r *CSVExportJobsRepo) FindResumable(entity, branchID, startFilter, finishFilter, filterExpr string, maxAge time.Duration) (*models.CSVExportJob, error) {
+	cutoff := time.Now().UTC().Add(-maxAge)
+
+	if err := r.db.Model(&models.CSVExportJob{}).
+		Where("entity = ? AND branch_id = ? AND status IN ? AND created_at < ?",
+			entity, branchID, resumableStatuses, cutoff).
+		Updates(map[string]any{
+			"status":     models.CSVExportExpired,
+			"updated_at": time.Now().UTC(),
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	var job models.CSVExportJob
+	err := r.db.
+		Where("entity = ? AND branch_id = ? AND start_filter = ? AND finish_filter = ? AND filter_expression = ?",
+			entity, branchID, startFilter, finishFilter, filterExpr).
+		Where("status IN ? AND created_at >= ?", resumableStatuses, cutoff).
+		Order("created_at DESC").
+		First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateStatus stores what the latest poll reported.
+func (r *CSVExportJobsRepo) UpdateStatus(job *models.CSVExportJob, status string, failureReason *string, totalRows *int32) error {
+	now := time.Now().UTC()
+	job.Status, job.FailureReason, job.UpdatedAt = status, failureReason, now
+
+	updates := map[string]any{
+		"status":         status,
+		"failure_reason": failureReason,
+		"updated_at":     now,
+	}
+	if totalRows != nil {
+		job.TotalRows = totalRows
+		updates["total_rows"] = *totalRows
+	}
+	if (status == models.CSVExportDone || status == models.CSVExportFailed) && job.FinishedAt == nil {
+		job.FinishedAt = &now
+		updates["finished_at"] = now
+	}
+
+	return r.db.Model(&models.CSVExportJob{}).Where("id = ?", job.ID).Updates(updates).Error
+}
+
+// MarkImported closes a job whose CSV has been imported.
+func (r *CSVExportJobsRepo) MarkImported(job *models.CSVExportJob) error {
+	now := time.Now().UTC()
+	job.Status, job.ImportedAt, job.UpdatedAt = models.CSVExportImported, &now, now
+
+	return r.db.Model(&models.CSVExportJob{}).
+		Where("id = ?", job.ID).
+		Updates(map[string]any{
+			"status":      models.CSVExportImported,
+			"imported_at": now,
+			"updated_at":  now,
+		}).Error
+}
+
+// MarkExpired closes a job Phorest no longer serves (unknown job, dead URL).
+func (r *CSVExportJobsRepo) MarkExpired(job *models.CSVExportJob, reason string) error {
+	return r.UpdateStatus(job, models.CSVExportExpired, &reason, nil)
+}
@@ -0,0 +1,118 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// ImportLedgerRepo reads/writes core.import_ledger.
+type ImportLedgerRepo struct {
+	db *gorm.DB
+	lg *log.Logger
+}
+
+func NewImportLedgerRepo(db *gorm.DB, lg *log.Logger) *ImportLedgerRepo {
+	return &ImportLedgerRepo{db: db, lg: lg}
+}
+
+// FindBySHA256 returns the entry that imported these contents for entity,
+// or nil.
+func (r *ImportLedgerRepo) FindBySHA256(entity, sum string) (*models.ImportLedgerEntry, error) {
+	return r.first(r.db.Where("entity = ? AND sha256 = ?", entity, sum))
+}
+
+// LatestByName returns the newest entry for a file of this name, or nil.
+func (r *ImportLedgerRepo) LatestByName(entity, fileName string) (*models.ImportLedgerEntry, error) {
+	return r.first(r.db.
+		Where("entity = ? AND file_name = ?", entity, fileName).
+		Order("imported_at DESC, id DESC"))
+}
+
+func (r *ImportLedgerRepo) first(q *gorm.DB) (*models.ImportLedgerEntry, error) {
+	var e models.ImportLedgerEntry
+	err := q.First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Record inserts e, stamping ImportedAt.
+func (r *ImportLedgerRepo) Record(e *models.ImportLedgerEntry) error {
+	e.ImportedAt = time.Now().UTC()
+	return r.db.Create(e).Error
+}
+
+// ImportRecord is what an import writes besides the data: its ledger entry
+// and the cells it rejected.
+type ImportRecord struct {
+	Entry   *models.ImportLedgerEntry
+	Rejects []models.ImportReject
+}
+
+var rejectCols = []string{"source", "file", "line", "column_name", "raw_value", "error", "created_at"}
+
+// recordImportTx writes rec in tx, for imports that run on a pgx
+// transaction rather than through gorm (see CopyImport).
+func recordImportTx(ctx context.Context, tx pgx.Tx, rec *ImportRecord) error {
+	now := time.Now().UTC()
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"raw", "import_rejects"}, rejectCols,
+		pgx.CopyFromSlice(len(rec.Rejects), func(i int) ([]any, error) {
+			j := &rec.Rejects[i]
+			j.CreatedAt = now
+			return []any{j.Source, j.File, j.Line, j.ColumnName, j.RawValue, j.Error, j.CreatedAt}, nil
+		})); err != nil {
+		return fmt.Errorf("record import rejects: %w", err)
+	}
+
+	e := rec.Entry
+	e.ImportedAt = now
+	if err := tx.QueryRow(ctx, `
+INSERT INTO core.import_ledger
+  (entity, file_name, path, sha256, size_bytes, row_count, rejected_count, changed, previous_sha256, run_id, imported_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id`,
+		e.Entity, e.FileName, e.Path, e.SHA256, e.SizeBytes, e.RowCount, e.RejectedCount,
+		e.Changed, e.PreviousSHA256, e.RunID, e.ImportedAt,
+	).Scan(&e.ID); err != nil {
+		return fmt.Errorf("record %s in import ledger: %w", e.FileName, err)
+	}
+	return nil
+}
+
+// ImportLedgerFilter narrows List; zero values match everything.
+type ImportLedgerFilter struct {
+	Entity      string
+	ChangedOnly bool
+	Limit       int
+}
+
+// List returns entries newest first.
+func (r *ImportLedgerRepo) List(f ImportLedgerFilter) ([]models.ImportLedgerEntry, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+
+	q := r.db.Model(&models.ImportLedgerEntry{}).Order("imported_at DESC, id DESC").Limit(f.Limit)
+	if f.Entity != "" {
+		q = q.Where("entity = ?", f.Entity)
+	}
+	if f.ChangedOnly {
+		q = q.Where("changed")
+	}
+
+	var rows []models.ImportLedgerEntry
+	err := q.Find(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,35 @@
+package repos
+
+import (
+	"log"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+	"gorm.io/gorm"
+)
+
+// ImportRejectsRepo writes raw.import_rejects.
+type ImportRejectsRepo struct {
+	db *gorm.DB
+	lg *log.Logger
+}
+
+func NewImportRejectsRepo(db *gorm.DB, lg *log.Logger) *ImportRejectsRepo {
+	return &ImportRejectsRepo{db: db, lg: lg}
+}
+
+// Insert records rejected cells.
+func (r *ImportRejectsRepo) Insert(rows []models.ImportReject) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for i := range rows {
+		rows[i].CreatedAt = now
+	}
+	if err := r.db.CreateInBatches(rows, 1000).Error; err != nil {
+		return err
+	}
+	r.lg.Printf("Recorded import rejects: %d", len(rows))
+	return nil
+}
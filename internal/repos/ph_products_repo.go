@@ -29,6 +29,7 @@ func (r *PhProductRepo) Upsert(ctx context.Context, p *models.PhProduct) error {
 				"category_id",
 				"category_name",
 				"code",
+				"barcode",
 				"type_raw",
 				"measurement_qty",
 				"measurement_unit",
@@ -0,0 +1,231 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Stock adjustment outbox statuses (core.stock_adjustment_outbox.status).
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending" // being posted; stuck here means we died mid-post or got no answer
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// StockAdjustmentOutboxRow is one planned stock line: Quantity of Barcode
+// to DEDUCT from / INCREASE at BranchID, covering SourceItemIDs.
+type StockAdjustmentOutboxRow struct {
+	ID            int64
+	HubBranchID   string
+	BranchID      string
+	Barcode       string
+	Quantity      int
+	Operation     string // "DEDUCT" or "INCREASE"
+	SourceItemIDs []string
+	Status        string
+	Attempts      int
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	SentAt        sql.NullTime
+}
+
+type StockAdjustmentOutboxRepo struct {
+	DB *sql.DB
+}
+
+const outboxCols = `id, hub_branch_id, branch_id, barcode, quantity, operation, source_item_ids,
+  status, attempts, last_error, created_at, updated_at, sent_at`
+
+// StockAdjustmentOutboxFilter narrows List; zero values mean "any".
+type StockAdjustmentOutboxFilter struct {
+	HubBranchID string
+	Status      string
+	IDs         []int64
+	Limit       int // 0 = no limit
+}
+
+// List returns matching rows, newest first.
+func (r *StockAdjustmentOutboxRepo) List(ctx context.Context, f StockAdjustmentOutboxFilter) ([]StockAdjustmentOutboxRow, error) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.HubBranchID != "" {
+		where = append(where, "hub_branch_id = "+arg(f.HubBranchID))
+	}
+	if f.Status != "" {
+		where = append(where, "status = "+arg(f.Status))
+	}
+	if len(f.IDs) > 0 {
+		where = append(where, "id = ANY("+arg(f.IDs)+")")
+	}
+
+	q := "SELECT " + outboxCols + " FROM core.stock_adjustment_outbox"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		q += " LIMIT " + arg(f.Limit)
+	}
+	return r.query(ctx, q, args...)
+}
+
+// Pending returns hubBranchID's pending rows, oldest first.
+func (r *StockAdjustmentOutboxRepo) Pending(ctx context.Context, hubBranchID string) ([]StockAdjustmentOutboxRow, error) {
+	return r.query(ctx, `
+SELECT `+outboxCols+`
+FROM core.stock_adjustment_outbox
+WHERE hub_branch_id = $1 AND status = 'pending'
+ORDER BY id`, hubBranchID)
+}
+
+func (r *StockAdjustmentOutboxRepo) query(ctx context.Context, q string, args ...any) ([]StockAdjustmentOutboxRow, error) {
+	rows, err := r.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := pgtype.NewMap()
+	var out []StockAdjustmentOutboxRow
+	for rows.Next() {
+		var o StockAdjustmentOutboxRow
+		if err := rows.Scan(
+			&o.ID,
+			&o.HubBranchID,
+			&o.BranchID,
+			&o.Barcode,
+			&o.Quantity,
+			&o.Operation,
+			m.SQLScanner(&o.SourceItemIDs),
+			&o.Status,
+			&o.Attempts,
+			&o.LastError,
+			&o.CreatedAt,
+			&o.UpdatedAt,
+			&o.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// MarkSending claims pending rows for posting. It reports false (and claims
+// nothing) unless every one of ids was still pending, e.g. because a retry
+// command got there first.
+func (r *StockAdjustmentOutboxRepo) MarkSending(ctx context.Context, ids []int64) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+UPDATE core.stock_adjustment_outbox
+SET status = 'sending', attempts = attempts + 1, updated_at = now()
+WHERE id = ANY($1) AND status = 'pending'`, ids)
+	if err != nil {
+		return false, fmt.Errorf("mark sending: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n != int64(len(ids)) {
+		return false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	return true, nil
+}
+
+// MarkSent records that ids reached Phorest.
+func (r *StockAdjustmentOutboxRepo) MarkSent(ctx context.Context, ids []int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+UPDATE core.stock_adjustment_outbox
+SET status = 'sent', sent_at = now(), last_error = NULL, updated_at = now()
+WHERE id = ANY($1) AND status = 'sending'`, ids)
+	if err != nil {
+		return fmt.Errorf("mark sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records that posting ids failed with msg.
+func (r *StockAdjustmentOutboxRepo) MarkFailed(ctx context.Context, ids []int64, msg string) error {
+	_, err := r.DB.ExecContext(ctx, `
+UPDATE core.stock_adjustment_outbox
+SET status = 'failed', last_error = $2, updated_at = now()
+WHERE id = ANY($1) AND status = 'sending'`, ids, msg)
+	if err != nil {
+		return fmt.Errorf("mark failed: %w", err)
+	}
+	return nil
+}
+
+// NoteSendError records msg on ids still in 'sending' without moving them:
+// the post got no clear answer, so only a human can say whether it was
+// applied.
+func (r *StockAdjustmentOutboxRepo) NoteSendError(ctx context.Context, ids []int64, msg string) error {
+	_, err := r.DB.ExecContext(ctx, `
+UPDATE core.stock_adjustment_outbox
+SET last_error = $2, updated_at = now()
+WHERE id = ANY($1) AND status = 'sending'`, ids, msg)
+	if err != nil {
+		return fmt.Errorf("note send error: %w", err)
+	}
+	return nil
+}
+
+// Requeue puts failed rows back to pending: ids if given (which may also
+// name rows stuck in 'sending'), otherwise every failed row of hubBranchID
+// ("" = all hubs). Sent rows are never touched. Returns how many rows were
+// requeued per hub.
+func (r *StockAdjustmentOutboxRepo) Requeue(ctx context.Context, ids []int64, hubBranchID string) (map[string]int, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if len(ids) > 0 {
+		rows, err = r.DB.QueryContext(ctx, `
+UPDATE core.stock_adjustment_outbox
+SET status = 'pending', updated_at = now()
+WHERE id = ANY($1) AND status IN ('failed', 'sending')
+RETURNING hub_branch_id`, ids)
+	} else {
+		rows, err = r.DB.QueryContext(ctx, `
+UPDATE core.stock_adjustment_outbox
+SET status = 'pending', updated_at = now()
+WHERE status = 'failed' AND ($1 = '' OR hub_branch_id = $1)
+RETURNING hub_branch_id`, hubBranchID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("requeue: %w", err)
+	}
+	defer rows.Close()
+
+	byHub := map[string]int{}
+	for rows.Next() {
+		var hub string
+		if err := rows.Scan(&hub); err != nil {
+			return nil, err
+		}
+		byHub[hub]++
+	}
+	return byHub, rows.Err()
+}
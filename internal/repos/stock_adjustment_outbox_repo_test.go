@@ -0,0 +1,151 @@
+package repos_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/e2e"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// Walks outbox rows through pending → sending → sent | failed → pending and
+// checks that no transition skips a step or touches a row it shouldn't.
+func TestStockAdjustmentOutboxTransitions(t *testing.T) {
+	db := e2e.NewTestDB(t)
+	ctx := context.Background()
+	repo := &repos.StockAdjustmentOutboxRepo{DB: db}
+
+	mustExec(t, db, `
+INSERT INTO core.stock_adjustment_outbox (id, hub_branch_id, branch_id, barcode, quantity, operation, source_item_ids)
+VALUES (1, 'pk', 'pk',   'B1', 2, 'DEDUCT',   ARRAY['s1', 's2']),
+       (2, 'pk', 'base', 'B1', 2, 'INCREASE', ARRAY['s1', 's2']),
+       (3, 'pk', 'pk',   'B2', 1, 'DEDUCT',   ARRAY['s3']),
+       (4, 'jk', 'jk',   'B1', 1, 'DEDUCT',   ARRAY['s4'])`)
+
+	type state struct {
+		Status    string
+		Attempts  int
+		LastError string
+		Sent      bool
+	}
+	states := func() map[int64]state {
+		t.Helper()
+		rows, err := repo.List(ctx, repos.StockAdjustmentOutboxFilter{})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		out := make(map[int64]state, len(rows))
+		for _, r := range rows {
+			out[r.ID] = state{r.Status, r.Attempts, r.LastError.String, r.SentAt.Valid}
+		}
+		return out
+	}
+	expect := func(step string, want map[int64]state) {
+		t.Helper()
+		if got := states(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s:\n got %+v\nwant %+v", step, got, want)
+		}
+	}
+	claim := func(want bool, ids ...int64) {
+		t.Helper()
+		ok, err := repo.MarkSending(ctx, ids)
+		if err != nil {
+			t.Fatalf("mark sending %v: %v", ids, err)
+		}
+		if ok != want {
+			t.Fatalf("mark sending %v = %v, want %v", ids, ok, want)
+		}
+	}
+
+	pending, err := repo.Pending(ctx, "pk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pendingIDs []int64
+	for _, r := range pending {
+		pendingIDs = append(pendingIDs, r.ID)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(pendingIDs, want) {
+		t.Fatalf("pending(pk) = %v, want %v oldest first", pendingIDs, want)
+	}
+	if want := []string{"s1", "s2"}; !reflect.DeepEqual(pending[0].SourceItemIDs, want) {
+		t.Fatalf("source item IDs = %v, want %v", pending[0].SourceItemIDs, want)
+	}
+
+	claim(true, 1, 2)
+	expect("claim 1,2", map[int64]state{
+		1: {"sending", 1, "", false},
+		2: {"sending", 1, "", false},
+		3: {"pending", 0, "", false},
+		4: {"pending", 0, "", false},
+	})
+
+	// 2 is already claimed: nothing is claimed, not even 3.
+	claim(false, 2, 3)
+	expect("claim 2,3", map[int64]state{
+		1: {"sending", 1, "", false},
+		2: {"sending", 1, "", false},
+		3: {"pending", 0, "", false},
+		4: {"pending", 0, "", false},
+	})
+
+	if err := repo.MarkSent(ctx, []int64{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkFailed(ctx, []int64{2}, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	// Only rows being sent can be marked: these are no-ops.
+	if err := repo.MarkSent(ctx, []int64{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkFailed(ctx, []int64{1, 3}, "late"); err != nil {
+		t.Fatal(err)
+	}
+	expect("sent 1, failed 2", map[int64]state{
+		1: {"sent", 1, "", true},
+		2: {"failed", 1, "boom", false},
+		3: {"pending", 0, "", false},
+		4: {"pending", 0, "", false},
+	})
+
+	// A sent row is never claimed again.
+	claim(false, 1)
+
+	// Requeue by hub: only failed rows of that hub.
+	byHub, err := repo.Requeue(ctx, nil, "pk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"pk": 1}; !reflect.DeepEqual(byHub, want) {
+		t.Fatalf("requeue pk = %v, want %v", byHub, want)
+	}
+
+	// Requeue by ID also picks up a row stuck in 'sending', never a sent one.
+	claim(true, 3)
+	byHub, err = repo.Requeue(ctx, []int64{1, 3}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"pk": 1}; !reflect.DeepEqual(byHub, want) {
+		t.Fatalf("requeue 1,3 = %v, want %v", byHub, want)
+	}
+	expect("requeued", map[int64]state{
+		1: {"sent", 1, "", true},
+		2: {"pending", 1, "boom", false},
+		3: {"pending", 1, "", false},
+		4: {"pending", 0, "", false},
+	})
+
+	claim(true, 2, 3)
+	if err := repo.MarkSent(ctx, []int64{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	expect("re-sent", map[int64]state{
+		1: {"sent", 1, "", true},
+		2: {"sent", 2, "", true},
+		3: {"sent", 2, "", true},
+		4: {"pending", 0, "", false},
+	})
+}
@@ -0,0 +1,268 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stock exception statuses (core.stock_virtual_transfer_exceptions.status).
+const (
+	ExceptionOpen     = "open"     // waiting for a fix; reconcile skips it
+	ExceptionResolved = "resolved" // fixed; reconcile processes it as normal
+	ExceptionIgnored  = "ignored"  // skipped for good
+)
+
+// StockExceptionRow is one core.stock_virtual_transfer_exceptions row.
+type StockExceptionRow struct {
+	TransactionItemID string
+	Reason            string
+	Status            string
+	CreatedAt         time.Time
+	CheckedAt         time.Time
+	ResolvedAt        sql.NullTime
+	PurchasedAt       sql.NullTime
+	ProductBarcode    string
+	ProductName       sql.NullString
+	StaffID           string
+	StaffFirstName    sql.NullString
+	StaffLastName     sql.NullString
+	Note              sql.NullString
+}
+
+// StockExceptionFilter narrows exception queries; zero values mean "any".
+type StockExceptionFilter struct {
+	HubBranchID string // branch the sale was made at
+	Status      string
+	Reason      string
+	ItemIDs     []string
+	Limit       int // 0 = no limit
+}
+
+type StockExceptionRepo struct {
+	DB *sql.DB
+}
+
+// where renders f as SQL conditions on svte (the exception) and t (its
+// transaction), appending the parameters to args.
+func (f StockExceptionFilter) where(args *[]any) []string {
+	arg := func(v any) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+	var where []string
+	if f.HubBranchID != "" {
+		where = append(where, "t.branch_id = "+arg(f.HubBranchID))
+	}
+	if f.Status != "" {
+		where = append(where, "svte.status = "+arg(f.Status))
+	}
+	if f.Reason != "" {
+		where = append(where, "svte.reason = "+arg(f.Reason))
+	}
+	if len(f.ItemIDs) > 0 {
+		where = append(where, "svte.transaction_item_id = ANY("+arg(f.ItemIDs)+")")
+	}
+	return where
+}
+
+const exceptionFrom = `
+FROM core.stock_virtual_transfer_exceptions svte
+LEFT JOIN raw.transaction_items ti ON ti.transaction_item_id = svte.transaction_item_id
+LEFT JOIN raw.transactions t ON t.transaction_id = ti.transaction_id`
+
+// List returns matching exceptions, newest first.
+func (r *StockExceptionRepo) List(ctx context.Context, f StockExceptionFilter) ([]StockExceptionRow, error) {
+	var args []any
+	q := `
+SELECT
+  svte.transaction_item_id,
+  svte.reason,
+  svte.status,
+  svte.created_at,
+  svte.checked_at,
+  svte.resolved_at,
+  svte.purchased_at,
+  svte.product_barcode,
+  svte.product_name,
+  svte.staff_id,
+  svte.staff_first_name,
+  svte.staff_last_name,
+  svte.note` + exceptionFrom
+	if where := f.where(&args); len(where) > 0 {
+		q += "\nWHERE " + strings.Join(where, " AND ")
+	}
+	q += "\nORDER BY svte.created_at DESC, svte.transaction_item_id"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		q += fmt.Sprintf("\nLIMIT $%d", len(args))
+	}
+
+	rows, err := r.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StockExceptionRow
+	for rows.Next() {
+		var e StockExceptionRow
+		if err := rows.Scan(
+			&e.TransactionItemID,
+			&e.Reason,
+			&e.Status,
+			&e.CreatedAt,
+			&e.CheckedAt,
+			&e.ResolvedAt,
+			&e.PurchasedAt,
+			&e.ProductBarcode,
+			&e.ProductName,
+			&e.StaffID,
+			&e.StaffFirstName,
+			&e.StaffLastName,
+			&e.Note,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ExceptionReevaluation counts the outcome of re-evaluating exceptions.
+type ExceptionReevaluation struct {
+	Resolved  int // cause fixed: reconcile will process them
+	StillOpen int // still missing a barcode / staff override
+}
+
+// stockRuleJoin picks, as srule, the stock source rule reconcile would
+// apply to the sale ti made at t.branch_id: the first active rule, by
+// priority then id, whose filters all match, matched like
+// services.ruleMatches (an empty filter matches anything, category and brand
+// match the ID or name, ignoring case and surrounding spaces). The sale's
+// category and brand come from the item, else its product prod, as in
+// FetchUnprocessedHubSales.
+const stockRuleJoin = `
+CROSS JOIN LATERAL (
+  SELECT
+    LOWER(TRIM(COALESCE(NULLIF(ti.product_category_id, ''), prod.category_id, '')))     AS category_id,
+    LOWER(TRIM(COALESCE(NULLIF(ti.product_category_name, ''), prod.category_name, ''))) AS category_name,
+    LOWER(TRIM(COALESCE(NULLIF(ti.product_brand_id, ''), prod.brand_id, '')))           AS brand_id,
+    LOWER(TRIM(COALESCE(NULLIF(ti.product_brand_name, ''), prod.brand_name, '')))       AS brand_name,
+    LOWER(TRIM(COALESCE(ti.staff_id, '')))                                              AS staff_id
+) sale
+LEFT JOIN LATERAL (
+  SELECT ssr.source, ssr.source_branch_id
+  FROM core.stock_source_rules ssr
+  WHERE ssr.selling_branch_id = t.branch_id
+    AND ssr.active
+    AND (TRIM(COALESCE(ssr.product_category, '')) = ''
+         OR LOWER(TRIM(ssr.product_category)) IN (NULLIF(sale.category_id, ''), NULLIF(sale.category_name, '')))
+    AND (TRIM(COALESCE(ssr.product_brand, '')) = ''
+         OR LOWER(TRIM(ssr.product_brand)) IN (NULLIF(sale.brand_id, ''), NULLIF(sale.brand_name, '')))
+    AND (TRIM(COALESCE(ssr.staff_id, '')) = ''
+         OR LOWER(TRIM(ssr.staff_id)) = NULLIF(sale.staff_id, ''))
+  ORDER BY ssr.priority, ssr.id
+  LIMIT 1
+) srule ON true`
+
+// Reevaluate checks open exceptions matching f against the current data and
+// resolves those that reconcile could now process: the item has a barcode
+// (its own or from the product catalogue, see barcodeResolutionJoin) and a
+// branch to take the stock from. That is the source of the stock source rule
+// that matches the sale (see stockRuleJoin), or, when that rule or no rule
+// says STAFF_OVERRIDE, its staff's active physical branch override.
+//
+// Unless force is set, only rows whose staff override, product, item or
+// stock source rules changed since they were last checked are looked at.
+// With f.ItemIDs, ignored rows are re-evaluated too (and reopened if still
+// unfixed). When apply is false nothing is written, the counts are what
+// would happen.
+func (r *StockExceptionRepo) Reevaluate(ctx context.Context, f StockExceptionFilter, force, apply bool) (ExceptionReevaluation, error) {
+	var res ExceptionReevaluation
+
+	statuses := []string{ExceptionOpen}
+	if len(f.ItemIDs) > 0 {
+		statuses = append(statuses, ExceptionIgnored)
+	}
+	f.Status = ""
+	args := []any{statuses, force}
+	where := append([]string{
+		"svte.status = ANY($1)",
+		`($2 OR spbo.updated_at > svte.checked_at
+         OR ti.updated_at_phorest > svte.checked_at
+         OR EXISTS (
+           SELECT 1
+           FROM core.stock_source_rules ssr
+           WHERE ssr.selling_branch_id = t.branch_id
+             AND ssr.updated_at > svte.checked_at)
+         OR EXISTS (
+           SELECT 1
+           FROM raw.ph_products p
+           WHERE (p.id = ti.product_id OR p.parent_id = ti.product_id OR p.code = ti.product_code
+                  OR p.id = (SELECT parent_id FROM raw.ph_products WHERE id = ti.product_id))
+             AND p.updated_at > svte.checked_at))`,
+	}, f.where(&args)...)
+
+	checked := `
+SELECT
+  svte.transaction_item_id,
+  (bc.barcode IS NOT NULL
+   AND CASE COALESCE(srule.source, 'STAFF_OVERRIDE')
+         WHEN 'SELLING_BRANCH' THEN true
+         WHEN 'BRANCH' THEN TRIM(COALESCE(srule.source_branch_id, '')) <> ''
+         ELSE TRIM(COALESCE(spbo.physical_branch_id, '')) <> ''
+       END) AS fixed` + exceptionFrom + `
+LEFT JOIN core.staff_physical_branch_overrides spbo
+  ON spbo.staff_id = ti.staff_id AND spbo.active = true
+LEFT JOIN raw.ph_products prod ON prod.id = ti.product_id` + barcodeResolutionJoin + stockRuleJoin + `
+WHERE ` + strings.Join(where, "\n  AND ")
+
+	q := "SELECT fixed FROM (" + checked + ") c"
+	if apply {
+		q = `
+WITH c AS (` + checked + `
+)
+UPDATE core.stock_virtual_transfer_exceptions svte
+SET checked_at  = now(),
+    status      = CASE WHEN c.fixed THEN 'resolved' ELSE 'open' END,
+    resolved_at = CASE WHEN c.fixed THEN now() END
+FROM c
+WHERE c.transaction_item_id = svte.transaction_item_id
+RETURNING c.fixed`
+	}
+
+	rows, err := r.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return res, fmt.Errorf("re-evaluate exceptions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fixed bool
+		if err := rows.Scan(&fixed); err != nil {
+			return res, err
+		}
+		if fixed {
+			res.Resolved++
+		} else {
+			res.StillOpen++
+		}
+	}
+	return res, rows.Err()
+}
+
+// Ignore marks open exceptions ignored, with an optional note, and returns
+// how many were changed.
+func (r *StockExceptionRepo) Ignore(ctx context.Context, itemIDs []string, note string) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, `
+UPDATE core.stock_virtual_transfer_exceptions
+SET status = 'ignored', note = NULLIF($2, ''), checked_at = now()
+WHERE transaction_item_id = ANY($1) AND status = 'open'`, itemIDs, note)
+	if err != nil {
+		return 0, fmt.Errorf("ignore exceptions: %w", err)
+	}
+	return res.RowsAffected()
+}
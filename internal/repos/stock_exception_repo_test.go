@@ -0,0 +1,87 @@
+package repos_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/e2e"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// An exception is only resolved by a stock source rule that matches its
+// sale, and then only if that rule doesn't need the missing staff override.
+func TestReevaluateAppliesRuleFilters(t *testing.T) {
+	db := e2e.NewTestDB(t)
+	ctx := context.Background()
+	repo := &repos.StockExceptionRepo{DB: db}
+
+	mustExec(t, db, `INSERT INTO raw.transactions (transaction_id, branch_id) VALUES ('tx-1', 'pk')`)
+	mustExec(t, db, `
+INSERT INTO raw.transaction_items
+  (transaction_item_id, transaction_id, item_type, product_category_name, product_barcode, staff_id, quantity)
+VALUES
+  ('styling-1', 'tx-1', 'PRODUCT', 'Styling', 'B1', 'staff-9', 1),
+  ('colour-1',  'tx-1', 'PRODUCT', 'Colour',  'B2', 'staff-9', 1),
+  ('nobc-1',    'tx-1', 'PRODUCT', 'Colour',  NULL, 'staff-9', 1)`)
+	mustExec(t, db, `
+INSERT INTO core.stock_virtual_transfer_exceptions (transaction_item_id, reason, product_barcode, staff_id)
+VALUES ('styling-1', 'UNMAPPED_STAFF', 'B1', 'staff-9'),
+       ('colour-1',  'UNMAPPED_STAFF', 'B2', 'staff-9'),
+       ('nobc-1',    'MISSING_BARCODE', '', 'staff-9')`)
+	mustExec(t, db, `
+INSERT INTO core.stock_source_rules (selling_branch_id, priority, product_category, staff_id, source, source_branch_id)
+VALUES ('pk', 10, ' colour ', NULL,      'BRANCH',         'base'),
+       ('pk',  5, 'Styling',  'STAFF-9', 'STAFF_OVERRIDE', NULL),
+       ('pk', 20, NULL,       NULL,      'SELLING_BRANCH', NULL),
+       ('jk',  1, NULL,       NULL,      'SELLING_BRANCH', NULL)`)
+	// Only the first matching rule counts, so disable the catch-all.
+	mustExec(t, db, `UPDATE core.stock_source_rules SET active = false WHERE selling_branch_id = 'pk' AND priority = 20`)
+
+	status := func(id string) string {
+		t.Helper()
+		var s string
+		if err := db.QueryRow(`SELECT status FROM core.stock_virtual_transfer_exceptions WHERE transaction_item_id = $1`, id).Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	reevaluate := func(force, apply bool, wantResolved, wantOpen int) {
+		t.Helper()
+		res, err := repo.Reevaluate(ctx, repos.StockExceptionFilter{HubBranchID: "pk"}, force, apply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Resolved != wantResolved || res.StillOpen != wantOpen {
+			t.Fatalf("Reevaluate(force=%v, apply=%v) = %+v, want resolved=%d open=%d", force, apply, res, wantResolved, wantOpen)
+		}
+	}
+
+	// colour-1: the BRANCH rule matches (category name, any case/spaces).
+	// styling-1: its matching rule wants the staff override, which is missing.
+	// nobc-1: no barcode, whatever the rules say.
+	reevaluate(true, false, 1, 2)
+	if got := status("colour-1"); got != repos.ExceptionOpen {
+		t.Fatalf("dry run changed colour-1 to %s", got)
+	}
+
+	reevaluate(true, true, 1, 2)
+	for id, want := range map[string]string{
+		"colour-1":  repos.ExceptionResolved,
+		"styling-1": repos.ExceptionOpen,
+		"nobc-1":    repos.ExceptionOpen,
+	} {
+		if got := status(id); got != want {
+			t.Errorf("%s: status %s, want %s", id, got, want)
+		}
+	}
+
+	// Nothing changed since: nothing is looked at.
+	reevaluate(false, true, 0, 0)
+
+	// The override arrives: styling-1 is fixed, nobc-1 still has no barcode.
+	mustExec(t, db, `INSERT INTO core.staff_physical_branch_overrides (staff_id, physical_branch_id) VALUES ('staff-9', 'base')`)
+	reevaluate(false, true, 1, 1)
+	if got := status("styling-1"); got != repos.ExceptionResolved {
+		t.Errorf("styling-1: status %s, want %s", got, repos.ExceptionResolved)
+	}
+}
@@ -7,17 +7,71 @@ import (
 	"time"
 )
 
-type PKStockRow struct {
-	TransactionItemID string
-	Barcode           string
-	ProductName       string
-	Quantity          int
-	StaffID           string
-	StaffFirstName    string
-	StaffLastName     string
-	PhysicalBranchID  sql.NullString
-	UpdatedAtPhorest  time.Time
-	PurchasedAt       sql.NullTime
+// Barcode sources: where a stock row's barcode came from.
+const (
+	BarcodeFromItem        = "ITEM"         // the transaction item's own product_barcode
+	BarcodeFromProductID   = "PRODUCT_ID"   // raw.ph_products row of the item's product_id
+	BarcodeFromParent      = "PARENT"       // that product's parent (ParentID)
+	BarcodeFromVariant     = "VARIANT"      // the product's only barcoded variant
+	BarcodeFromProductCode = "PRODUCT_CODE" // the one product with the item's product_code
+)
+
+// barcodeResolutionJoin resolves the barcode of transaction item ti as
+// bc.barcode / bc.source (NULL when nothing has one): the item's own
+// barcode, else the synced product catalogue by product_id, its parent,
+// its single barcoded variant, then by product code. Catalogue lookups that
+// match several different barcodes are ambiguous and skipped.
+const barcodeResolutionJoin = `
+LEFT JOIN LATERAL (
+  SELECT c.barcode, c.source
+  FROM (
+    SELECT NULLIF(TRIM(ti.product_barcode), '') AS barcode, 'ITEM' AS source, 1 AS pref
+    UNION ALL
+    SELECT NULLIF(TRIM(p.barcode), ''), 'PRODUCT_ID', 2
+    FROM raw.ph_products p
+    WHERE p.id = ti.product_id
+    UNION ALL
+    SELECT NULLIF(TRIM(pp.barcode), ''), 'PARENT', 3
+    FROM raw.ph_products p
+    JOIN raw.ph_products pp ON pp.id = p.parent_id
+    WHERE p.id = ti.product_id
+    UNION ALL
+    SELECT MIN(TRIM(v.barcode)), 'VARIANT', 4
+    FROM raw.ph_products v
+    WHERE v.parent_id = ti.product_id
+      AND TRIM(COALESCE(v.barcode, '')) <> ''
+    HAVING COUNT(DISTINCT TRIM(v.barcode)) = 1
+    UNION ALL
+    SELECT MIN(TRIM(p.barcode)), 'PRODUCT_CODE', 5
+    FROM raw.ph_products p
+    WHERE p.code = ti.product_code
+      AND TRIM(COALESCE(ti.product_code, '')) <> ''
+      AND TRIM(COALESCE(p.barcode, '')) <> ''
+    HAVING COUNT(DISTINCT TRIM(p.barcode)) = 1
+  ) c
+  WHERE c.barcode IS NOT NULL
+  ORDER BY c.pref
+  LIMIT 1
+) bc ON true`
+
+// HubSaleRow is one product sale at a hub branch that stock reconcile
+// hasn't processed yet.
+type HubSaleRow struct {
+	TransactionItemID   string
+	Barcode             string
+	BarcodeSource       string // Barcode* const; "" when no barcode was found
+	ProductName         string
+	ProductCategoryID   string
+	ProductCategoryName string
+	ProductBrandID      string
+	ProductBrandName    string
+	Quantity            int
+	StaffID             string
+	StaffFirstName      string
+	StaffLastName       string
+	PhysicalBranchID    sql.NullString
+	UpdatedAtPhorest    time.Time
+	PurchasedAt         sql.NullTime
 }
 
 type StockVirtualTransfer struct {
@@ -33,26 +87,35 @@ type StockVirtualTransferRow struct {
 	FromBranchID      string
 	ToBranchID        string
 	Barcode           string
+	BarcodeSource     string
 	Quantity          int
+	RuleID            sql.NullInt64 // core.stock_source_rules row that picked FromBranchID
 }
 
 type StockReconcileRepo struct {
 	DB *sql.DB
 }
 
-func (r *StockReconcileRepo) FetchUnprocessedPKItems(
+// FetchUnprocessedHubSales returns product sales made at hubBranchID that
+// have neither a transfer nor an open / ignored exception, oldest first.
+func (r *StockReconcileRepo) FetchUnprocessedHubSales(
 	ctx context.Context,
-	pkBranchID string,
+	hubBranchID string,
 	fromTS, toTS time.Time,
 	limit int,
 	testBarcode string,
-) ([]PKStockRow, error) {
+) ([]HubSaleRow, error) {
 
 	const q = `
 SELECT
   ti.transaction_item_id,
-  COALESCE(ti.product_barcode, '') AS barcode,
+  COALESCE(bc.barcode, '')         AS barcode,
+  COALESCE(bc.source, '')          AS barcode_source,
   COALESCE(ti.product_name, '')    AS product_name,
+  COALESCE(NULLIF(ti.product_category_id, ''), prod.category_id, '')     AS product_category_id,
+  COALESCE(NULLIF(ti.product_category_name, ''), prod.category_name, '') AS product_category_name,
+  COALESCE(NULLIF(ti.product_brand_id, ''), prod.brand_id, '')           AS product_brand_id,
+  COALESCE(NULLIF(ti.product_brand_name, ''), prod.brand_name, '')       AS product_brand_name,
   ti.quantity::int                 AS quantity,
   ti.staff_id,
   COALESCE(ti.staff_first_name, '') AS staff_first_name,
@@ -64,12 +127,14 @@ FROM raw.transactions t
 JOIN raw.transaction_items ti ON ti.transaction_id = t.transaction_id
 LEFT JOIN core.staff_physical_branch_overrides spbo
   ON spbo.staff_id = ti.staff_id AND spbo.active = true
+LEFT JOIN raw.ph_products prod ON prod.id = ti.product_id` + barcodeResolutionJoin + `
 WHERE t.branch_id = $1
   AND ti.quantity > 0
   AND ti.item_type = 'PRODUCT'
+  AND COALESCE(ti.void, 0) = 0
   AND ti.updated_at_phorest >= $2
   AND ti.updated_at_phorest <  $3
-  AND ($5 = '' OR ti.product_barcode = $5)
+  AND ($5 = '' OR bc.barcode = $5)
   AND NOT EXISTS (
     SELECT 1
     FROM core.stock_virtual_transfers svt
@@ -79,24 +144,30 @@ WHERE t.branch_id = $1
     SELECT 1
     FROM core.stock_virtual_transfer_exceptions svte
     WHERE svte.transaction_item_id = ti.transaction_item_id
+      AND svte.status IN ('open', 'ignored')
   )
 ORDER BY ti.updated_at_phorest ASC
 LIMIT $4;
 `
 
-	rows, err := r.DB.QueryContext(ctx, q, pkBranchID, fromTS, toTS, limit, testBarcode)
+	rows, err := r.DB.QueryContext(ctx, q, hubBranchID, fromTS, toTS, limit, testBarcode)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var out []PKStockRow
+	var out []HubSaleRow
 	for rows.Next() {
-		var r PKStockRow
+		var r HubSaleRow
 		if err := rows.Scan(
 			&r.TransactionItemID,
 			&r.Barcode,
+			&r.BarcodeSource,
 			&r.ProductName,
+			&r.ProductCategoryID,
+			&r.ProductCategoryName,
+			&r.ProductBrandID,
+			&r.ProductBrandName,
 			&r.Quantity,
 			&r.StaffID,
 			&r.StaffFirstName,
@@ -121,6 +192,25 @@ func (r *StockReconcileRepo) InsertStockVirtualTransfers(
 		return nil
 	}
 
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := insertStockVirtualTransfers(ctx, tx, transfers); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// insertStockVirtualTransfers inserts transfers in tx and returns the item
+// IDs that were already recorded (and so skipped).
+func insertStockVirtualTransfers(ctx context.Context, tx *sql.Tx, transfers []StockVirtualTransferRow) ([]string, error) {
 	const q = `
 INSERT INTO core.stock_virtual_transfers (
   transaction_item_id,
@@ -128,39 +218,75 @@ INSERT INTO core.stock_virtual_transfers (
   from_branch_id,
   to_branch_id,
   barcode,
-  quantity
+  barcode_source,
+  quantity,
+  rule_id
 ) VALUES (
-  $1, now(), $2, $3, $4, $5
+  $1, now(), $2, $3, $4, NULLIF($5, ''), $6, $7
 )
 ON CONFLICT (transaction_item_id) DO NOTHING;
 `
 
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
 	stmt, err := tx.PrepareContext(ctx, q)
 	if err != nil {
-		return fmt.Errorf("prepare: %w", err)
+		return nil, fmt.Errorf("prepare: %w", err)
 	}
 	defer stmt.Close()
 
+	var existing []string
 	for _, t := range transfers {
 		if t.FromBranchID == "" || t.ToBranchID == "" || t.Barcode == "" || t.Quantity <= 0 {
-			return fmt.Errorf("invalid transfer row: %+v", t)
+			return nil, fmt.Errorf("invalid transfer row: %+v", t)
 		}
-		if _, err := stmt.ExecContext(ctx,
+		res, err := stmt.ExecContext(ctx,
 			t.TransactionItemID,
 			t.FromBranchID,
 			t.ToBranchID,
 			t.Barcode,
+			t.BarcodeSource,
 			t.Quantity,
-		); err != nil {
-			return fmt.Errorf("insert transfer item_id=%s: %w", t.TransactionItemID, err)
+			t.RuleID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("insert transfer item_id=%s: %w", t.TransactionItemID, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			existing = append(existing, t.TransactionItemID)
 		}
 	}
+	return existing, nil
+}
+
+// PlanStockAdjustments records transfers and queues the adjustments that
+// carry them out in core.stock_adjustment_outbox, in one transaction: either
+// both are stored or neither is. If any item already has a transfer the
+// whole plan is rolled back, since its stock has been moved before.
+func (r *StockReconcileRepo) PlanStockAdjustments(
+	ctx context.Context,
+	adjustments []StockAdjustmentOutboxRow,
+	transfers []StockVirtualTransferRow,
+) error {
+	if len(adjustments) == 0 && len(transfers) == 0 {
+		return nil
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	existing, err := insertStockVirtualTransfers(ctx, tx, transfers)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%d item(s) already transferred (e.g. %s); plan discarded", len(existing), existing[0])
+	}
+
+	if err := queueStockAdjustments(ctx, tx, adjustments); err != nil {
+		return err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit: %w", err)
@@ -168,10 +294,55 @@ ON CONFLICT (transaction_item_id) DO NOTHING;
 	return nil
 }
 
+// queueStockAdjustments inserts pending core.stock_adjustment_outbox rows in tx.
+func queueStockAdjustments(ctx context.Context, tx *sql.Tx, adjustments []StockAdjustmentOutboxRow) error {
+	if len(adjustments) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO core.stock_adjustment_outbox (
+  hub_branch_id,
+  branch_id,
+  barcode,
+  quantity,
+  operation,
+  source_item_ids
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+`)
+	if err != nil {
+		return fmt.Errorf("prepare outbox: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, a := range adjustments {
+		if a.HubBranchID == "" || a.BranchID == "" || a.Barcode == "" || a.Quantity <= 0 {
+			return fmt.Errorf("invalid adjustment row: %+v", a)
+		}
+		if _, err := stmt.ExecContext(ctx,
+			a.HubBranchID,
+			a.BranchID,
+			a.Barcode,
+			a.Quantity,
+			a.Operation,
+			a.SourceItemIDs,
+		); err != nil {
+			return fmt.Errorf("queue %s branch=%s barcode=%s: %w", a.Operation, a.BranchID, a.Barcode, err)
+		}
+	}
+
+	return nil
+}
+
 // File: internal/repos/stock_reconcile_repo.go
+// InsertStockVirtualTransferExceptions records rows as open exceptions. An
+// item whose exception was resolved but still can't be processed is
+// reopened with the new reason.
 func (r *StockReconcileRepo) InsertStockVirtualTransferExceptions(
 	ctx context.Context,
-	rows []PKStockRow,
+	rows []HubSaleRow,
 	reason string,
 	// optional extras if you can provide them (otherwise pass empty strings)
 	productNameByBarcode map[string]string,
@@ -197,7 +368,14 @@ INSERT INTO core.stock_virtual_transfer_exceptions (
 ) VALUES (
   $1, $2, $3, $4, $5, $6, $7, $8
 )
-ON CONFLICT (transaction_item_id) DO NOTHING;
+ON CONFLICT (transaction_item_id) DO UPDATE SET
+  reason          = EXCLUDED.reason,
+  purchased_at    = EXCLUDED.purchased_at,
+  product_barcode = EXCLUDED.product_barcode,
+  status          = 'open',
+  checked_at      = now(),
+  resolved_at     = NULL
+WHERE core.stock_virtual_transfer_exceptions.status = 'resolved';
 `
 
 	tx, err := r.DB.BeginTx(ctx, nil)
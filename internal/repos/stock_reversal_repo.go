@@ -0,0 +1,250 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Reversal reasons (core.stock_virtual_transfer_reversals.reason).
+const (
+	ReversalVoid   = "VOID"
+	ReversalRefund = "REFUND"
+)
+
+// StockReversalCandidate is a voided sale, or a negative line pointing back
+// at one, paired with a transfer that still has stock left to move back. A
+// negative line matching several transfers gives one candidate per transfer.
+type StockReversalCandidate struct {
+	ReversalItemID    string // the reversing line (the sale itself for in-place voids)
+	Reason            string // ReversalVoid or ReversalRefund
+	TransactionItemID string // the transferred sale
+	FromBranchID      string // transfer's from_branch_id (where the stock came from)
+	ToBranchID        string // transfer's to_branch_id (hub)
+	Barcode           string
+	Quantity          int // units this line has still to reverse, over all its transfers
+	Remaining         int // units of the transfer not yet reversed
+	UpdatedAtPhorest  time.Time
+}
+
+// StockVirtualTransferReversalRow is one recorded reversal: Quantity of
+// Barcode taken back from FromBranchID (the hub) and returned to ToBranchID.
+type StockVirtualTransferReversalRow struct {
+	ReversalItemID    string
+	TransactionItemID string
+	Reason            string
+	FromBranchID      string
+	ToBranchID        string
+	Barcode           string
+	Quantity          int
+}
+
+// FetchReversibleItems finds lines that undo sales already transferred into
+// hubBranchID and not yet fully reversed:
+//   - a transferred sale later flagged void (reverses the whole transfer);
+//   - a negative PRODUCT line whose voided_transaction_id is the sale's
+//     transaction and whose product matches it (void or refund).
+//
+// A negative line is paired with every matching transfer that still has
+// stock to move back, oldest first, so a refund of 2 against two
+// single-unit lines undoes both. The caller spreads the line over them and
+// caps each transfer (Remaining) across the batch, so a sale voided in place
+// and by a negative line is only moved back once.
+func (r *StockReconcileRepo) FetchReversibleItems(
+	ctx context.Context,
+	hubBranchID string,
+	fromTS, toTS time.Time,
+	limit int,
+	testBarcode string,
+) ([]StockReversalCandidate, error) {
+
+	const q = `
+WITH candidates AS (
+  -- sales voided in place after their stock was moved
+  SELECT
+    ti.transaction_item_id AS reversal_item_id,
+    'VOID'                 AS reason,
+    svt.transaction_item_id,
+    svt.quantity           AS quantity,
+    ti.updated_at_phorest
+  FROM core.stock_virtual_transfers svt
+  JOIN raw.transaction_items ti ON ti.transaction_item_id = svt.transaction_item_id
+  WHERE svt.to_branch_id = $1
+    AND COALESCE(ti.void, 0) <> 0
+
+  UNION ALL
+
+  -- negative lines voiding / refunding a transferred sale, once per
+  -- matching transfer
+  SELECT
+    nti.transaction_item_id,
+    CASE WHEN COALESCE(nti.void, 0) <> 0 THEN 'VOID' ELSE 'REFUND' END,
+    svt.transaction_item_id,
+    (-nti.quantity)::int,
+    nti.updated_at_phorest
+  FROM raw.transaction_items nti
+  JOIN raw.transaction_items oti ON oti.transaction_id = nti.voided_transaction_id
+  JOIN core.stock_virtual_transfers svt ON svt.transaction_item_id = oti.transaction_item_id
+  WHERE nti.item_type = 'PRODUCT'
+    AND nti.quantity < 0
+    AND COALESCE(nti.voided_transaction_id, '') <> ''
+    AND svt.to_branch_id = $1
+    AND (
+      (COALESCE(nti.product_barcode, '') <> '' AND svt.barcode = nti.product_barcode)
+      OR (COALESCE(nti.product_id, '') <> '' AND oti.product_id = nti.product_id)
+    )
+)
+SELECT
+  c.reversal_item_id,
+  c.reason,
+  svt.transaction_item_id,
+  svt.from_branch_id,
+  svt.to_branch_id,
+  svt.barcode,
+  c.quantity - COALESCE(ldone.quantity, 0) AS quantity,
+  svt.quantity - COALESCE(done.quantity, 0) AS remaining,
+  c.updated_at_phorest
+FROM candidates c
+JOIN core.stock_virtual_transfers svt ON svt.transaction_item_id = c.transaction_item_id
+LEFT JOIN LATERAL (
+  -- what the transfer has had moved back
+  SELECT SUM(svtr.quantity)::int AS quantity
+  FROM core.stock_virtual_transfer_reversals svtr
+  WHERE svtr.transaction_item_id = svt.transaction_item_id
+) done ON true
+LEFT JOIN LATERAL (
+  -- what the line has moved back, over all its transfers
+  SELECT SUM(svtr.quantity)::int AS quantity
+  FROM core.stock_virtual_transfer_reversals svtr
+  WHERE svtr.reversal_item_id = c.reversal_item_id
+) ldone ON true
+WHERE c.updated_at_phorest >= $2
+  AND c.updated_at_phorest <  $3
+  AND c.quantity - COALESCE(ldone.quantity, 0) > 0
+  AND svt.from_branch_id <> svt.to_branch_id -- hub's own stock: nothing to undo
+  AND ($5 = '' OR svt.barcode = $5)
+  AND svt.quantity - COALESCE(done.quantity, 0) > 0
+  AND NOT EXISTS (
+    SELECT 1
+    FROM core.stock_virtual_transfer_reversals svtr
+    WHERE svtr.reversal_item_id = c.reversal_item_id
+      AND svtr.transaction_item_id = c.transaction_item_id
+  )
+ORDER BY c.updated_at_phorest ASC, c.reversal_item_id ASC, svt.processed_at ASC, svt.transaction_item_id ASC
+LIMIT $4;
+`
+
+	rows, err := r.DB.QueryContext(ctx, q, hubBranchID, fromTS, toTS, limit, testBarcode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StockReversalCandidate
+	for rows.Next() {
+		var c StockReversalCandidate
+		if err := rows.Scan(
+			&c.ReversalItemID,
+			&c.Reason,
+			&c.TransactionItemID,
+			&c.FromBranchID,
+			&c.ToBranchID,
+			&c.Barcode,
+			&c.Quantity,
+			&c.Remaining,
+			&c.UpdatedAtPhorest,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+
+	return out, rows.Err()
+}
+
+// PlanStockReversals records reversals and queues the adjustments that carry
+// them out, in one transaction, like PlanStockAdjustments. If any reversing
+// line was already recorded against its transfer the whole plan is rolled
+// back.
+func (r *StockReconcileRepo) PlanStockReversals(
+	ctx context.Context,
+	adjustments []StockAdjustmentOutboxRow,
+	reversals []StockVirtualTransferReversalRow,
+) error {
+	if len(adjustments) == 0 && len(reversals) == 0 {
+		return nil
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	existing, err := insertStockVirtualTransferReversals(ctx, tx, reversals)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%d line(s) already reversed (e.g. %s); plan discarded", len(existing), existing[0])
+	}
+
+	if err := queueStockAdjustments(ctx, tx, adjustments); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// insertStockVirtualTransferReversals inserts reversals in tx and returns
+// the line → transfer pairs that were already recorded (and so skipped).
+func insertStockVirtualTransferReversals(ctx context.Context, tx *sql.Tx, reversals []StockVirtualTransferReversalRow) ([]string, error) {
+	const q = `
+INSERT INTO core.stock_virtual_transfer_reversals (
+  reversal_item_id,
+  transaction_item_id,
+  reason,
+  processed_at,
+  from_branch_id,
+  to_branch_id,
+  barcode,
+  quantity
+) VALUES (
+  $1, $2, $3, now(), $4, $5, $6, $7
+)
+ON CONFLICT (reversal_item_id, transaction_item_id) DO NOTHING;
+`
+
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	var existing []string
+	for _, v := range reversals {
+		if v.FromBranchID == "" || v.ToBranchID == "" || v.Barcode == "" || v.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid reversal row: %+v", v)
+		}
+		res, err := stmt.ExecContext(ctx,
+			v.ReversalItemID,
+			v.TransactionItemID,
+			v.Reason,
+			v.FromBranchID,
+			v.ToBranchID,
+			v.Barcode,
+			v.Quantity,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("insert reversal item_id=%s: %w", v.ReversalItemID, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			existing = append(existing, v.ReversalItemID+" → "+v.TransactionItemID)
+		}
+	}
+	return existing, nil
+}
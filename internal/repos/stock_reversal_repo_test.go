@@ -0,0 +1,176 @@
+package repos_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/e2e"
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func mustExec(t *testing.T, db *sql.DB, q string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(q, args...); err != nil {
+		t.Fatalf("%v\n%s", err, q)
+	}
+}
+
+// reversalFixture inserts sale-1 and sale-2, one unit each of B1 on tx-1,
+// both transferred base → pk, and the refund lines on tx-2 (line ID →
+// quantity, negative).
+func reversalFixture(t *testing.T, db *sql.DB, now time.Time, refunds map[string]int) {
+	t.Helper()
+	mustExec(t, db, `
+INSERT INTO raw.transaction_items
+  (transaction_item_id, transaction_id, item_type, product_id, product_barcode, quantity, updated_at_phorest)
+VALUES
+  ('sale-1', 'tx-1', 'PRODUCT', 'prod-1', 'B1', 1, $1),
+  ('sale-2', 'tx-1', 'PRODUCT', 'prod-1', 'B1', 1, $1)`, now.Add(-2*time.Hour))
+	for id, qty := range refunds {
+		mustExec(t, db, `
+INSERT INTO raw.transaction_items
+  (transaction_item_id, transaction_id, item_type, product_id, product_barcode, quantity, voided_transaction_id, updated_at_phorest)
+VALUES ($1, 'tx-2', 'PRODUCT', 'prod-1', 'B1', $2, 'tx-1', $3)`, id, qty, now.Add(-time.Hour))
+	}
+	mustExec(t, db, `
+INSERT INTO core.stock_virtual_transfers (transaction_item_id, from_branch_id, to_branch_id, barcode, quantity, processed_at)
+VALUES ('sale-1', 'base', 'pk', 'B1', 1, $1),
+       ('sale-2', 'base', 'pk', 'B1', 1, $2)`, now.Add(-2*time.Hour), now.Add(-90*time.Minute))
+}
+
+// reverseCandidate records c as reversing qty units, like a live run.
+func reverseCandidate(t *testing.T, repo *repos.StockReconcileRepo, c repos.StockReversalCandidate, qty int) {
+	t.Helper()
+	err := repo.PlanStockReversals(context.Background(), nil, []repos.StockVirtualTransferReversalRow{{
+		ReversalItemID:    c.ReversalItemID,
+		TransactionItemID: c.TransactionItemID,
+		Reason:            c.Reason,
+		FromBranchID:      c.ToBranchID,
+		ToBranchID:        c.FromBranchID,
+		Barcode:           c.Barcode,
+		Quantity:          qty,
+	}})
+	if err != nil {
+		t.Fatalf("plan reversal of %s → %s: %v", c.ReversalItemID, c.TransactionItemID, err)
+	}
+}
+
+func fetchReversible(t *testing.T, repo *repos.StockReconcileRepo, now time.Time) []repos.StockReversalCandidate {
+	t.Helper()
+	cands, err := repo.FetchReversibleItems(context.Background(), "pk", now.Add(-24*time.Hour), now.Add(time.Hour), 100, "")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	return cands
+}
+
+// A sale with two lines of one product, both transferred, then both
+// refunded: each refund must move one line's stock back, not both bind to
+// the first line.
+func TestFetchReversibleItemsTwoRefundsOfOneSale(t *testing.T) {
+	db := e2e.NewTestDB(t)
+	repo := &repos.StockReconcileRepo{DB: db}
+
+	now := time.Now().UTC()
+	reversalFixture(t, db, now, map[string]int{"refund-1": -1, "refund-2": -1})
+
+	fetch := func() []repos.StockReversalCandidate {
+		t.Helper()
+		return fetchReversible(t, repo, now)
+	}
+	reverse := func(c repos.StockReversalCandidate) {
+		t.Helper()
+		reverseCandidate(t, repo, c, min(c.Quantity, c.Remaining))
+	}
+
+	cands := fetch()
+	if len(cands) != 4 {
+		t.Fatalf("first fetch: want each refund paired with each sale, got %+v", cands)
+	}
+	if cands[0].ReversalItemID != "refund-1" || cands[0].TransactionItemID != "sale-1" {
+		t.Fatalf("first fetch: want refund-1 -> sale-1 first, got %+v", cands)
+	}
+	if cands[0].Reason != repos.ReversalRefund || cands[0].Quantity != 1 || cands[0].Remaining != 1 {
+		t.Fatalf("first fetch: want a 1-unit REFUND with 1 remaining, got %+v", cands[0])
+	}
+	reverse(cands[0])
+
+	cands = fetch()
+	if len(cands) != 1 {
+		t.Fatalf("second fetch: want only refund-2, got %+v", cands)
+	}
+	if c := cands[0]; c.ReversalItemID != "refund-2" || c.TransactionItemID != "sale-2" || c.Remaining != 1 {
+		t.Fatalf("second fetch: want refund-2 -> sale-2 with 1 remaining, got %+v", c)
+	}
+	if c := cands[0]; c.FromBranchID != "base" || c.ToBranchID != "pk" {
+		t.Fatalf("second fetch: want the base -> pk transfer, got %+v", c)
+	}
+	reverse(cands[0])
+
+	if cands = fetch(); len(cands) != 0 {
+		t.Fatalf("both sales reversed, want nothing left, got %+v", cands)
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(DISTINCT transaction_item_id) FROM core.stock_virtual_transfer_reversals`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want reversals against both sales, got %d", n)
+	}
+}
+
+// One refund line of two units against a sale with two single-unit lines:
+// both units go back, one from each transfer, oldest first.
+func TestFetchReversibleItemsMultiUnitRefund(t *testing.T) {
+	db := e2e.NewTestDB(t)
+	repo := &repos.StockReconcileRepo{DB: db}
+
+	now := time.Now().UTC()
+	reversalFixture(t, db, now, map[string]int{"refund-1": -2})
+
+	cands := fetchReversible(t, repo, now)
+	if len(cands) != 2 {
+		t.Fatalf("first fetch: want refund-1 paired with both sales, got %+v", cands)
+	}
+	for i, item := range []string{"sale-1", "sale-2"} {
+		if c := cands[i]; c.ReversalItemID != "refund-1" || c.TransactionItemID != item || c.Quantity != 2 || c.Remaining != 1 {
+			t.Fatalf("first fetch[%d]: want refund-1 → %s, 2 to reverse, 1 remaining, got %+v", i, item, c)
+		}
+	}
+
+	// Only the first unit makes it in: the line is still open for the other.
+	reverseCandidate(t, repo, cands[0], 1)
+
+	cands = fetchReversible(t, repo, now)
+	if len(cands) != 1 {
+		t.Fatalf("second fetch: want refund-1 → sale-2 only, got %+v", cands)
+	}
+	if c := cands[0]; c.TransactionItemID != "sale-2" || c.Quantity != 1 || c.Remaining != 1 {
+		t.Fatalf("second fetch: want refund-1 → sale-2, 1 to reverse, 1 remaining, got %+v", c)
+	}
+	reverseCandidate(t, repo, cands[0], 1)
+
+	if cands = fetchReversible(t, repo, now); len(cands) != 0 {
+		t.Fatalf("refund fully reversed, want nothing left, got %+v", cands)
+	}
+
+	// Recording a pair twice discards the plan.
+	err := repo.PlanStockReversals(context.Background(), nil, []repos.StockVirtualTransferReversalRow{{
+		ReversalItemID: "refund-1", TransactionItemID: "sale-1", Reason: repos.ReversalRefund,
+		FromBranchID: "pk", ToBranchID: "base", Barcode: "B1", Quantity: 1,
+	}})
+	if err == nil {
+		t.Fatal("re-recording refund-1 → sale-1: want an error")
+	}
+
+	var rows, units int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(quantity) FROM core.stock_virtual_transfer_reversals WHERE reversal_item_id = 'refund-1'`).Scan(&rows, &units); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 || units != 2 {
+		t.Fatalf("want refund-1 recorded against both sales for 2 units, got %d row(s), %d unit(s)", rows, units)
+	}
+}
@@ -0,0 +1,161 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Stock sources (core.stock_source_rules.source): where a hub sale's stock
+// is deducted from.
+const (
+	SourceStaffOverride = "STAFF_OVERRIDE" // the stylist's physical branch override
+	SourceBranch        = "BRANCH"         // the rule's SourceBranchID
+	SourceSellingBranch = "SELLING_BRANCH" // the hub itself: nothing to move
+)
+
+// StockSourceRule says which branch's stock the matching sales of
+// SellingBranchID come from. Empty filters match anything.
+type StockSourceRule struct {
+	ID              int64
+	SellingBranchID string
+	Priority        int
+	ProductCategory string // category ID or name
+	ProductBrand    string // brand ID or name
+	StaffID         string
+	Source          string
+	SourceBranchID  string // only for SourceBranch
+	Active          bool
+	Note            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type StockSourceRuleRepo struct {
+	DB *sql.DB
+}
+
+// List returns the rules of sellingBranchID ("" = every branch), in the
+// order they are tried: priority, then id.
+func (r *StockSourceRuleRepo) List(ctx context.Context, sellingBranchID string, activeOnly bool) ([]StockSourceRule, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT
+  id,
+  selling_branch_id,
+  priority,
+  COALESCE(product_category, ''),
+  COALESCE(product_brand, ''),
+  COALESCE(staff_id, ''),
+  source,
+  COALESCE(source_branch_id, ''),
+  active,
+  COALESCE(note, ''),
+  created_at,
+  updated_at
+FROM core.stock_source_rules
+WHERE ($1 = '' OR selling_branch_id = $1)
+  AND (active OR NOT $2)
+ORDER BY selling_branch_id, priority, id`, sellingBranchID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StockSourceRule
+	for rows.Next() {
+		var s StockSourceRule
+		if err := rows.Scan(
+			&s.ID,
+			&s.SellingBranchID,
+			&s.Priority,
+			&s.ProductCategory,
+			&s.ProductBrand,
+			&s.StaffID,
+			&s.Source,
+			&s.SourceBranchID,
+			&s.Active,
+			&s.Note,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// SellingBranches returns every branch with at least one active rule, with
+// when the oldest of those rules was created.
+func (r *StockSourceRuleRepo) SellingBranches(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT selling_branch_id, MIN(created_at)
+FROM core.stock_source_rules
+WHERE active
+GROUP BY selling_branch_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]time.Time)
+	for rows.Next() {
+		var (
+			id    string
+			since time.Time
+		)
+		if err := rows.Scan(&id, &since); err != nil {
+			return nil, err
+		}
+		out[id] = since
+	}
+	return out, rows.Err()
+}
+
+// Add stores a new active rule and returns its ID.
+func (r *StockSourceRuleRepo) Add(ctx context.Context, s StockSourceRule) (int64, error) {
+	var id int64
+	err := r.DB.QueryRowContext(ctx, `
+INSERT INTO core.stock_source_rules (
+  selling_branch_id,
+  priority,
+  product_category,
+  product_brand,
+  staff_id,
+  source,
+  source_branch_id,
+  note
+) VALUES (
+  $1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, '')
+)
+RETURNING id`,
+		s.SellingBranchID,
+		s.Priority,
+		s.ProductCategory,
+		s.ProductBrand,
+		s.StaffID,
+		s.Source,
+		s.SourceBranchID,
+		s.Note,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("add stock source rule: %w", err)
+	}
+	return id, nil
+}
+
+// SetActive enables or disables rule id.
+func (r *StockSourceRuleRepo) SetActive(ctx context.Context, id int64, active bool) error {
+	res, err := r.DB.ExecContext(ctx, `
+UPDATE core.stock_source_rules
+SET active = $2
+WHERE id = $1`, id, active)
+	if err != nil {
+		return fmt.Errorf("update stock source rule %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no stock source rule %d", id)
+	}
+	return nil
+}
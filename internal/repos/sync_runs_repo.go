@@ -0,0 +1,150 @@
+package repos
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/db"
+	"github.com/araquach/phorest-datahub/internal/models"
+	"gorm.io/gorm"
+)
+
+// SyncRunsRepo reads/writes core.sync_runs + core.sync_run_branches.
+type SyncRunsRepo struct {
+	db *gorm.DB
+	lg *log.Logger
+}
+
+func NewSyncRunsRepo(db *gorm.DB, lg *log.Logger) *SyncRunsRepo {
+	return &SyncRunsRepo{db: db, lg: lg}
+}
+
+// Start inserts a new "running" run for entity.
+func (r *SyncRunsRepo) Start(entity string) (*models.SyncRun, error) {
+	run := &models.SyncRun{
+		Entity:    entity,
+		Status:    models.SyncRunRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := r.db.Omit("Branches").Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Finish stamps the run with its final status / totals.
+func (r *SyncRunsRepo) Finish(run *models.SyncRun, runErr error) error {
+	now := time.Now().UTC()
+	run.FinishedAt = &now
+	run.Status, run.Error = outcome(runErr)
+
+	return r.db.Model(&models.SyncRun{}).
+		Where("id = ?", run.ID).
+		Updates(map[string]any{
+			"status":        run.Status,
+			"finished_at":   run.FinishedAt,
+			"rows_fetched":  run.RowsFetched,
+			"rows_upserted": run.RowsUpserted,
+			"error":         run.Error,
+		}).Error
+}
+
+// StartBranch inserts a "running" branch row under runID.
+func (r *SyncRunsRepo) StartBranch(runID int64, branchID string, watermarkBefore *time.Time) (*models.SyncRunBranch, error) {
+	b := &models.SyncRunBranch{
+		RunID:           runID,
+		BranchID:        normaliseBranchID(branchID),
+		Status:          models.SyncRunRunning,
+		StartedAt:       time.Now().UTC(),
+		WatermarkBefore: watermarkBefore,
+	}
+	if err := r.db.Create(b).Error; err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// FinishBranch stamps a branch row with its outcome.
+func (r *SyncRunsRepo) FinishBranch(b *models.SyncRunBranch, branchErr error) error {
+	now := time.Now().UTC()
+	b.FinishedAt = &now
+	b.Status, b.Error = outcome(branchErr)
+
+	return r.db.Model(&models.SyncRunBranch{}).
+		Where("id = ?", b.ID).
+		Updates(map[string]any{
+			"status":          b.Status,
+			"finished_at":     b.FinishedAt,
+			"window_from":     b.WindowFrom,
+			"window_to":       b.WindowTo,
+			"rows_fetched":    b.RowsFetched,
+			"rows_upserted":   b.RowsUpserted,
+			"watermark_after": b.WatermarkAfter,
+			"error":           b.Error,
+		}).Error
+}
+
+// SyncRunFilter narrows List. Empty fields are ignored.
+// When BranchID is set, Status applies to that branch's outcome, not the whole run.
+type SyncRunFilter struct {
+	Entity   string
+	BranchID string
+	Status   string
+	Limit    int
+}
+
+// List returns runs newest first.
+func (r *SyncRunsRepo) List(f SyncRunFilter) ([]models.SyncRun, error) {
+	if f.Limit <= 0 {
+		f.Limit = 20
+	}
+
+	q := r.db.Model(&models.SyncRun{}).Order("started_at DESC").Limit(f.Limit)
+
+	if f.Entity != "" {
+		q = q.Where("entity = ?", f.Entity)
+	}
+
+	if f.BranchID != "" {
+		sub := r.db.Model(&models.SyncRunBranch{}).
+			Select("1").
+			Where("sync_run_branches.run_id = sync_runs.id AND sync_run_branches.branch_id = ?", f.BranchID)
+		if f.Status != "" {
+			sub = sub.Where("sync_run_branches.status = ?", f.Status)
+		}
+		q = q.Where("EXISTS (?)", sub)
+	} else if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+
+	var rows []models.SyncRun
+	err := q.Find(&rows).Error
+	return rows, err
+}
+
+// Get returns a run with its branch rows, or nil if not found.
+func (r *SyncRunsRepo) Get(id int64) (*models.SyncRun, error) {
+	var run models.SyncRun
+	err := r.db.
+		Preload("Branches", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		First(&run, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func outcome(err error) (string, *string) {
+	if err == nil {
+		return models.SyncRunSuccess, nil
+	}
+	msg := err.Error()
+	if errors.Is(err, db.ErrLockHeld) {
+		return models.SyncRunSkipped, &msg
+	}
+	return models.SyncRunFailed, &msg
+}
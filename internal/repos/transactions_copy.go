@@ -0,0 +1,123 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// TransactionsLoadFunc merges one chunk of headers and items into raw.*.
+type TransactionsLoadFunc func(txs []models.Transaction, items []models.TransactionItem) error
+
+const (
+	stageTransactions = "stage_transactions"
+	stageItems        = "stage_transaction_items"
+)
+
+// CopyImport hands fill a loader that COPYs each chunk into temp staging
+// tables and merges it into raw.transactions / raw.transaction_items with
+// the same rules as UpsertBatch, so the newest header by updated_at_phorest
+// still wins across chunks. Everything runs in one transaction on a
+// dedicated connection, which also writes rec (the file's ledger entry and
+// the rejects fill added to it, see recordImportTx): the file lands whole
+// and recorded, or not at all.
+func (r *TransactionsRepo) CopyImport(ctx context.Context, rec *ImportRecord, fill func(load TransactionsLoadFunc) error) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return fmt.Errorf("copy import: %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("copy import: get connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("copy import: need the pgx driver, got %T", driverConn)
+		}
+
+		tx, err := sc.Conn().Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("copy import: begin: %w", err)
+		}
+		defer tx.Rollback(ctx) // no-op once committed
+
+		for _, ddl := range []string{
+			stageDDL(stageTransactions, "raw.transactions", transactionCols),
+			stageDDL(stageItems, "raw.transaction_items", itemCols),
+		} {
+			if _, err := tx.Exec(ctx, ddl); err != nil {
+				return fmt.Errorf("copy import: create staging: %w", err)
+			}
+		}
+
+		loaded := 0
+		load := func(txs []models.Transaction, items []models.TransactionItem) error {
+			now := time.Now().UTC()
+
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{stageTransactions}, transactionCols,
+				pgx.CopyFromSlice(len(txs), func(i int) ([]any, error) {
+					return transactionValues(txs[i], now), nil
+				})); err != nil {
+				return fmt.Errorf("copy transactions: %w", err)
+			}
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{stageItems}, itemCols,
+				pgx.CopyFromSlice(len(items), func(i int) ([]any, error) {
+					return itemValues(items[i], now), nil
+				})); err != nil {
+				return fmt.Errorf("copy items: %w", err)
+			}
+
+			if _, err := tx.Exec(ctx, mergeSQL("raw.transactions AS t", stageTransactions, "transaction_id", transactionCols, transactionsOnConflict)); err != nil {
+				return fmt.Errorf("merge transactions: %w", err)
+			}
+			if _, err := tx.Exec(ctx, mergeSQL("raw.transaction_items AS ti", stageItems, "transaction_item_id", itemCols, itemsOnConflict)); err != nil {
+				return fmt.Errorf("merge items: %w", err)
+			}
+			if _, err := tx.Exec(ctx, "TRUNCATE "+stageTransactions+", "+stageItems); err != nil {
+				return fmt.Errorf("truncate staging: %w", err)
+			}
+
+			loaded += len(items)
+			r.lg.Printf("Merged chunk: %d transactions, %d items", len(txs), len(items))
+			return nil
+		}
+
+		if err := fill(load); err != nil {
+			return err
+		}
+		rec.Entry.RowCount, rec.Entry.RejectedCount = loaded, len(rec.Rejects)
+		if err := recordImportTx(ctx, tx, rec); err != nil {
+			return fmt.Errorf("copy import: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("copy import: commit: %w", err)
+		}
+		return nil
+	})
+}
+
+// stageDDL creates a constraint-free temp copy of cols from table.
+func stageDDL(stage, table string, cols []string) string {
+	return fmt.Sprintf(`CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA`,
+		stage, strings.Join(cols, ", "), table)
+}
+
+// mergeSQL upserts stage into target. DISTINCT ON keeps one row per key
+// (the newest) so ON CONFLICT never meets the same row twice in a statement.
+func mergeSQL(target, stage, key string, cols []string, onConflict string) string {
+	list := strings.Join(cols, ", ")
+	return fmt.Sprintf(`
+INSERT INTO %s (%s)
+SELECT DISTINCT ON (%s) %s
+FROM %s
+ORDER BY %s, updated_at_phorest DESC NULLS LAST
+%s;`, target, list, key, list, stage, key, onConflict)
+}
@@ -10,6 +10,41 @@ import (
 	"gorm.io/gorm"
 )
 
+var transactionCols = []string{
+	"transaction_id", "branch_id", "branch_name",
+	"client_id", "client_first_name", "client_last_name", "client_source",
+	"purchased_date", "purchase_time",
+	"updated_at_phorest",
+	"created_at", "updated_at",
+}
+
+// transactionsOnConflict keeps the newest header by updated_at_phorest.
+const transactionsOnConflict = `
+ON CONFLICT (transaction_id) DO UPDATE SET
+  branch_id = EXCLUDED.branch_id,
+  branch_name = EXCLUDED.branch_name,
+  client_id = EXCLUDED.client_id,
+  client_first_name = EXCLUDED.client_first_name,
+  client_last_name = EXCLUDED.client_last_name,
+  client_source = EXCLUDED.client_source,
+  purchased_date = EXCLUDED.purchased_date,
+  purchase_time = EXCLUDED.purchase_time,
+  updated_at_phorest = EXCLUDED.updated_at_phorest,
+  updated_at = EXCLUDED.updated_at
+WHERE t.updated_at_phorest IS NULL
+   OR EXCLUDED.updated_at_phorest > t.updated_at_phorest`
+
+// transactionValues lines row up with transactionCols.
+func transactionValues(row models.Transaction, now time.Time) []any {
+	return []any{
+		row.TransactionID, row.BranchID, row.BranchName,
+		row.ClientID, row.ClientFirstName, row.ClientLastName, row.ClientSource,
+		row.PurchasedDate, row.PurchaseTime,
+		row.UpdatedAtPhorest,
+		now, now,
+	}
+}
+
 type TransactionsRepo struct {
 	db *gorm.DB
 	lg *log.Logger
@@ -24,14 +59,7 @@ func (r *TransactionsRepo) UpsertBatch(rows []models.Transaction, batchSize int)
 		return nil
 	}
 	now := time.Now().UTC()
-
-	cols := []string{
-		"transaction_id", "branch_id", "branch_name",
-		"client_id", "client_first_name", "client_last_name", "client_source",
-		"purchased_date", "purchase_time",
-		"updated_at_phorest",
-		"created_at", "updated_at",
-	}
+	cols := transactionCols
 
 	placeholders := make([]string, 0, len(rows))
 	args := make([]any, 0, len(rows)*len(cols))
@@ -43,21 +71,10 @@ func (r *TransactionsRepo) UpsertBatch(rows []models.Transaction, batchSize int)
 		sql := fmt.Sprintf(`
 INSERT INTO raw.transactions AS t (%s)
 VALUES %s
-ON CONFLICT (transaction_id) DO UPDATE SET
-  branch_id = EXCLUDED.branch_id,
-  branch_name = EXCLUDED.branch_name,
-  client_id = EXCLUDED.client_id,
-  client_first_name = EXCLUDED.client_first_name,
-  client_last_name = EXCLUDED.client_last_name,
-  client_source = EXCLUDED.client_source,
-  purchased_date = EXCLUDED.purchased_date,
-  purchase_time = EXCLUDED.purchase_time,
-  updated_at_phorest = EXCLUDED.updated_at_phorest,
-  updated_at = EXCLUDED.updated_at
-WHERE t.updated_at_phorest IS NULL
-   OR EXCLUDED.updated_at_phorest > t.updated_at_phorest;`,
+%s;`,
 			strings.Join(cols, ", "),
 			strings.Join(placeholders, ","),
+			transactionsOnConflict,
 		)
 		if err := r.db.Exec(sql, args...).Error; err != nil {
 			return err
@@ -70,13 +87,7 @@ WHERE t.updated_at_phorest IS NULL
 
 	for _, row := range rows {
 		placeholders = append(placeholders, "("+strings.Repeat("?,", len(cols)-1)+"?)")
-		args = append(args,
-			row.TransactionID, row.BranchID, row.BranchName,
-			row.ClientID, row.ClientFirstName, row.ClientLastName, row.ClientSource,
-			row.PurchasedDate, row.PurchaseTime,
-			row.UpdatedAtPhorest,
-			now, now,
-		)
+		args = append(args, transactionValues(row, now)...)
 		if len(placeholders) >= batchSize {
 			if err := flush(); err != nil {
 				return err
@@ -0,0 +1,110 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WatermarkEntity is a sync_watermarks.entity. It is also the entity a sync
+// records its runs under (core.sync_runs.entity).
+type WatermarkEntity string
+
+const (
+	WatermarkTransactionsCSV           WatermarkEntity = "transactions_csv"
+	WatermarkClientsCSV                WatermarkEntity = "clients_csv"
+	WatermarkClientsAPI                WatermarkEntity = "clients_api"
+	WatermarkAppointmentsAPI           WatermarkEntity = "appointments_api"
+	WatermarkBreaksAPI                 WatermarkEntity = "breaks_api"
+	WatermarkReviewsAPI                WatermarkEntity = "reviews_api"
+	WatermarkProductsAPI               WatermarkEntity = "products_api"
+	WatermarkStaffAPI                  WatermarkEntity = "staff_api"
+	WatermarkBranchesAPI               WatermarkEntity = "branches_api"
+	WatermarkWorktimetableRolling      WatermarkEntity = "worktimetable"
+	WatermarkWorktimetableBackfillDone WatermarkEntity = "worktimetable_backfill_done"
+)
+
+// WatermarkEntities lists every known entity.
+var WatermarkEntities = []WatermarkEntity{
+	WatermarkTransactionsCSV,
+	WatermarkClientsCSV,
+	WatermarkClientsAPI,
+	WatermarkAppointmentsAPI,
+	WatermarkBreaksAPI,
+	WatermarkReviewsAPI,
+	WatermarkProductsAPI,
+	WatermarkStaffAPI,
+	WatermarkBranchesAPI,
+	WatermarkWorktimetableRolling,
+	WatermarkWorktimetableBackfillDone,
+}
+
+// WatermarkAllBranches is the branch key of a business-wide watermark for
+// the primary business; other businesses use "ALL:<businessID>" (see
+// BusinessWideKey).
+const WatermarkAllBranches = "ALL"
+
+// BusinessWideKey is the branch key business-wide watermarks (and sync
+// history rows) of businessID are stored under. The primary business keeps
+// plain "ALL" so single-business installs see no change.
+func BusinessWideKey(businessID string, primary bool) string {
+	if primary {
+		return WatermarkAllBranches
+	}
+	return WatermarkAllBranches + ":" + businessID
+}
+
+// IsBusinessWideKey reports whether branchID is "ALL" or "ALL:<businessID>".
+func IsBusinessWideKey(branchID string) bool {
+	return branchID == WatermarkAllBranches || strings.HasPrefix(branchID, WatermarkAllBranches+":")
+}
+
+// ParseWatermarkEntity validates a user-supplied entity name.
+func ParseWatermarkEntity(s string) (WatermarkEntity, error) {
+	for _, e := range WatermarkEntities {
+		if string(e) == s {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown watermark entity %q (want one of %s)", s, JoinWatermarkEntities(", "))
+}
+
+// JoinWatermarkEntities renders WatermarkEntities for help and error text.
+func JoinWatermarkEntities(sep string) string {
+	names := make([]string, len(WatermarkEntities))
+	for i, e := range WatermarkEntities {
+		names[i] = string(e)
+	}
+	return strings.Join(names, sep)
+}
+
+// BusinessWide reports whether the entity is kept once per business (under
+// BusinessWideKey) rather than once per branch.
+func (e WatermarkEntity) BusinessWide() bool {
+	switch e {
+	case WatermarkClientsCSV, WatermarkClientsAPI, WatermarkBranchesAPI:
+		return true
+	}
+	return false
+}
+
+// branchKey normalises branchID for e ("" = "ALL") and rejects a key of the
+// wrong kind, so a business-wide watermark can't be written per branch or
+// the other way round.
+func (e WatermarkEntity) branchKey(branchID string) (string, error) {
+	branchID = normaliseBranchID(branchID)
+	if e.BusinessWide() != IsBusinessWideKey(branchID) {
+		if e.BusinessWide() {
+			return "", fmt.Errorf("watermark %s is business-wide: branch must be ALL or ALL:<businessID>, got %q", e, branchID)
+		}
+		return "", fmt.Errorf("watermark %s is per branch: got business-wide key %q", e, branchID)
+	}
+	return branchID, nil
+}
+
+// normaliseBranchID maps "" to the canonical business-wide key "ALL".
+func normaliseBranchID(branchID string) string {
+	if branchID == "" {
+		return WatermarkAllBranches
+	}
+	return branchID
+}
@@ -0,0 +1,108 @@
+package repos
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Who moved a watermark, recorded in core.watermark_history.source.
+const (
+	WatermarkSourceSync      = "sync"
+	WatermarkSourceBootstrap = "bootstrap"
+	WatermarkSourceAdmin     = "admin" // datahub watermarks set/rewind/reset
+)
+
+const (
+	// DefaultWatermarkMaxJump is how far one move may advance a watermark
+	// before it is flagged (WATERMARK_MAX_JUMP overrides it).
+	DefaultWatermarkMaxJump = 30 * 24 * time.Hour
+
+	// watermarkFutureSkew tolerates clock drift between us and Phorest
+	// before a watermark ahead of now is flagged.
+	watermarkFutureSkew = 5 * time.Minute
+)
+
+// WatermarkHistory is one move of a sync_watermarks row.
+type WatermarkHistory struct {
+	ID          int64      `gorm:"primaryKey;column:id"`
+	Entity      string     `gorm:"column:entity"`
+	BranchID    string     `gorm:"column:branch_id"`
+	OldValue    *time.Time `gorm:"column:old_value"`
+	NewValue    *time.Time `gorm:"column:new_value"`
+	Source      string     `gorm:"column:source"`
+	RunID       *int64     `gorm:"column:run_id"`
+	JumpSeconds *int64     `gorm:"column:jump_seconds"`
+	FlagFuture  bool       `gorm:"column:flag_future"`
+	FlagJump    bool       `gorm:"column:flag_jump"`
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+}
+
+func (WatermarkHistory) TableName() string { return "core.watermark_history" }
+
+// Flagged reports whether the move looks suspicious.
+func (h WatermarkHistory) Flagged() bool { return h.FlagFuture || h.FlagJump }
+
+// recordWatermarkMove flags h against maxJump (<= 0 = no jump check) and
+// inserts it on db, which should be the transaction that moved the
+// watermark. Flagged moves are logged as well.
+func recordWatermarkMove(db *gorm.DB, lg *log.Logger, h *WatermarkHistory, maxJump time.Duration) error {
+	now := time.Now().UTC()
+	h.CreatedAt = now
+
+	if h.NewValue != nil {
+		h.FlagFuture = h.NewValue.After(now.Add(watermarkFutureSkew))
+		if h.OldValue != nil {
+			jump := h.NewValue.Sub(*h.OldValue)
+			secs := int64(jump / time.Second)
+			h.JumpSeconds = &secs
+			h.FlagJump = maxJump > 0 && jump > maxJump
+		}
+	}
+
+	if err := db.Create(h).Error; err != nil {
+		return err
+	}
+
+	if h.FlagFuture {
+		lg.Printf("🚩 Watermark %s/%s moved into the future: %s (now %s)",
+			h.Entity, h.BranchID, h.NewValue.UTC().Format(time.RFC3339), now.Format(time.RFC3339))
+	}
+	if h.FlagJump {
+		lg.Printf("🚩 Watermark %s/%s jumped %s in one move (%s → %s, threshold %s)",
+			h.Entity, h.BranchID, h.NewValue.Sub(*h.OldValue).Round(time.Second),
+			h.OldValue.UTC().Format(time.RFC3339), h.NewValue.UTC().Format(time.RFC3339), maxJump)
+	}
+	return nil
+}
+
+// WatermarkHistoryFilter narrows History; zero values match everything.
+type WatermarkHistoryFilter struct {
+	Entity      WatermarkEntity
+	BranchID    string
+	FlaggedOnly bool
+	Limit       int
+}
+
+// History returns watermark moves newest first.
+func (r *WatermarksRepo) History(f WatermarkHistoryFilter) ([]WatermarkHistory, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+
+	q := r.db.Model(&WatermarkHistory{}).Order("created_at DESC, id DESC").Limit(f.Limit)
+	if f.Entity != "" {
+		q = q.Where("entity = ?", f.Entity)
+	}
+	if f.BranchID != "" {
+		q = q.Where("branch_id = ?", f.BranchID)
+	}
+	if f.FlaggedOnly {
+		q = q.Where("flag_future OR flag_jump")
+	}
+
+	var rows []WatermarkHistory
+	err := q.Find(&rows).Error
+	return rows, err
+}
@@ -1,32 +1,75 @@
 package repos
 
 import (
+	"errors"
 	"log"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Manual watermark changes recorded in core.watermark_audit.
 const (
-	WatermarkWorktimetableRolling      = "worktimetable"
-	WatermarkWorktimetableBackfillDone = "worktimetable_backfill_done"
+	WatermarkActionSet    = "set"
+	WatermarkActionRewind = "rewind"
+	WatermarkActionReset  = "reset"
 )
 
-// WatermarksRepo provides access to the sync_watermarks table.
+// WatermarksRepo is the one way to read and move sync_watermarks rows. Keys
+// are (WatermarkEntity, branch ID) — or BusinessWideKey for business-wide
+// entities — and every move is recorded in core.watermark_history.
 type WatermarksRepo struct {
 	db *gorm.DB
 	lg *log.Logger
+
+	// History context for moves made through this repo.
+	source  string
+	runID   *int64
+	maxJump time.Duration
 }
 
 func NewWatermarksRepo(db *gorm.DB, lg *log.Logger) *WatermarksRepo {
-	return &WatermarksRepo{db: db, lg: lg}
+	return &WatermarksRepo{db: db, lg: lg, source: WatermarkSourceSync, maxJump: DefaultWatermarkMaxJump}
+}
+
+// ForRun returns a copy whose moves are attributed to sync run runID
+// (nil = none, e.g. the run's history row couldn't be written).
+func (r *WatermarksRepo) ForRun(runID *int64) *WatermarksRepo {
+	cp := *r
+	cp.runID = runID
+	return &cp
+}
+
+// WithMaxJump returns a copy that flags moves further than d; d <= 0 keeps
+// the current threshold.
+func (r *WatermarksRepo) WithMaxJump(d time.Duration) *WatermarksRepo {
+	cp := *r
+	if d > 0 {
+		cp.maxJump = d
+	}
+	return &cp
 }
 
-// SyncWatermark matches the *current* sync_watermarks schema.
+// ForBootstrap returns a copy whose moves are recorded as bootstrap seeding.
+func (r *WatermarksRepo) ForBootstrap() *WatermarksRepo {
+	cp := *r
+	cp.source, cp.runID = WatermarkSourceBootstrap, nil
+	return &cp
+}
+
+// WithTx returns a copy writing through tx, keeping the history context.
+func (r *WatermarksRepo) WithTx(tx *gorm.DB) *WatermarksRepo {
+	cp := *r
+	cp.db = tx
+	return &cp
+}
+
+// SyncWatermark is one sync_watermarks row.
 type SyncWatermark struct {
 	ID                 int64      `gorm:"primaryKey;column:id"`
-	Entity             string     `gorm:"column:entity"`               // e.g. "clients_csv", "transactions_csv"
-	BranchID           *string    `gorm:"column:branch_id"`            // NULL or branch id, or "ALL" for global
+	Entity             string     `gorm:"column:entity"`               // a WatermarkEntity (older rows may hold retired names)
+	BranchID           *string    `gorm:"column:branch_id"`            // branch ID or BusinessWideKey; NULL only in pre-0003 rows, read as "ALL"
 	LastUpdatedPhorest *time.Time `gorm:"column:last_updated_phorest"` // watermark
 	CreatedAt          time.Time  `gorm:"column:created_at"`
 	UpdatedAt          time.Time  `gorm:"column:updated_at"`
@@ -34,52 +77,90 @@ type SyncWatermark struct {
 
 func (SyncWatermark) TableName() string { return "sync_watermarks" }
 
-// GetLastUpdated returns the last_updated_phorest for (entity, branchID).
-// For global sources like clients, pass branchID = "ALL".
-func (r *WatermarksRepo) GetLastUpdated(entity, branchID string) (*time.Time, error) {
-	branchID = normaliseBranchID(branchID)
+// Branch returns the row's branch key, "ALL" for a NULL branch_id.
+func (wm SyncWatermark) Branch() string {
+	if wm.BranchID == nil || *wm.BranchID == "" {
+		return WatermarkAllBranches
+	}
+	return *wm.BranchID
+}
+
+// WatermarkAudit is one manual change made via `datahub watermarks`.
+// OldValue nil = there was no row; NewValue nil = the row was deleted.
+type WatermarkAudit struct {
+	ID        int64      `gorm:"primaryKey;column:id"`
+	Entity    string     `gorm:"column:entity"`
+	BranchID  string     `gorm:"column:branch_id"`
+	Action    string     `gorm:"column:action"`
+	OldValue  *time.Time `gorm:"column:old_value"`
+	NewValue  *time.Time `gorm:"column:new_value"`
+	Actor     string     `gorm:"column:actor"`
+	Reason    string     `gorm:"column:reason"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+}
 
-	var wm SyncWatermark
-	err := r.db.
-		Where("entity = ? AND branch_id = ?", entity, branchID).
-		First(&wm).Error
+func (WatermarkAudit) TableName() string { return "core.watermark_audit" }
 
-	if err == gorm.ErrRecordNotFound {
-		return nil, nil
-	}
-	if err != nil {
+// GetLastUpdated returns the watermark for (entity, branchID), nil if unset.
+// Business-wide entities take BusinessWideKey ("" means "ALL").
+func (r *WatermarksRepo) GetLastUpdated(entity WatermarkEntity, branchID string) (*time.Time, error) {
+	wm, err := r.Get(entity, branchID)
+	if err != nil || wm == nil {
 		return nil, err
 	}
 	return wm.LastUpdatedPhorest, nil
 }
 
-// UpsertLastUpdated advances the watermark for (entity, branchID) if candidate is newer.
-// For global sources like clients, pass branchID = "ALL" (or "") – "" will be normalised.
-func (r *WatermarksRepo) UpsertLastUpdated(entity, branchID string, candidate time.Time) error {
+// UpsertLastUpdated advances the watermark for (entity, branchID) if
+// candidate is newer; it never moves one backwards (see SetLastUpdated).
+func (r *WatermarksRepo) UpsertLastUpdated(entity WatermarkEntity, branchID string, candidate time.Time) error {
 	if candidate.IsZero() {
 		return nil
 	}
 
-	branchID = normaliseBranchID(branchID)
+	branchID, err := entity.branchKey(branchID)
+	if err != nil {
+		return err
+	}
+	candidate = candidate.UTC()
 
 	r.lg.Printf("💾 Updating watermark for %s/%s → %s",
-		entity, branchID, candidate.UTC().Format(time.RFC3339))
+		entity, branchID, candidate.Format(time.RFC3339))
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockedLastUpdated(tx, entity, branchID)
+		if err != nil {
+			return err
+		}
 
-	return r.db.Exec(`
+		if err := tx.Exec(`
 INSERT INTO sync_watermarks (entity, branch_id, last_updated_phorest, created_at, updated_at)
 VALUES (?, ?, ?, now(), now())
 ON CONFLICT (entity, branch_id) DO UPDATE
 SET last_updated_phorest = GREATEST(sync_watermarks.last_updated_phorest, EXCLUDED.last_updated_phorest),
     updated_at           = now();
-`, entity, branchID, candidate.UTC()).Error
+`, entity, branchID, candidate).Error; err != nil {
+			return err
+		}
+
+		// GREATEST kept the old value: nothing moved, nothing to record.
+		if old != nil && !candidate.After(*old) {
+			return nil
+		}
+		return r.recordMove(tx, entity, branchID, old, &candidate)
+	})
 }
 
-func normaliseBranchID(branchID string) string {
-	// Canonical "global" key
-	if branchID == "" {
-		return "ALL"
-	}
-	return branchID
+// recordMove writes the history row for a move made through this repo.
+func (r *WatermarksRepo) recordMove(tx *gorm.DB, entity WatermarkEntity, branchID string, old, new *time.Time) error {
+	return recordWatermarkMove(tx, r.lg, &WatermarkHistory{
+		Entity:   string(entity),
+		BranchID: branchID,
+		OldValue: old,
+		NewValue: new,
+		Source:   r.source,
+		RunID:    r.runID,
+	}, r.maxJump)
 }
 
 func (r *WatermarksRepo) GetWorktimetableBackfillDone(branchID string) (*time.Time, error) {
@@ -89,3 +170,142 @@ func (r *WatermarksRepo) GetWorktimetableBackfillDone(branchID string) (*time.Ti
 func (r *WatermarksRepo) MarkWorktimetableBackfillDone(branchID string, doneAt time.Time) error {
 	return r.UpsertLastUpdated(WatermarkWorktimetableBackfillDone, branchID, doneAt)
 }
+
+// List returns every watermark row ordered by entity, branch.
+func (r *WatermarksRepo) List() ([]SyncWatermark, error) {
+	var rows []SyncWatermark
+	err := r.db.
+		Order("entity ASC, branch_id ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// Get returns the row for (entity, branchID), or nil if there is none.
+func (r *WatermarksRepo) Get(entity WatermarkEntity, branchID string) (*SyncWatermark, error) {
+	branchID, err := entity.branchKey(branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	var wm SyncWatermark
+	err = r.db.
+		Where("entity = ? AND branch_id = ?", entity, branchID).
+		First(&wm).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wm, nil
+}
+
+// SetLastUpdated overwrites the watermark for (entity, branchID) with value —
+// unlike UpsertLastUpdated it may move it backwards — and records the change
+// in core.watermark_audit in the same transaction. audit supplies Action,
+// Actor and Reason; the rest is filled in and returned.
+func (r *WatermarksRepo) SetLastUpdated(entity WatermarkEntity, branchID string, value time.Time, audit WatermarkAudit) (*WatermarkAudit, error) {
+	branchID, err := entity.branchKey(branchID)
+	if err != nil {
+		return nil, err
+	}
+	value = value.UTC()
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockedLastUpdated(tx, entity, branchID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`
+INSERT INTO sync_watermarks (entity, branch_id, last_updated_phorest, created_at, updated_at)
+VALUES (?, ?, ?, now(), now())
+ON CONFLICT (entity, branch_id) DO UPDATE
+SET last_updated_phorest = EXCLUDED.last_updated_phorest,
+    updated_at           = now();
+`, entity, branchID, value).Error; err != nil {
+			return err
+		}
+
+		audit.Entity, audit.BranchID = string(entity), branchID
+		audit.OldValue, audit.NewValue = old, &value
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+		return r.adminMove(tx, entity, branchID, old, &value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.lg.Printf("✏️  Watermark %s/%s %s → %s by %s",
+		entity, branchID, fmtWatermark(audit.OldValue), value.Format(time.RFC3339), audit.Actor)
+	return &audit, nil
+}
+
+// Reset deletes the watermark for (entity, branchID), so the next run behaves
+// as if it had never synced, and records the change in core.watermark_audit.
+func (r *WatermarksRepo) Reset(entity WatermarkEntity, branchID string, audit WatermarkAudit) (*WatermarkAudit, error) {
+	branchID, err := entity.branchKey(branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockedLastUpdated(tx, entity, branchID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.
+			Where("entity = ? AND branch_id = ?", entity, branchID).
+			Delete(&SyncWatermark{}).Error; err != nil {
+			return err
+		}
+
+		audit.Entity, audit.BranchID = string(entity), branchID
+		audit.OldValue, audit.NewValue = old, nil
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+		return r.adminMove(tx, entity, branchID, old, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.lg.Printf("🗑  Watermark %s/%s reset (was %s) by %s",
+		entity, branchID, fmtWatermark(audit.OldValue), audit.Actor)
+	return &audit, nil
+}
+
+// adminMove records a manual change in the history alongside its audit row.
+func (r *WatermarksRepo) adminMove(tx *gorm.DB, entity WatermarkEntity, branchID string, old, new *time.Time) error {
+	cp := *r
+	cp.source, cp.runID = WatermarkSourceAdmin, nil
+	return cp.recordMove(tx, entity, branchID, old, new)
+}
+
+// lockedLastUpdated reads the current value FOR UPDATE so a sync finishing
+// concurrently can't slip in between the read and the audit row.
+func lockedLastUpdated(tx *gorm.DB, entity WatermarkEntity, branchID string) (*time.Time, error) {
+	var wm SyncWatermark
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("entity = ? AND branch_id = ?", entity, branchID).
+		First(&wm).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return wm.LastUpdatedPhorest, nil
+}
+
+func fmtWatermark(t *time.Time) string {
+	if t == nil {
+		return "(none)"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Job is one independently scheduled unit of work.
+type Job struct {
+	Name     string
+	Schedule cron.Schedule
+	Jitter   time.Duration // random delay [0, Jitter) added to each tick
+	Timeout  time.Duration // per-run timeout (0 = none)
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs each Job on its own schedule. Every job gets a dedicated
+// goroutine that runs sequentially, so a job can never overlap itself: if a
+// run overshoots the next tick, missed ticks are skipped, not queued.
+type Scheduler struct {
+	Logger *log.Logger
+	jobs   []Job
+}
+
+func New(lg *log.Logger) *Scheduler {
+	return &Scheduler{Logger: lg}
+}
+
+// ParseSchedule accepts standard 5-field cron specs plus descriptors such as
+// "@hourly" and "@every 15m".
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	s, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return s, nil
+}
+
+func (s *Scheduler) Add(j Job) {
+	s.jobs = append(s.jobs, j)
+}
+
+// Run blocks until ctx is cancelled, then waits for in-flight runs to return.
+// Running jobs see the cancellation through the ctx they were given.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j Job) {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+
+	<-ctx.Done()
+	s.Logger.Printf("🛑 scheduler: shutdown requested, waiting for running jobs…")
+	wg.Wait()
+	s.Logger.Printf("👋 scheduler: all jobs stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context, j Job) {
+	for {
+		now := time.Now()
+		next := j.Schedule.Next(now)
+		if j.Jitter > 0 {
+			next = next.Add(rand.N(j.Jitter))
+		}
+
+		s.Logger.Printf("⏰ scheduler/%s: next run at %s", j.Name, next.UTC().Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.runOnce(ctx, j)
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, j Job) {
+	runCtx := ctx
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	// A panicking sync must not take the daemon down with it.
+	defer func() {
+		if p := recover(); p != nil {
+			s.Logger.Printf("💥 scheduler/%s: panic: %v", j.Name, p)
+		}
+	}()
+
+	start := time.Now()
+	s.Logger.Printf("🚀 scheduler/%s: starting", j.Name)
+
+	if err := j.Run(runCtx); err != nil {
+		s.Logger.Printf("❌ scheduler/%s: failed after %s: %v", j.Name, time.Since(start).Round(time.Second), err)
+		return
+	}
+	s.Logger.Printf("✅ scheduler/%s: finished in %s", j.Name, time.Since(start).Round(time.Second))
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// StockAdjustmentDispatcher posts a hub's pending core.stock_adjustment_outbox
+// rows to Phorest: one request per branch and operation, DEDUCTs first.
+// Each group is marked 'sending' before the call and 'sent' / 'failed'
+// after it, so a sent row is never posted again. Rows are only 'failed'
+// when Phorest certainly didn't apply them (ErrStockNotAdjusted); a call
+// with no clear answer, or a crash mid-call, leaves them in 'sending' for a
+// human to check rather than retrying them blindly.
+type StockAdjustmentDispatcher struct {
+	Repo     repos.StockAdjustmentOutboxRepo
+	Adjuster StockAdjuster
+	Logger   *log.Logger
+}
+
+// DispatchResult counts outbox rows by outcome.
+type DispatchResult struct {
+	Sent    int
+	Failed  int
+	Unknown int // posted without a clear answer; left in 'sending'
+	Skipped int // claimed by someone else between read and post
+}
+
+func (d StockAdjustmentDispatcher) lg() *log.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return log.Default()
+}
+
+// outboxGroup is the rows posted together in one AdjustStock call.
+type outboxGroup struct {
+	branchID string
+	op       string
+	rows     []repos.StockAdjustmentOutboxRow
+}
+
+// Dispatch posts every pending row of hubBranchID. A failed or unanswered
+// group is recorded and the rest still go; the error then says how many
+// rows need attention. Once ctx ends, the remaining groups stay pending.
+func (d StockAdjustmentDispatcher) Dispatch(ctx context.Context, hubBranchID string) (DispatchResult, error) {
+	var res DispatchResult
+	if d.Adjuster == nil {
+		return res, fmt.Errorf("refusing to dispatch: Adjuster is nil")
+	}
+
+	pending, err := d.Repo.Pending(ctx, hubBranchID)
+	if err != nil {
+		return res, fmt.Errorf("read outbox: %w", err)
+	}
+	if len(pending) == 0 {
+		return res, nil
+	}
+
+	var unknownIDs []int64
+	for _, g := range groupOutbox(pending) {
+		if ctx.Err() != nil {
+			break
+		}
+		ids := make([]int64, len(g.rows))
+		agg := make(map[string]int)
+		for i, r := range g.rows {
+			ids[i] = r.ID
+			agg[r.Barcode] += r.Quantity
+		}
+
+		claimed, err := d.Repo.MarkSending(ctx, ids)
+		if err != nil {
+			return res, err
+		}
+		if !claimed {
+			d.lg().Printf("[stockrecon] outbox: %s branch=%s rows=%v no longer pending, skipping", g.op, g.branchID, ids)
+			res.Skipped += len(ids)
+			continue
+		}
+
+		req := buildRequest(agg, g.op)
+		d.lg().Printf("[stockrecon] LIVE: POST %s branch=%s lines=%d (outbox rows=%d)", g.op, g.branchID, len(req.Stocks), len(ids))
+
+		if postErr := d.Adjuster.AdjustStock(ctx, g.branchID, req); postErr != nil {
+			// Use a fresh context: the post may have failed because ctx ended.
+			if !errors.Is(postErr, ErrStockNotAdjusted) {
+				// Phorest may have applied it: posting again could move the
+				// stock twice.
+				d.lg().Printf("[stockrecon] outbox: %s branch=%s outcome unknown, rows %v left in 'sending': %v", g.op, g.branchID, ids, postErr)
+				if err := d.Repo.NoteSendError(context.WithoutCancel(ctx), ids, postErr.Error()); err != nil {
+					return res, err
+				}
+				res.Unknown += len(ids)
+				unknownIDs = append(unknownIDs, ids...)
+				continue
+			}
+			d.lg().Printf("[stockrecon] outbox: %s branch=%s failed: %v", g.op, g.branchID, postErr)
+			if err := d.Repo.MarkFailed(context.WithoutCancel(ctx), ids, postErr.Error()); err != nil {
+				return res, err
+			}
+			res.Failed += len(ids)
+			continue
+		}
+		if err := d.Repo.MarkSent(context.WithoutCancel(ctx), ids); err != nil {
+			return res, fmt.Errorf("posted %s branch=%s but could not mark rows %v sent (left in 'sending'): %w",
+				g.op, g.branchID, ids, err)
+		}
+		res.Sent += len(ids)
+	}
+
+	var problems []string
+	if res.Unknown > 0 {
+		problems = append(problems, fmt.Sprintf(
+			"%d outbox row(s) may or may not have reached Phorest, left in 'sending' (check Phorest, then datahub stock outbox retry --id %s only if they weren't applied)",
+			res.Unknown, joinIDs(unknownIDs)))
+	}
+	if res.Failed > 0 {
+		problems = append(problems, fmt.Sprintf("%d outbox row(s) failed to post (see datahub stock outbox list --status failed)", res.Failed))
+	}
+	if len(problems) > 0 {
+		return res, errors.New(strings.Join(problems, "; "))
+	}
+	return res, ctx.Err()
+}
+
+func joinIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(s, ",")
+}
+
+// groupOutbox groups rows by operation and branch: DEDUCT groups first,
+// then INCREASE, each sorted by branch.
+func groupOutbox(rows []repos.StockAdjustmentOutboxRow) []outboxGroup {
+	type key struct{ op, branch string }
+	byKey := map[key]*outboxGroup{}
+	var groups []*outboxGroup
+	for _, r := range rows {
+		k := key{r.Operation, r.BranchID}
+		g, ok := byKey[k]
+		if !ok {
+			g = &outboxGroup{branchID: r.BranchID, op: r.Operation}
+			byKey[k] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, r)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].op != groups[j].op {
+			return groups[i].op == "DEDUCT"
+		}
+		return groups[i].branchID < groups[j].branchID
+	})
+
+	out := make([]outboxGroup, len(groups))
+	for i, g := range groups {
+		out[i] = *g
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package services_test
+
+// An external test package: internal/e2e imports services (through phorest).
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/e2e"
+	"github.com/araquach/phorest-datahub/internal/repos"
+	"github.com/araquach/phorest-datahub/internal/services"
+)
+
+// adjusterFunc answers each AdjustStock call by branch.
+type adjusterFunc func(branchID string) error
+
+func (f adjusterFunc) AdjustStock(_ context.Context, branchID string, _ services.StockAdjustmentRequest) error {
+	return f(branchID)
+}
+
+// A post that times out may have reached Phorest: its rows must stay in
+// 'sending', out of reach of a bare retry, while a post Phorest turned down
+// is 'failed' and retried.
+func TestDispatchLeavesUnansweredPostsSending(t *testing.T) {
+	db := e2e.NewTestDB(t)
+	ctx := context.Background()
+	repo := repos.StockAdjustmentOutboxRepo{DB: db}
+
+	if _, err := db.Exec(`
+INSERT INTO core.stock_adjustment_outbox (id, hub_branch_id, branch_id, barcode, quantity, operation, source_item_ids)
+VALUES (1, 'pk', 'pk',   'B1', 2, 'DEDUCT',   ARRAY['s1']),
+       (2, 'pk', 'base', 'B1', 1, 'INCREASE', ARRAY['s1']),
+       (3, 'pk', 'jk',   'B1', 1, 'INCREASE', ARRAY['s1'])`); err != nil {
+		t.Fatal(err)
+	}
+
+	d := services.StockAdjustmentDispatcher{
+		Repo: repo,
+		Adjuster: adjusterFunc(func(branchID string) error {
+			switch branchID {
+			case "pk":
+				return fmt.Errorf("request failed: %w", context.DeadlineExceeded)
+			case "base":
+				return fmt.Errorf("%w: status=400 Bad Request", services.ErrStockNotAdjusted)
+			}
+			return nil
+		}),
+		Logger: e2e.TestLogger(t),
+	}
+
+	res, err := d.Dispatch(ctx, "pk")
+	if want := (services.DispatchResult{Sent: 1, Failed: 1, Unknown: 1}); res != want {
+		t.Fatalf("Dispatch() = %+v, want %+v", res, want)
+	}
+	if err == nil || !strings.Contains(err.Error(), "retry --id 1 ") {
+		t.Fatalf("Dispatch() error = %v, want it to point at row 1", err)
+	}
+
+	rows, err := repo.List(ctx, repos.StockAdjustmentOutboxFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int64]string{}
+	for _, r := range rows {
+		got[r.ID] = r.Status
+		if r.ID == 1 && !strings.Contains(r.LastError.String, "deadline exceeded") {
+			t.Errorf("row 1 last_error = %q, want the timeout", r.LastError.String)
+		}
+	}
+	if want := map[int64]string{1: repos.OutboxSending, 2: repos.OutboxFailed, 3: repos.OutboxSent}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+
+	// A bare retry only picks up the row Phorest turned down.
+	byHub, err := repo.Requeue(ctx, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"pk": 1}; !reflect.DeepEqual(byHub, want) {
+		t.Fatalf("Requeue() = %v, want %v", byHub, want)
+	}
+	pending, err := repo.Pending(ctx, "pk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != 2 {
+		t.Fatalf("pending after retry = %+v, want only row 2", pending)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func TestGroupOutbox(t *testing.T) {
+	row := func(id int64, op, branch, barcode string) repos.StockAdjustmentOutboxRow {
+		return repos.StockAdjustmentOutboxRow{ID: id, Operation: op, BranchID: branch, Barcode: barcode, Quantity: 1}
+	}
+	ids := func(groups []outboxGroup) [][]int64 {
+		out := make([][]int64, len(groups))
+		for i, g := range groups {
+			for _, r := range g.rows {
+				out[i] = append(out[i], r.ID)
+			}
+		}
+		return out
+	}
+	keys := func(groups []outboxGroup) []string {
+		out := make([]string, len(groups))
+		for i, g := range groups {
+			out[i] = g.op + " " + g.branchID
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		rows     []repos.StockAdjustmentOutboxRow
+		wantKeys []string
+		wantIDs  [][]int64
+	}{
+		{
+			name:     "empty",
+			wantKeys: []string{},
+			wantIDs:  [][]int64{},
+		},
+		{
+			name: "DEDUCTs go before INCREASEs whatever the row order",
+			rows: []repos.StockAdjustmentOutboxRow{
+				row(1, "INCREASE", "base", "B1"),
+				row(2, "DEDUCT", "pk", "B1"),
+			},
+			wantKeys: []string{"DEDUCT pk", "INCREASE base"},
+			wantIDs:  [][]int64{{2}, {1}},
+		},
+		{
+			name: "one group per operation and branch, sorted by branch",
+			rows: []repos.StockAdjustmentOutboxRow{
+				row(1, "DEDUCT", "pk", "B1"),
+				row(2, "DEDUCT", "jakata", "B1"),
+				row(3, "INCREASE", "pk", "B2"),
+				row(4, "DEDUCT", "pk", "B2"),
+				row(5, "INCREASE", "base", "B1"),
+			},
+			wantKeys: []string{"DEDUCT jakata", "DEDUCT pk", "INCREASE base", "INCREASE pk"},
+			wantIDs:  [][]int64{{2}, {1, 4}, {5}, {3}},
+		},
+		{
+			name: "rows keep their order within a group",
+			rows: []repos.StockAdjustmentOutboxRow{
+				row(7, "DEDUCT", "pk", "B2"),
+				row(3, "DEDUCT", "pk", "B1"),
+				row(9, "DEDUCT", "pk", "B1"),
+			},
+			wantKeys: []string{"DEDUCT pk"},
+			wantIDs:  [][]int64{{7, 3, 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupOutbox(tt.rows)
+			if k := keys(got); !reflect.DeepEqual(k, tt.wantKeys) {
+				t.Errorf("groups = %v, want %v", k, tt.wantKeys)
+			}
+			if i := ids(got); !reflect.DeepEqual(i, tt.wantIDs) {
+				t.Errorf("row IDs = %v, want %v", i, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -4,19 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/araquach/phorest-datahub/internal/db"
 	"github.com/araquach/phorest-datahub/internal/repos"
 )
 
+// StockAdjuster posts stock adjustments to Phorest. An error wrapping
+// ErrStockNotAdjusted means the adjustment certainly wasn't applied; any
+// other error (timeout, cancelled context, dropped connection) leaves that
+// unknown.
 type StockAdjuster interface {
 	AdjustStock(ctx context.Context, branchID string, req StockAdjustmentRequest) error
 }
 
+// ErrStockNotAdjusted marks an AdjustStock error where Phorest did not
+// apply the adjustment: the request was never sent, or Phorest turned it
+// down, so it is safe to post again.
+var ErrStockNotAdjusted = errors.New("stock not adjusted")
+
 type StockAdjustmentItem struct {
 	Barcode       string `json:"barcode"`
 	Quantity      int    `json:"quantity"`
@@ -32,6 +43,19 @@ type BranchPayload struct {
 	Req      StockAdjustmentRequest
 }
 
+// StockHub is one business's stock hub branch plus the adjuster that posts
+// to that business.
+type StockHub struct {
+	BusinessID string
+	BranchID   string
+	Adjuster   StockAdjuster // nil in dry-run
+
+	// From, when after the run's FromTS, is where this hub's reconcile
+	// starts instead: a branch that is a hub only through stock source rules
+	// starts when its first rule was added, not at the cut-over date.
+	From time.Time
+}
+
 type StockReconcileService struct {
 	Repo repos.StockReconcileRepo
 
@@ -40,8 +64,12 @@ type StockReconcileService struct {
 	Logger *log.Logger
 
 	// Config
-	PKBranchID string
-	DryRun     bool // dry-run logs only (no Phorest calls, no DB marks)
+	HubBranchID string
+	DryRun      bool // dry-run logs only (no Phorest calls, no DB marks)
+
+	// Hubs, when set, reconciles each business's hub in turn instead of the
+	// single HubBranchID / Adjuster pair.
+	Hubs []StockHub
 
 	// Run limits
 	FromTS time.Time
@@ -54,6 +82,10 @@ type StockReconcileService struct {
 	// Logging controls
 	MaxPreview int  // how many stock lines to preview per payload
 	PrintJSON  bool // print full JSON payloads
+
+	// LockWait is how long a live run waits for another live run on the same
+	// hub to finish before giving up with db.ErrLockHeld. 0 = don't wait.
+	LockWait time.Duration
 }
 
 func (s StockReconcileService) lg() *log.Logger {
@@ -64,8 +96,45 @@ func (s StockReconcileService) lg() *log.Logger {
 }
 
 func (s StockReconcileService) Run(ctx context.Context) error {
-	if s.PKBranchID == "" {
-		return fmt.Errorf("PKBranchID is required")
+	if len(s.Hubs) > 0 {
+		return s.runHubs(ctx)
+	}
+	return s.runHub(ctx)
+}
+
+// runHubs runs each hub independently: one failing or locked business
+// doesn't stop the others. Returns db.ErrLockHeld only if every hub was
+// skipped that way.
+func (s StockReconcileService) runHubs(ctx context.Context) error {
+	var errs []error
+	skipped := 0
+	for _, h := range s.Hubs {
+		hs := s
+		hs.Hubs = nil
+		hs.HubBranchID = h.BranchID
+		hs.Adjuster = h.Adjuster
+		if h.From.After(hs.FromTS) {
+			hs.FromTS = h.From
+		}
+
+		s.lg().Printf("[stockrecon] business=%s hub=%s from=%s", h.BusinessID, h.BranchID, hs.FromTS.Format(time.RFC3339))
+		err := hs.runHub(ctx)
+		switch {
+		case errors.Is(err, db.ErrLockHeld):
+			skipped++
+		case err != nil:
+			errs = append(errs, fmt.Errorf("business %s hub %s: %w", h.BusinessID, h.BranchID, err))
+		}
+	}
+	if len(errs) == 0 && skipped == len(s.Hubs) {
+		return db.ErrLockHeld
+	}
+	return errors.Join(errs...)
+}
+
+func (s StockReconcileService) runHub(ctx context.Context) error {
+	if s.HubBranchID == "" {
+		return fmt.Errorf("HubBranchID is required")
 	}
 	if s.Limit <= 0 {
 		s.Limit = 500
@@ -80,6 +149,52 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 		s.ToTS = time.Now()
 	}
 
+	// Two live runs would both read the same unprocessed rows and double-post
+	// the adjustments to Phorest, so hold an advisory lock for the whole run.
+	if !s.DryRun {
+		lock, err := db.TryAdvisoryLock(ctx, s.Repo.DB, "stock_reconcile:"+s.HubBranchID, s.LockWait)
+		if errors.Is(err, db.ErrLockHeld) {
+			s.lg().Printf("[stockrecon] hub=%s already running in another process, skipping", s.HubBranchID)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("stock reconcile lock: %w", err)
+		}
+		defer func() {
+			if err := lock.Unlock(); err != nil {
+				s.lg().Printf("[stockrecon] %v", err)
+			}
+		}()
+	}
+
+	// ---- LIVE: post anything a previous run queued but didn't get to ----
+	if !s.DryRun {
+		if s.Adjuster == nil {
+			return fmt.Errorf("refusing LIVE run: Adjuster is nil")
+		}
+		if err := s.dispatch(ctx); err != nil {
+			return err
+		}
+	}
+
+	// ---- Let exceptions whose cause has been fixed through ----
+	if err := s.reevaluateExceptions(ctx); err != nil {
+		return err
+	}
+
+	// ---- Undo transfers of sales voided / refunded since ----
+	if err := s.runReversals(ctx); err != nil {
+		return err
+	}
+
+	// ---- Which branch each sale's stock comes from ----
+	ruleRepo := repos.StockSourceRuleRepo{DB: s.Repo.DB}
+	rules, err := ruleRepo.List(ctx, s.HubBranchID, true)
+	if err != nil {
+		return fmt.Errorf("load stock source rules: %w", err)
+	}
+	s.logRules(rules)
+
 	totalRows := 0
 	totalMapped := 0
 	totalUnmapped := 0
@@ -88,9 +203,9 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 	batches := 0
 
 	for {
-		rows, err := s.Repo.FetchUnprocessedPKItems(ctx, s.PKBranchID, s.FromTS, s.ToTS, s.Limit, s.TestBarcode)
+		rows, err := s.Repo.FetchUnprocessedHubSales(ctx, s.HubBranchID, s.FromTS, s.ToTS, s.Limit, s.TestBarcode)
 		if err != nil {
-			return fmt.Errorf("fetch pk items: %w", err)
+			return fmt.Errorf("fetch hub sales: %w", err)
 		}
 
 		if len(rows) == 0 {
@@ -108,11 +223,12 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 
 		// Split into:
 		// 1) missingBarcode -> exception (can't call Phorest API without barcode)
-		// 2) unmappedStaff  -> exception (no physical branch override)
+		// 2) unmappedStaff  -> exception (stock comes from the stylist's
+		//                     physical branch, but they have no override)
 		// 3) mapped         -> normal processing
-		var mapped []repos.PKStockRow
-		var unmappedStaff []repos.PKStockRow
-		var missingBarcode []repos.PKStockRow
+		var mapped []plannedSale
+		var unmappedStaff []repos.HubSaleRow
+		var missingBarcode []repos.HubSaleRow
 
 		for _, r := range rows {
 			// Missing barcode (or whitespace) -> exception
@@ -121,14 +237,32 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 				continue
 			}
 
-			// Unmapped staff override -> exception
-			if !r.PhysicalBranchID.Valid || strings.TrimSpace(r.PhysicalBranchID.String) == "" {
+			// No branch to take the stock from -> exception
+			from := s.sourceFor(rules, r)
+			if from.BranchID == "" {
 				unmappedStaff = append(unmappedStaff, r)
 				continue
 			}
 
 			// Fully valid
-			mapped = append(mapped, r)
+			mapped = append(mapped, plannedSale{HubSaleRow: r, From: from})
+		}
+		s.logSourceDecisions(mapped)
+
+		// Barcodes filled in from the product catalogue
+		resolved := make(map[string]int) // source -> rows
+		for _, r := range mapped {
+			if r.BarcodeSource != repos.BarcodeFromItem {
+				resolved[r.BarcodeSource]++
+			}
+		}
+		if len(resolved) > 0 {
+			sources := make([]string, 0, len(resolved))
+			for src, n := range resolved {
+				sources = append(sources, fmt.Sprintf("%s=%d", src, n))
+			}
+			sort.Strings(sources)
+			s.lg().Printf("[stockrecon] barcodes resolved from product catalogue: %s", strings.Join(sources, " "))
 		}
 
 		totalMapped += len(mapped)
@@ -166,17 +300,25 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 			totalExceptions += len(unmappedStaff)
 		}
 
-		// Aggregate (mapped only)
-		deductAgg := make(map[string]map[string]int) // physical_branch_id -> barcode -> qty
-		increaseAgg := make(map[string]int)          // barcode -> qty
+		// Aggregate (mapped only; stock already at the hub doesn't move)
+		deductAgg := make(map[string]map[string]int)        // source branch -> barcode -> qty
+		increaseAgg := make(map[string]int)                 // barcode -> qty
+		deductItems := make(map[string]map[string][]string) // source branch -> barcode -> item IDs
+		increaseItems := make(map[string][]string)          // barcode -> item IDs
 
 		for _, r := range mapped {
-			branch := r.PhysicalBranchID.String
+			branch := r.From.BranchID
+			if branch == s.HubBranchID {
+				continue
+			}
 			if _, ok := deductAgg[branch]; !ok {
 				deductAgg[branch] = make(map[string]int)
+				deductItems[branch] = make(map[string][]string)
 			}
 			deductAgg[branch][r.Barcode] += r.Quantity
 			increaseAgg[r.Barcode] += r.Quantity
+			deductItems[branch][r.Barcode] = append(deductItems[branch][r.Barcode], r.TransactionItemID)
+			increaseItems[r.Barcode] = append(increaseItems[r.Barcode], r.TransactionItemID)
 		}
 
 		// Build payloads
@@ -189,8 +331,8 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 		}
 		sort.Slice(deductPayloads, func(i, j int) bool { return deductPayloads[i].BranchID < deductPayloads[j].BranchID })
 
-		pkIncrease := BranchPayload{
-			BranchID: s.PKBranchID,
+		hubIncrease := BranchPayload{
+			BranchID: s.HubBranchID,
 			Req:      buildRequest(increaseAgg, "INCREASE"),
 		}
 
@@ -251,11 +393,11 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 		}
 
 		// Increase payload preview
-		lines, total := payloadStats(pkIncrease.Req)
-		s.lg().Printf("[stockrecon] would POST INCREASE branch=%s lines=%d total_qty=%d", pkIncrease.BranchID, lines, total)
-		printPreview(s.lg(), pkIncrease.Req, s.MaxPreview)
+		lines, total := payloadStats(hubIncrease.Req)
+		s.lg().Printf("[stockrecon] would POST INCREASE branch=%s lines=%d total_qty=%d", hubIncrease.BranchID, lines, total)
+		printPreview(s.lg(), hubIncrease.Req, s.MaxPreview)
 		if s.PrintJSON {
-			printJSON(s.lg(), "INCREASE PK", pkIncrease.Req)
+			printJSON(s.lg(), fmt.Sprintf("INCREASE hub=%s", hubIncrease.BranchID), hubIncrease.Req)
 		}
 
 		// ---- STOP HERE IN DRY RUN ----
@@ -267,53 +409,97 @@ func (s StockReconcileService) Run(ctx context.Context) error {
 		}
 
 		// ---- LIVE MODE GUARDS ----
-		if s.Adjuster == nil {
-			return fmt.Errorf("refusing LIVE run: Adjuster is nil")
-		}
 		if len(unmappedStaff)+len(missingBarcode) > 0 {
 			s.lg().Printf("[stockrecon] LIVE: continuing with mapped rows; %d rows were exceptioned", len(unmappedStaff)+len(missingBarcode))
 		}
 
-		// ---- LIVE: POST DEDUCT payloads ----
+		// ---- LIVE: plan (transfers + outbox) in one transaction ----
+		var outbox []repos.StockAdjustmentOutboxRow
 		for _, p := range deductPayloads {
-			if len(p.Req.Stocks) == 0 {
-				continue
-			}
-			s.lg().Printf("[stockrecon] LIVE: POST DEDUCT branch=%s lines=%d", p.BranchID, len(p.Req.Stocks))
-			if err := s.Adjuster.AdjustStock(ctx, p.BranchID, p.Req); err != nil {
-				return fmt.Errorf("live deduct failed branch=%s: %w", p.BranchID, err)
-			}
+			outbox = append(outbox, s.outboxRows(p, deductItems[p.BranchID])...)
 		}
+		outbox = append(outbox, s.outboxRows(hubIncrease, increaseItems)...)
 
-		// ---- LIVE: POST INCREASE to PK ----
-		if len(pkIncrease.Req.Stocks) > 0 {
-			s.lg().Printf("[stockrecon] LIVE: POST INCREASE branch=%s lines=%d", pkIncrease.BranchID, len(pkIncrease.Req.Stocks))
-			if err := s.Adjuster.AdjustStock(ctx, pkIncrease.BranchID, pkIncrease.Req); err != nil {
-				return fmt.Errorf("live increase failed branch=%s: %w", pkIncrease.BranchID, err)
-			}
-		}
-
-		// ---- LIVE: record processed items (mapped only) ----
 		transferRows := make([]repos.StockVirtualTransferRow, 0, len(mapped))
 		for _, r := range mapped {
 			transferRows = append(transferRows, repos.StockVirtualTransferRow{
 				TransactionItemID: r.TransactionItemID,
-				FromBranchID:      r.PhysicalBranchID.String,
-				ToBranchID:        s.PKBranchID,
+				FromBranchID:      r.From.BranchID,
+				ToBranchID:        s.HubBranchID,
 				Barcode:           r.Barcode,
+				BarcodeSource:     r.BarcodeSource,
 				Quantity:          r.Quantity,
+				RuleID:            r.From.RuleID,
 			})
 		}
 
-		if err := s.Repo.InsertStockVirtualTransfers(ctx, transferRows); err != nil {
-			return fmt.Errorf("insert stock_virtual_transfers failed: %w", err)
+		if err := s.Repo.PlanStockAdjustments(ctx, outbox, transferRows); err != nil {
+			return fmt.Errorf("plan stock adjustments failed: %w", err)
 		}
-
 		totalTransfers += len(transferRows)
-		s.lg().Printf("[stockrecon] LIVE batch=%d complete: recorded %d transfers", batches, len(transferRows))
+		s.lg().Printf("[stockrecon] LIVE batch=%d planned: recorded %d transfers, queued %d adjustments", batches, len(transferRows), len(outbox))
+
+		// ---- LIVE: post the queued adjustments ----
+		if err := s.dispatch(ctx); err != nil {
+			return err
+		}
 
-		// loop continues: next FetchUnprocessedPKItems will exclude transfers + exceptions
+		// loop continues: next FetchUnprocessedHubSales will exclude transfers + exceptions
+	}
+}
+
+// reevaluateExceptions resolves the hub's open exceptions whose staff
+// override, product or item changed since they were last checked and now
+// look processable, so this run picks them up. Dry runs only report.
+func (s StockReconcileService) reevaluateExceptions(ctx context.Context) error {
+	repo := repos.StockExceptionRepo{DB: s.Repo.DB}
+	res, err := repo.Reevaluate(ctx, repos.StockExceptionFilter{HubBranchID: s.HubBranchID}, false, !s.DryRun)
+	if err != nil {
+		return err
+	}
+	if res.Resolved+res.StillOpen > 0 {
+		verb := "resolved"
+		if s.DryRun {
+			verb = "would resolve"
+		}
+		s.lg().Printf("[stockrecon] exceptions re-checked: %s %d, still open %d", verb, res.Resolved, res.StillOpen)
+	}
+	return nil
+}
+
+// dispatch posts the hub's pending outbox rows.
+func (s StockReconcileService) dispatch(ctx context.Context) error {
+	d := StockAdjustmentDispatcher{
+		Repo:     repos.StockAdjustmentOutboxRepo{DB: s.Repo.DB},
+		Adjuster: s.Adjuster,
+		Logger:   s.lg(),
+	}
+	res, err := d.Dispatch(ctx, s.HubBranchID)
+	if res.Sent+res.Failed+res.Unknown+res.Skipped > 0 {
+		s.lg().Printf("[stockrecon] outbox hub=%s: sent=%d failed=%d unknown=%d skipped=%d",
+			s.HubBranchID, res.Sent, res.Failed, res.Unknown, res.Skipped)
+	}
+	if err != nil {
+		return fmt.Errorf("dispatch stock adjustments: %w", err)
+	}
+	return nil
+}
+
+// outboxRows turns one payload into outbox rows, one per stock line, with
+// the item IDs behind each barcode.
+func (s StockReconcileService) outboxRows(p BranchPayload, items map[string][]string) []repos.StockAdjustmentOutboxRow {
+	out := make([]repos.StockAdjustmentOutboxRow, 0, len(p.Req.Stocks))
+	for _, it := range p.Req.Stocks {
+		out = append(out, repos.StockAdjustmentOutboxRow{
+			HubBranchID:   s.HubBranchID,
+			BranchID:      p.BranchID,
+			Barcode:       it.Barcode,
+			Quantity:      it.Quantity,
+			Operation:     it.OperationType,
+			SourceItemIDs: items[it.Barcode],
+		})
 	}
+	return out
 }
 
 func buildRequest(agg map[string]int, op string) StockAdjustmentRequest {
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// runReversals moves stock back for transferred sales that were since voided
+// or refunded: DEDUCT at the hub, INCREASE at the branch the stock came from.
+// Each reversing line is recorded in core.stock_virtual_transfer_reversals,
+// once per transfer it undoes, alongside the queued adjustments, so it is
+// only ever applied once.
+func (s StockReconcileService) runReversals(ctx context.Context) error {
+	totalReversals := 0
+	batches := 0
+
+	for {
+		cands, err := s.Repo.FetchReversibleItems(ctx, s.HubBranchID, s.FromTS, s.ToTS, s.Limit, s.TestBarcode)
+		if err != nil {
+			return fmt.Errorf("fetch reversible items: %w", err)
+		}
+
+		if len(cands) == 0 {
+			if batches > 0 {
+				s.lg().Printf("[stockrecon] reversals done batches=%d reversals=%d", batches, totalReversals)
+			}
+			return nil
+		}
+
+		batches++
+
+		reversals := capReversals(cands)
+
+		// Aggregate
+		deductAgg := make(map[string]map[string]int)          // hub -> barcode -> qty
+		increaseAgg := make(map[string]map[string]int)        // physical branch -> barcode -> qty
+		deductItems := make(map[string]map[string][]string)   // hub -> barcode -> reversing line IDs
+		increaseItems := make(map[string]map[string][]string) // physical branch -> barcode -> reversing line IDs
+		add := func(agg map[string]map[string]int, items map[string]map[string][]string, branch string, v repos.StockVirtualTransferReversalRow) {
+			if _, ok := agg[branch]; !ok {
+				agg[branch] = make(map[string]int)
+				items[branch] = make(map[string][]string)
+			}
+			agg[branch][v.Barcode] += v.Quantity
+			if ids := items[branch][v.Barcode]; !slices.Contains(ids, v.ReversalItemID) { // a line may span transfers
+				items[branch][v.Barcode] = append(ids, v.ReversalItemID)
+			}
+		}
+		for _, v := range reversals {
+			add(deductAgg, deductItems, v.FromBranchID, v)
+			add(increaseAgg, increaseItems, v.ToBranchID, v)
+		}
+
+		payloads := append(branchPayloads(deductAgg, "DEDUCT"), branchPayloads(increaseAgg, "INCREASE")...)
+
+		// ---- Logging ----
+		voids, refunds := 0, 0
+		for _, v := range reversals {
+			if v.Reason == repos.ReversalVoid {
+				voids++
+			} else {
+				refunds++
+			}
+		}
+		s.lg().Printf("[stockrecon] reversal batch=%d dry-run=%v window=[%s .. %s) limit=%d lines=%d reversals=%d (void=%d refund=%d)",
+			batches,
+			s.DryRun,
+			s.FromTS.Format(time.RFC3339),
+			s.ToTS.Format(time.RFC3339),
+			s.Limit,
+			len(cands),
+			len(reversals),
+			voids,
+			refunds,
+		)
+
+		n := min(s.MaxPreview, len(reversals))
+		for i := 0; i < n; i++ {
+			v := reversals[i]
+			s.lg().Printf("  - %s line=%s reverses item_id=%s barcode=%s qty=%d %s -> %s",
+				v.Reason, v.ReversalItemID, v.TransactionItemID, v.Barcode, v.Quantity, v.FromBranchID, v.ToBranchID)
+		}
+		if len(reversals) > n {
+			s.lg().Printf("  ... and %d more", len(reversals)-n)
+		}
+
+		for _, p := range payloads {
+			op := p.Req.Stocks[0].OperationType
+			lines, total := payloadStats(p.Req)
+			s.lg().Printf("[stockrecon] would POST reversal %s branch=%s lines=%d total_qty=%d", op, p.BranchID, lines, total)
+			printPreview(s.lg(), p.Req, s.MaxPreview)
+			if s.PrintJSON {
+				printJSON(s.lg(), fmt.Sprintf("reversal %s branch=%s", op, p.BranchID), p.Req)
+			}
+		}
+
+		// ---- STOP HERE IN DRY RUN ----
+		if s.DryRun {
+			s.lg().Printf("[stockrecon] dry-run=true: stopping reversals after 1 batch (no DB marks written)")
+			return nil
+		}
+
+		// ---- LIVE: plan (reversals + outbox) in one transaction ----
+		var outbox []repos.StockAdjustmentOutboxRow
+		for _, p := range payloads {
+			items := increaseItems[p.BranchID]
+			if p.Req.Stocks[0].OperationType == "DEDUCT" {
+				items = deductItems[p.BranchID]
+			}
+			outbox = append(outbox, s.outboxRows(p, items)...)
+		}
+
+		if err := s.Repo.PlanStockReversals(ctx, outbox, reversals); err != nil {
+			return fmt.Errorf("plan stock reversals failed: %w", err)
+		}
+		totalReversals += len(reversals)
+		s.lg().Printf("[stockrecon] LIVE reversal batch=%d planned: recorded %d reversals, queued %d adjustments", batches, len(reversals), len(outbox))
+
+		// ---- LIVE: post the queued adjustments ----
+		if err := s.dispatch(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// capReversals turns candidates into reversal rows. A line is spread over
+// its transfers in order, each taking no more than is left of the line and
+// of the transfer: a sale voided in place and by a negative line, or
+// refunded twice, can show up more than once in one batch. Pairs with
+// nothing left to reverse are dropped; they are fetched again next batch.
+func capReversals(cands []repos.StockReversalCandidate) []repos.StockVirtualTransferReversalRow {
+	left := make(map[string]int)     // transaction_item_id -> units not yet reversed
+	lineLeft := make(map[string]int) // reversal_item_id -> units the line has still to reverse
+	out := make([]repos.StockVirtualTransferReversalRow, 0, len(cands))
+	for _, c := range cands {
+		remaining, ok := left[c.TransactionItemID]
+		if !ok {
+			remaining = c.Remaining
+		}
+		todo, ok := lineLeft[c.ReversalItemID]
+		if !ok {
+			todo = c.Quantity
+		}
+		qty := min(todo, remaining)
+		if qty <= 0 {
+			continue
+		}
+		left[c.TransactionItemID] = remaining - qty
+		lineLeft[c.ReversalItemID] = todo - qty
+
+		out = append(out, repos.StockVirtualTransferReversalRow{
+			ReversalItemID:    c.ReversalItemID,
+			TransactionItemID: c.TransactionItemID,
+			Reason:            c.Reason,
+			FromBranchID:      c.ToBranchID,   // take back from the hub...
+			ToBranchID:        c.FromBranchID, // ...and return to the physical branch
+			Barcode:           c.Barcode,
+			Quantity:          qty,
+		})
+	}
+	return out
+}
+
+// branchPayloads builds one op request per branch, sorted by branch.
+func branchPayloads(agg map[string]map[string]int, op string) []BranchPayload {
+	out := make([]BranchPayload, 0, len(agg))
+	for branchID, byBarcode := range agg {
+		req := buildRequest(byBarcode, op)
+		if len(req.Stocks) == 0 {
+			continue
+		}
+		out = append(out, BranchPayload{BranchID: branchID, Req: req})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].BranchID < out[j].BranchID })
+	return out
+}
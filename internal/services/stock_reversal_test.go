@@ -0,0 +1,162 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func TestCapReversals(t *testing.T) {
+	cand := func(line, item string, qty, remaining int) repos.StockReversalCandidate {
+		return repos.StockReversalCandidate{
+			ReversalItemID:    line,
+			Reason:            repos.ReversalRefund,
+			TransactionItemID: item,
+			FromBranchID:      "base",
+			ToBranchID:        "pk",
+			Barcode:           "B1",
+			Quantity:          qty,
+			Remaining:         remaining,
+		}
+	}
+	row := func(line, item string, qty int) repos.StockVirtualTransferReversalRow {
+		return repos.StockVirtualTransferReversalRow{
+			ReversalItemID:    line,
+			TransactionItemID: item,
+			Reason:            repos.ReversalRefund,
+			FromBranchID:      "pk",   // taken back from the hub
+			ToBranchID:        "base", // returned to where it came from
+			Barcode:           "B1",
+			Quantity:          qty,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		cands []repos.StockReversalCandidate
+		want  []repos.StockVirtualTransferReversalRow
+	}{
+		{
+			name:  "whole transfer refunded",
+			cands: []repos.StockReversalCandidate{cand("r1", "s1", 2, 2)},
+			want:  []repos.StockVirtualTransferReversalRow{row("r1", "s1", 2)},
+		},
+		{
+			name:  "partial refund",
+			cands: []repos.StockReversalCandidate{cand("r1", "s1", 1, 3)},
+			want:  []repos.StockVirtualTransferReversalRow{row("r1", "s1", 1)},
+		},
+		{
+			name:  "refund larger than what is left is capped",
+			cands: []repos.StockReversalCandidate{cand("r1", "s1", 5, 2)},
+			want:  []repos.StockVirtualTransferReversalRow{row("r1", "s1", 2)},
+		},
+		{
+			name: "voided in place and by a negative line: moved back once",
+			cands: []repos.StockReversalCandidate{
+				cand("s1", "s1", 2, 2),
+				cand("r1", "s1", 2, 2),
+			},
+			want: []repos.StockVirtualTransferReversalRow{row("s1", "s1", 2)},
+		},
+		{
+			name: "two refunds of one transfer share what is left",
+			cands: []repos.StockReversalCandidate{
+				cand("r1", "s1", 2, 3),
+				cand("r2", "s1", 2, 3),
+			},
+			want: []repos.StockVirtualTransferReversalRow{
+				row("r1", "s1", 2),
+				row("r2", "s1", 1),
+			},
+		},
+		{
+			name: "separate transfers are capped separately",
+			cands: []repos.StockReversalCandidate{
+				cand("r1", "s1", 1, 1),
+				cand("r2", "s2", 1, 1),
+			},
+			want: []repos.StockVirtualTransferReversalRow{
+				row("r1", "s1", 1),
+				row("r2", "s2", 1),
+			},
+		},
+		{
+			name: "one refund spread over two transfers, oldest first",
+			cands: []repos.StockReversalCandidate{
+				cand("r1", "s1", 2, 1),
+				cand("r1", "s2", 2, 1),
+			},
+			want: []repos.StockVirtualTransferReversalRow{
+				row("r1", "s1", 1),
+				row("r1", "s2", 1),
+			},
+		},
+		{
+			name: "a spread line stops once it is used up",
+			cands: []repos.StockReversalCandidate{
+				cand("r1", "s1", 3, 2),
+				cand("r1", "s2", 3, 5),
+				cand("r1", "s3", 3, 1),
+			},
+			want: []repos.StockVirtualTransferReversalRow{
+				row("r1", "s1", 2),
+				row("r1", "s2", 1),
+			},
+		},
+		{
+			name: "spread lines share transfers with other lines",
+			cands: []repos.StockReversalCandidate{
+				cand("r1", "s1", 1, 1),
+				cand("r2", "s1", 2, 1),
+				cand("r2", "s2", 2, 1),
+			},
+			want: []repos.StockVirtualTransferReversalRow{
+				row("r1", "s1", 1),
+				row("r2", "s2", 1),
+			},
+		},
+		{
+			name:  "nothing left",
+			cands: []repos.StockReversalCandidate{cand("r1", "s1", 1, 0)},
+			want:  []repos.StockVirtualTransferReversalRow{},
+		},
+		{
+			name:  "zero quantity line",
+			cands: []repos.StockReversalCandidate{cand("r1", "s1", 0, 1)},
+			want:  []repos.StockVirtualTransferReversalRow{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capReversals(tt.cands)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("capReversals()\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBranchPayloads(t *testing.T) {
+	agg := map[string]map[string]int{
+		"pk":   {"B2": 1, "B1": 3},
+		"base": {"B1": 2},
+		"gone": {"B1": 0}, // nothing to post
+	}
+	got := branchPayloads(agg, "DEDUCT")
+
+	want := []BranchPayload{
+		{BranchID: "base", Req: StockAdjustmentRequest{Stocks: []StockAdjustmentItem{
+			{Barcode: "B1", Quantity: 2, OperationType: "DEDUCT"},
+		}}},
+		{BranchID: "pk", Req: StockAdjustmentRequest{Stocks: []StockAdjustmentItem{
+			{Barcode: "B1", Quantity: 3, OperationType: "DEDUCT"},
+			{Barcode: "B2", Quantity: 1, OperationType: "DEDUCT"},
+		}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("branchPayloads()\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+// stockSource is where one hub sale's stock is deducted from, and why.
+type stockSource struct {
+	BranchID string // "" = unknown (no staff override)
+	Source   string // repos.Source* const
+	RuleID   sql.NullInt64
+}
+
+// label names the decision in logs: the rule, or "default".
+func (d stockSource) label() string {
+	if !d.RuleID.Valid {
+		return "default " + d.Source
+	}
+	return fmt.Sprintf("rule #%d %s", d.RuleID.Int64, d.Source)
+}
+
+// ruleMatches reports whether every filter of rule accepts sale. The
+// exception re-check does the same matching in SQL (repos.stockRuleJoin).
+func ruleMatches(rule repos.StockSourceRule, sale repos.HubSaleRow) bool {
+	matchAny := func(want string, have ...string) bool {
+		if want == "" {
+			return true
+		}
+		for _, h := range have {
+			if h != "" && strings.EqualFold(strings.TrimSpace(want), strings.TrimSpace(h)) {
+				return true
+			}
+		}
+		return false
+	}
+	return matchAny(rule.ProductCategory, sale.ProductCategoryID, sale.ProductCategoryName) &&
+		matchAny(rule.ProductBrand, sale.ProductBrandID, sale.ProductBrandName) &&
+		matchAny(rule.StaffID, sale.StaffID)
+}
+
+// sourceFor applies the hub's rules (already in priority order) to sale:
+// the first match decides, and with no match the stock comes from the
+// stylist's physical branch override, as it always has.
+func (s StockReconcileService) sourceFor(rules []repos.StockSourceRule, sale repos.HubSaleRow) stockSource {
+	for _, rule := range rules {
+		if !ruleMatches(rule, sale) {
+			continue
+		}
+		d := stockSource{Source: rule.Source, RuleID: sql.NullInt64{Int64: rule.ID, Valid: true}}
+		switch rule.Source {
+		case repos.SourceBranch:
+			d.BranchID = rule.SourceBranchID
+		case repos.SourceSellingBranch:
+			d.BranchID = s.HubBranchID
+		default:
+			d.BranchID = physicalBranch(sale)
+		}
+		return d
+	}
+	return stockSource{Source: repos.SourceStaffOverride, BranchID: physicalBranch(sale)}
+}
+
+// physicalBranch is the sale's stylist's physical branch override, or "".
+func physicalBranch(sale repos.HubSaleRow) string {
+	if !sale.PhysicalBranchID.Valid {
+		return ""
+	}
+	return strings.TrimSpace(sale.PhysicalBranchID.String)
+}
+
+// logRules prints the rules a run will apply.
+func (s StockReconcileService) logRules(rules []repos.StockSourceRule) {
+	if len(rules) == 0 {
+		s.lg().Printf("[stockrecon] hub=%s: no stock source rules, deducting from each stylist's physical branch", s.HubBranchID)
+		return
+	}
+	s.lg().Printf("[stockrecon] hub=%s: %d stock source rule(s), first match wins:", s.HubBranchID, len(rules))
+	for _, r := range rules {
+		var match []string
+		if r.ProductCategory != "" {
+			match = append(match, "category="+r.ProductCategory)
+		}
+		if r.ProductBrand != "" {
+			match = append(match, "brand="+r.ProductBrand)
+		}
+		if r.StaffID != "" {
+			match = append(match, "staff="+r.StaffID)
+		}
+		if len(match) == 0 {
+			match = append(match, "any sale")
+		}
+		target := r.Source
+		if r.Source == repos.SourceBranch {
+			target += " " + r.SourceBranchID
+		}
+		s.lg().Printf("  - #%d priority=%d %s -> %s", r.ID, r.Priority, strings.Join(match, " "), target)
+	}
+}
+
+// logSourceDecisions summarises which rule sent how many sales where.
+func (s StockReconcileService) logSourceDecisions(planned []plannedSale) {
+	type key struct{ label, branch string }
+	counts := make(map[key]int)
+	for _, p := range planned {
+		counts[key{p.From.label(), p.From.BranchID}]++
+	}
+	keys := make([]key, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].label != keys[j].label {
+			return keys[i].label < keys[j].label
+		}
+		return keys[i].branch < keys[j].branch
+	})
+	for _, k := range keys {
+		note := ""
+		if k.branch == s.HubBranchID {
+			note = " (hub's own stock, nothing to move)"
+		}
+		s.lg().Printf("[stockrecon] %s -> from branch=%s rows=%d%s", k.label, k.branch, counts[k], note)
+	}
+}
+
+// plannedSale is a sale with the branch its stock comes from.
+type plannedSale struct {
+	repos.HubSaleRow
+	From stockSource
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/araquach/phorest-datahub/internal/repos"
+)
+
+func TestRuleMatches(t *testing.T) {
+	sale := repos.HubSaleRow{
+		ProductCategoryID:   "cat-1",
+		ProductCategoryName: "Colour",
+		ProductBrandID:      "brand-1",
+		ProductBrandName:    "Wella Professionals",
+		StaffID:             "staff-1",
+	}
+
+	tests := []struct {
+		name string
+		rule repos.StockSourceRule
+		sale *repos.HubSaleRow // nil = sale above
+		want bool
+	}{
+		{name: "no filters match anything", want: true},
+		{name: "no filters match a sale with nothing set", sale: &repos.HubSaleRow{}, want: true},
+		{name: "category by ID", rule: repos.StockSourceRule{ProductCategory: "cat-1"}, want: true},
+		{name: "category by name", rule: repos.StockSourceRule{ProductCategory: "Colour"}, want: true},
+		{name: "category name ignores case and spaces", rule: repos.StockSourceRule{ProductCategory: "  colour "}, want: true},
+		{name: "other category", rule: repos.StockSourceRule{ProductCategory: "Styling"}, want: false},
+		{name: "category is not a prefix match", rule: repos.StockSourceRule{ProductCategory: "Col"}, want: false},
+		{name: "brand by ID", rule: repos.StockSourceRule{ProductBrand: "BRAND-1"}, want: true},
+		{name: "brand by name", rule: repos.StockSourceRule{ProductBrand: "wella professionals"}, want: true},
+		{name: "other brand", rule: repos.StockSourceRule{ProductBrand: "Redken"}, want: false},
+		{name: "staff", rule: repos.StockSourceRule{StaffID: "staff-1"}, want: true},
+		{name: "other staff", rule: repos.StockSourceRule{StaffID: "staff-2"}, want: false},
+		{
+			name: "every filter must match",
+			rule: repos.StockSourceRule{ProductCategory: "Colour", ProductBrand: "Wella Professionals", StaffID: "staff-1"},
+			want: true,
+		},
+		{
+			name: "one failing filter fails the rule",
+			rule: repos.StockSourceRule{ProductCategory: "Colour", ProductBrand: "Wella Professionals", StaffID: "staff-2"},
+			want: false,
+		},
+		{
+			name: "a filter never matches a sale without that field",
+			rule: repos.StockSourceRule{ProductCategory: "Colour"},
+			sale: &repos.HubSaleRow{StaffID: "staff-1"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sale
+			if tt.sale != nil {
+				s = *tt.sale
+			}
+			if got := ruleMatches(tt.rule, s); got != tt.want {
+				t.Errorf("ruleMatches(%+v) = %v, want %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceFor(t *testing.T) {
+	svc := StockReconcileService{HubBranchID: "pk"}
+	ruleID := func(id int64) sql.NullInt64 { return sql.NullInt64{Int64: id, Valid: true} }
+
+	colour := repos.HubSaleRow{
+		ProductCategoryName: "Colour",
+		StaffID:             "staff-1",
+		PhysicalBranchID:    sql.NullString{String: " base ", Valid: true},
+	}
+	styling := repos.HubSaleRow{
+		ProductCategoryName: "Styling",
+		StaffID:             "staff-2",
+		PhysicalBranchID:    sql.NullString{String: "jakata", Valid: true},
+	}
+	noOverride := repos.HubSaleRow{ProductCategoryName: "Styling", StaffID: "staff-3"}
+
+	colourFromBase := repos.StockSourceRule{ID: 1, ProductCategory: "colour", Source: repos.SourceBranch, SourceBranchID: "base"}
+	staff1Own := repos.StockSourceRule{ID: 2, StaffID: "staff-1", Source: repos.SourceSellingBranch}
+	anyOwn := repos.StockSourceRule{ID: 3, Source: repos.SourceSellingBranch}
+	anyOverride := repos.StockSourceRule{ID: 4, Source: repos.SourceStaffOverride}
+
+	tests := []struct {
+		name  string
+		rules []repos.StockSourceRule
+		sale  repos.HubSaleRow
+		want  stockSource
+	}{
+		{
+			name: "no rules: stylist's physical branch, trimmed",
+			sale: colour,
+			want: stockSource{Source: repos.SourceStaffOverride, BranchID: "base"},
+		},
+		{
+			name: "no rules and no override: unknown branch",
+			sale: noOverride,
+			want: stockSource{Source: repos.SourceStaffOverride},
+		},
+		{
+			name:  "no rule matches: default",
+			rules: []repos.StockSourceRule{colourFromBase, staff1Own},
+			sale:  styling,
+			want:  stockSource{Source: repos.SourceStaffOverride, BranchID: "jakata"},
+		},
+		{
+			name:  "BRANCH rule: the rule's branch",
+			rules: []repos.StockSourceRule{colourFromBase},
+			sale:  colour,
+			want:  stockSource{Source: repos.SourceBranch, BranchID: "base", RuleID: ruleID(1)},
+		},
+		{
+			name:  "SELLING_BRANCH rule: the hub",
+			rules: []repos.StockSourceRule{anyOwn},
+			sale:  styling,
+			want:  stockSource{Source: repos.SourceSellingBranch, BranchID: "pk", RuleID: ruleID(3)},
+		},
+		{
+			name:  "STAFF_OVERRIDE rule: stylist's physical branch, with the rule recorded",
+			rules: []repos.StockSourceRule{anyOverride},
+			sale:  styling,
+			want:  stockSource{Source: repos.SourceStaffOverride, BranchID: "jakata", RuleID: ruleID(4)},
+		},
+		{
+			name:  "STAFF_OVERRIDE rule without an override: unknown branch",
+			rules: []repos.StockSourceRule{anyOverride},
+			sale:  noOverride,
+			want:  stockSource{Source: repos.SourceStaffOverride, RuleID: ruleID(4)},
+		},
+		{
+			name:  "first match wins",
+			rules: []repos.StockSourceRule{staff1Own, colourFromBase, anyOverride},
+			sale:  colour,
+			want:  stockSource{Source: repos.SourceSellingBranch, BranchID: "pk", RuleID: ruleID(2)},
+		},
+		{
+			name:  "non-matching rules are skipped until one matches",
+			rules: []repos.StockSourceRule{colourFromBase, staff1Own, anyOwn, anyOverride},
+			sale:  styling,
+			want:  stockSource{Source: repos.SourceSellingBranch, BranchID: "pk", RuleID: ruleID(3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svc.sourceFor(tt.rules, tt.sale); got != tt.want {
+				t.Errorf("sourceFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
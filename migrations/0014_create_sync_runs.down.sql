@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS core.sync_run_branches;
+DROP TABLE IF EXISTS core.sync_runs;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS core.sync_runs
+(
+    id            BIGSERIAL PRIMARY KEY,
+    entity        TEXT        NOT NULL,          -- e.g. 'transactions_csv', 'appointments_api'
+    status        TEXT        NOT NULL,          -- 'running', 'success', 'failed', 'skipped' (lock held elsewhere)
+    started_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at   TIMESTAMPTZ NULL,
+    rows_fetched  BIGINT      NOT NULL DEFAULT 0,
+    rows_upserted BIGINT      NOT NULL DEFAULT 0,
+    error         TEXT        NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_entity_started
+    ON core.sync_runs (entity, started_at DESC);
+
+CREATE TABLE IF NOT EXISTS core.sync_run_branches
+(
+    id               BIGSERIAL PRIMARY KEY,
+    run_id           BIGINT      NOT NULL REFERENCES core.sync_runs (id) ON DELETE CASCADE,
+    branch_id        TEXT        NOT NULL,       -- 'ALL' for business-wide syncs
+    status           TEXT        NOT NULL,       -- 'running', 'success', 'failed', 'skipped' (lock held elsewhere)
+    started_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at      TIMESTAMPTZ NULL,
+    window_from      TIMESTAMPTZ NULL,
+    window_to        TIMESTAMPTZ NULL,
+    rows_fetched     BIGINT      NOT NULL DEFAULT 0,
+    rows_upserted    BIGINT      NOT NULL DEFAULT 0,
+    watermark_before TIMESTAMPTZ NULL,
+    watermark_after  TIMESTAMPTZ NULL,
+    error            TEXT        NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_run_branches_run
+    ON core.sync_run_branches (run_id);
+
+CREATE INDEX IF NOT EXISTS idx_sync_run_branches_branch_status
+    ON core.sync_run_branches (branch_id, status, started_at DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS core.csv_export_jobs;
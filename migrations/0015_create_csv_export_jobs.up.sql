@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS core.csv_export_jobs
+(
+    id                BIGSERIAL PRIMARY KEY,
+    entity            TEXT        NOT NULL,          -- 'transactions_csv', 'clients_csv'
+    branch_id         TEXT        NOT NULL,          -- branch the job was created under
+    job_type          TEXT        NOT NULL,          -- Phorest jobType, e.g. 'TRANSACTIONS_CSV'
+    job_id            TEXT        NOT NULL,          -- Phorest jobId
+    start_filter      TEXT        NOT NULL DEFAULT '',
+    finish_filter     TEXT        NOT NULL DEFAULT '',
+    filter_expression TEXT        NOT NULL DEFAULT '',
+    status            TEXT        NOT NULL,          -- QUEUED / IN_PROGRESS / DONE / FAILED (Phorest), IMPORTED / EXPIRED (ours)
+    failure_reason    TEXT        NULL,
+    total_rows        INTEGER     NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at       TIMESTAMPTZ NULL,              -- Phorest reported DONE/FAILED
+    imported_at       TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_csv_export_jobs_job_id
+    ON core.csv_export_jobs (job_id);
+
+-- Lookup for "is there already a job for this window?"
+CREATE INDEX IF NOT EXISTS idx_csv_export_jobs_window
+    ON core.csv_export_jobs (entity, branch_id, start_filter, finish_filter, filter_expression, created_at DESC);
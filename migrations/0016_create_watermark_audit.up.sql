@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS core.watermark_audit
+(
+    id           BIGSERIAL PRIMARY KEY,
+    entity       TEXT        NOT NULL,          -- sync_watermarks.entity
+    branch_id    TEXT        NOT NULL,          -- sync_watermarks.branch_id ('ALL' for business-wide)
+    action       TEXT        NOT NULL,          -- 'set', 'rewind', 'reset'
+    old_value    TIMESTAMPTZ NULL,              -- NULL = no row before
+    new_value    TIMESTAMPTZ NULL,              -- NULL = row deleted (reset)
+    actor        TEXT        NOT NULL,          -- who ran the command (user@host unless --actor)
+    reason       TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_watermark_audit_entity_branch
+    ON core.watermark_audit (entity, branch_id, created_at DESC);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS core.watermark_history
+(
+    id           BIGSERIAL PRIMARY KEY,
+    entity       TEXT        NOT NULL,          -- sync_watermarks.entity
+    branch_id    TEXT        NOT NULL,          -- sync_watermarks.branch_id ('ALL' for business-wide)
+    old_value    TIMESTAMPTZ NULL,              -- NULL = no row before
+    new_value    TIMESTAMPTZ NULL,              -- NULL = row deleted (watermarks reset)
+    source       TEXT        NOT NULL,          -- 'sync', 'bootstrap', 'admin'
+    run_id       BIGINT      NULL REFERENCES core.sync_runs (id) ON DELETE SET NULL,
+    jump_seconds BIGINT      NULL,              -- new_value - old_value
+    flag_future  BOOLEAN     NOT NULL DEFAULT false, -- new_value was ahead of the clock
+    flag_jump    BOOLEAN     NOT NULL DEFAULT false, -- jump exceeded WATERMARK_MAX_JUMP
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_watermark_history_entity_branch
+    ON core.watermark_history (entity, branch_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_watermark_history_flagged
+    ON core.watermark_history (created_at DESC)
+    WHERE flag_future OR flag_jump;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS raw.import_rejects
+(
+    id          BIGSERIAL PRIMARY KEY,
+    source      TEXT        NOT NULL,          -- CSV schema, e.g. 'transactions_csv'
+    file        TEXT        NOT NULL,          -- path of the imported CSV
+    line        INTEGER     NOT NULL,          -- line in the file (header = 1)
+    column_name TEXT        NOT NULL,
+    raw_value   TEXT        NOT NULL,
+    error       TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_rejects_file
+    ON raw.import_rejects (file, line);
+
+CREATE INDEX IF NOT EXISTS idx_import_rejects_created_at
+    ON raw.import_rejects (created_at DESC);
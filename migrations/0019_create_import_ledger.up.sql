@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS core.import_ledger
+(
+    id              BIGSERIAL PRIMARY KEY,
+    entity          TEXT        NOT NULL,          -- 'transactions_csv', 'clients_csv', 'reviews_csv'
+    file_name       TEXT        NOT NULL,          -- base name of the imported file
+    path            TEXT        NOT NULL,          -- where it was imported from
+    sha256          TEXT        NOT NULL,          -- hex digest of the file contents
+    size_bytes      BIGINT      NOT NULL,
+    row_count       INTEGER     NOT NULL,          -- rows imported (items for transactions)
+    rejected_count  INTEGER     NOT NULL DEFAULT 0, -- cells sent to raw.import_rejects
+    changed         BOOLEAN     NOT NULL DEFAULT false, -- same file name imported before with other contents
+    previous_sha256 TEXT        NULL,              -- that earlier digest
+    run_id          BIGINT      NULL REFERENCES core.sync_runs (id) ON DELETE SET NULL,
+    imported_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Identical contents are only ever imported once per entity.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_import_ledger_entity_sha256
+    ON core.import_ledger (entity, sha256);
+
+CREATE INDEX IF NOT EXISTS idx_import_ledger_entity_file
+    ON core.import_ledger (entity, file_name, imported_at DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS core.stock_adjustment_outbox;
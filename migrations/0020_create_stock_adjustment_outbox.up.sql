@@ -0,0 +1,32 @@
+-- Stock adjustments planned by stock reconcile, written in the same
+-- transaction as the core.stock_virtual_transfers rows they cover and posted
+-- to Phorest afterwards by the dispatcher, so a crash between the two can
+-- never post the same adjustment twice.
+--
+-- status: pending → sending → sent | failed. A row left in 'sending' was
+-- being posted when the process died: it may or may not have reached
+-- Phorest, so it is only retried by hand (datahub stock outbox retry --id).
+CREATE TABLE IF NOT EXISTS core.stock_adjustment_outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    hub_branch_id   TEXT        NOT NULL,          -- hub whose reconcile planned it
+    branch_id       TEXT        NOT NULL,          -- branch whose stock moves
+    barcode         TEXT        NOT NULL,
+    quantity        INTEGER     NOT NULL CHECK (quantity > 0),
+    operation       TEXT        NOT NULL CHECK (operation IN ('DEDUCT', 'INCREASE')),
+    source_item_ids TEXT[]      NOT NULL,          -- raw.transaction_items behind the quantity
+    status          TEXT        NOT NULL DEFAULT 'pending'
+                                CHECK (status IN ('pending', 'sending', 'sent', 'failed')),
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    last_error      TEXT        NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_adjustment_outbox_hub_status
+    ON core.stock_adjustment_outbox (hub_branch_id, status, id);
+
+CREATE INDEX IF NOT EXISTS idx_stock_adjustment_outbox_unsent
+    ON core.stock_adjustment_outbox (status)
+    WHERE status <> 'sent';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS raw.idx_transaction_items_voided_transaction_id;
+DROP TABLE IF EXISTS core.stock_virtual_transfer_reversals;
@@ -0,0 +1,28 @@
+-- Stock moved back after a transferred PK sale was voided or refunded.
+--
+-- Each row is one reversing line: the sale itself when it was voided in
+-- place (reversal_item_id = transaction_item_id), or the negative-quantity
+-- line that voided / refunded it. The stock goes back the other way: DEDUCT
+-- at the transfer's to_branch_id (the hub), INCREASE at its from_branch_id.
+-- Reversals of one transfer never add up to more than its quantity.
+CREATE TABLE IF NOT EXISTS core.stock_virtual_transfer_reversals
+(
+    id                  BIGSERIAL PRIMARY KEY,
+    reversal_item_id    TEXT        NOT NULL UNIQUE,
+    transaction_item_id TEXT        NOT NULL
+        REFERENCES core.stock_virtual_transfers (transaction_item_id),
+    reason              TEXT        NOT NULL CHECK (reason IN ('VOID', 'REFUND')),
+    processed_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    from_branch_id      TEXT        NOT NULL, -- where the stock is taken back from (the hub)
+    to_branch_id        TEXT        NOT NULL, -- where it is returned (the physical branch)
+    barcode             TEXT        NOT NULL,
+    quantity            INTEGER     NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_svtr_transaction_item_id
+    ON core.stock_virtual_transfer_reversals (transaction_item_id);
+
+-- Lets reversal lookups find a voided transaction's items.
+CREATE INDEX IF NOT EXISTS idx_transaction_items_voided_transaction_id
+    ON raw.transaction_items (voided_transaction_id)
+    WHERE voided_transaction_id IS NOT NULL AND voided_transaction_id <> '';
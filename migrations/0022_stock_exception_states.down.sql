@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trg_staff_physical_branch_overrides_touch ON core.staff_physical_branch_overrides;
+DROP FUNCTION IF EXISTS core.touch_updated_at();
+
+DROP INDEX IF EXISTS core.idx_svte_status;
+
+ALTER TABLE core.stock_virtual_transfer_exceptions
+    DROP COLUMN IF EXISTS note,
+    DROP COLUMN IF EXISTS resolved_at,
+    DROP COLUMN IF EXISTS checked_at,
+    DROP COLUMN IF EXISTS status;
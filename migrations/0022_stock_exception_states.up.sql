@@ -0,0 +1,33 @@
+-- Exceptions used to be final: any row here kept its item out of stock
+-- reconcile for good. Now they have a state:
+--
+--   open      waiting for a fix (staff override / product barcode); skipped
+--   resolved  the cause was fixed; reconcile picks the item up as normal
+--   ignored   skipped for good (datahub stock exceptions ignore)
+--
+-- checked_at is when the row was last re-evaluated; open rows are looked at
+-- again once the staff override, product or item behind them changes.
+ALTER TABLE core.stock_virtual_transfer_exceptions
+    ADD COLUMN IF NOT EXISTS status      TEXT        NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'resolved', 'ignored')),
+    ADD COLUMN IF NOT EXISTS checked_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ NULL,
+    ADD COLUMN IF NOT EXISTS note        TEXT        NULL;
+
+CREATE INDEX IF NOT EXISTS idx_svte_status
+    ON core.stock_virtual_transfer_exceptions (status);
+
+-- Overrides are edited by hand; keep updated_at honest so edits are noticed.
+CREATE OR REPLACE FUNCTION core.touch_updated_at() RETURNS trigger AS
+$$
+BEGIN
+    NEW.updated_at := now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_staff_physical_branch_overrides_touch ON core.staff_physical_branch_overrides;
+CREATE TRIGGER trg_staff_physical_branch_overrides_touch
+    BEFORE UPDATE ON core.staff_physical_branch_overrides
+    FOR EACH ROW
+EXECUTE FUNCTION core.touch_updated_at();
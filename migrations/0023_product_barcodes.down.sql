@@ -0,0 +1,8 @@
+ALTER TABLE core.stock_virtual_transfers
+    DROP COLUMN IF EXISTS barcode_source;
+
+DROP INDEX IF EXISTS raw.idx_ph_products_code;
+DROP INDEX IF EXISTS raw.idx_ph_products_parent_id;
+
+ALTER TABLE raw.ph_products
+    DROP COLUMN IF EXISTS barcode;
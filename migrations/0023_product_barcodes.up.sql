@@ -0,0 +1,20 @@
+-- Product barcodes from the products sync, so stock reconcile can fill in a
+-- sale's barcode when the transaction item arrives without one. Existing
+-- products get theirs on the next full products sync
+-- (datahub watermarks reset --entity products_api).
+ALTER TABLE raw.ph_products
+    ADD COLUMN IF NOT EXISTS barcode TEXT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ph_products_parent_id
+    ON raw.ph_products (parent_id)
+    WHERE parent_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ph_products_code
+    ON raw.ph_products (code)
+    WHERE code IS NOT NULL;
+
+-- Where a transfer's barcode came from: ITEM (the transaction item itself),
+-- PRODUCT_ID, PARENT, VARIANT or PRODUCT_CODE (the synced catalogue).
+-- NULL for transfers recorded before this existed (all ITEM).
+ALTER TABLE core.stock_virtual_transfers
+    ADD COLUMN IF NOT EXISTS barcode_source TEXT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE core.stock_virtual_transfers
+    DROP COLUMN IF EXISTS rule_id;
+
+DROP TABLE IF EXISTS core.stock_source_rules;
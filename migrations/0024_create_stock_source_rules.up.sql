@@ -0,0 +1,48 @@
+-- Which branch's stock a hub's product sales are taken from.
+--
+-- Stock reconcile looks at the sales of a selling (hub) branch and, for each
+-- one, uses the first active rule of that branch (lowest priority, then id)
+-- whose filters all match. Empty filters match anything; product_category
+-- and product_brand match the category / brand ID or name (any case).
+--
+-- source:
+--   STAFF_OVERRIDE  the stylist's core.staff_physical_branch_overrides branch
+--                   (what happens when no rule matches)
+--   BRANCH          source_branch_id
+--   SELLING_BRANCH  the hub's own stock: nothing is moved
+--
+-- Any branch with active rules is reconciled as a hub.
+CREATE TABLE IF NOT EXISTS core.stock_source_rules
+(
+    id                BIGSERIAL PRIMARY KEY,
+    selling_branch_id TEXT        NOT NULL,
+    priority          INTEGER     NOT NULL DEFAULT 100,
+    product_category  TEXT        NULL,
+    product_brand     TEXT        NULL,
+    staff_id          TEXT        NULL,
+    source            TEXT        NOT NULL
+        CHECK (source IN ('STAFF_OVERRIDE', 'BRANCH', 'SELLING_BRANCH')),
+    source_branch_id  TEXT        NULL,
+    active            BOOLEAN     NOT NULL DEFAULT TRUE,
+    note              TEXT        NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK ((source = 'BRANCH') = (source_branch_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_source_rules_selling_branch
+    ON core.stock_source_rules (selling_branch_id, priority, id)
+    WHERE active;
+
+DROP TRIGGER IF EXISTS trg_stock_source_rules_touch ON core.stock_source_rules;
+CREATE TRIGGER trg_stock_source_rules_touch
+    BEFORE UPDATE ON core.stock_source_rules
+    FOR EACH ROW
+EXECUTE FUNCTION core.touch_updated_at();
+
+-- The rule that picked a transfer's from_branch_id (NULL = no rule matched,
+-- or recorded before rules existed).
+ALTER TABLE core.stock_virtual_transfers
+    ADD COLUMN IF NOT EXISTS rule_id BIGINT NULL
+        REFERENCES core.stock_source_rules (id) ON DELETE SET NULL;
@@ -0,0 +1,7 @@
+-- Fails if a line was spread over several transfers; remove those rows first.
+ALTER TABLE core.stock_virtual_transfer_reversals
+    DROP CONSTRAINT IF EXISTS uq_svtr_reversal_item_transaction_item;
+
+ALTER TABLE core.stock_virtual_transfer_reversals
+    ADD CONSTRAINT stock_virtual_transfer_reversals_reversal_item_id_key
+        UNIQUE (reversal_item_id);
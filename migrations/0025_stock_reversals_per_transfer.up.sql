@@ -0,0 +1,9 @@
+-- A negative line can undo more than one transfer: a refund of 2 against a
+-- sale with two single-unit lines of the product reverses one unit of each.
+-- So a reversal row is one reversing line applied to one transfer.
+ALTER TABLE core.stock_virtual_transfer_reversals
+    DROP CONSTRAINT IF EXISTS stock_virtual_transfer_reversals_reversal_item_id_key;
+
+ALTER TABLE core.stock_virtual_transfer_reversals
+    ADD CONSTRAINT uq_svtr_reversal_item_transaction_item
+        UNIQUE (reversal_item_id, transaction_item_id);